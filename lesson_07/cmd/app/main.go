@@ -2,13 +2,16 @@ package main
 
 import (
 	"fmt"
-	_ "lesson_07/internal"
+	"lesson_07/internal"
 	. "lesson_07/internal/document_store"
+	"log/slog"
 )
 
 func main() {
+	internal.SetupLogger(internal.LoggerConfig{Level: slog.LevelDebug})
+
 	store := NewStore()
-	_, collection := store.CreateCollection("test", &CollectionConfig{PrimaryKey: "id"})
+	collection, _ := store.CreateCollection("test", &CollectionConfig{PrimaryKey: "id"})
 	collection.Put(Document{
 		Fields: map[string]DocumentField{
 			"id": {Type: DocumentFieldTypeString, Value: "unique_id"},
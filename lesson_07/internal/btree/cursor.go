@@ -0,0 +1,92 @@
+package btree
+
+// Cursor iterates over keys in ascending byte order. A cursor of a write
+// transaction observes uncommitted changes but must not be used while the
+// transaction is being modified.
+type Cursor struct {
+	tx    *Tx
+	stack []elementRef
+	err   error
+}
+
+type elementRef struct {
+	node  *node
+	index int
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+func (c *Cursor) First() (key, value []byte) {
+	return c.Seek(nil)
+}
+
+// Seek moves the cursor to the first key that is >= seek.
+func (c *Cursor) Seek(seek []byte) (key, value []byte) {
+	c.stack = c.stack[:0]
+	if c.tx.closed {
+		c.err = ErrTxClosed
+		return nil, nil
+	}
+
+	n, err := c.tx.rootNode(false)
+	if err != nil {
+		c.err = err
+		return nil, nil
+	}
+
+	for !n.leaf {
+		i := n.childIndex(seek)
+		c.stack = append(c.stack, elementRef{node: n, index: i})
+		if n, err = c.tx.child(n, i, false); err != nil {
+			c.err = err
+			return nil, nil
+		}
+	}
+
+	i, _ := n.leafIndex(seek)
+	c.stack = append(c.stack, elementRef{node: n, index: i})
+
+	return c.current()
+}
+
+func (c *Cursor) Next() (key, value []byte) {
+	if len(c.stack) == 0 {
+		return nil, nil
+	}
+	c.stack[len(c.stack)-1].index++
+
+	return c.current()
+}
+
+// current moves to the next leaf when the top of the stack is exhausted and
+// returns the element under the cursor.
+func (c *Cursor) current() (key, value []byte) {
+	for len(c.stack) > 0 {
+		top := c.stack[len(c.stack)-1]
+		if top.index < len(top.node.inodes) {
+			if top.node.leaf {
+				in := top.node.inodes[top.index]
+				return in.key, in.value
+			}
+
+			child, err := c.tx.child(top.node, top.index, false)
+			if err != nil {
+				c.err = err
+				c.stack = c.stack[:0]
+				return nil, nil
+			}
+			c.stack = append(c.stack, elementRef{node: child})
+			continue
+		}
+
+		c.stack = c.stack[:len(c.stack)-1]
+		if len(c.stack) > 0 {
+			c.stack[len(c.stack)-1].index++
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,363 @@
+// Package btree implements a single-file, page-based B+tree key/value engine.
+//
+// Pages have a fixed size. Write transactions never modify committed pages:
+// changed nodes are written to newly allocated pages and the commit becomes
+// visible by writing one of two alternating meta pages, so a crash at any
+// point leaves the previous commit intact. Pages released by a commit are
+// kept on a free list and reused once no reader can observe them.
+package btree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const DefaultPageSize = 4096
+
+const defaultCacheSize = 1024
+
+var (
+	ErrDatabaseClosed = errors.New("database is closed")
+	ErrTxClosed       = errors.New("transaction is closed")
+	ErrTxNotWritable  = errors.New("transaction is not writable")
+	ErrKeyRequired    = errors.New("key required")
+)
+
+type Options struct {
+	// PageSize is used when a new file is created. Existing files keep their
+	// own page size.
+	PageSize int
+	// NoSync skips fsync on commit. Useful for tests only.
+	NoSync bool
+	// CacheSize is the amount of decoded nodes kept in memory.
+	CacheSize int
+}
+
+type DB struct {
+	path     string
+	file     *os.File
+	pageSize int
+	noSync   bool
+
+	writer sync.Mutex // serializes write transactions
+
+	mu       sync.RWMutex // guards fields below
+	meta     meta
+	freelist *freelist
+	readers  map[uint64]int
+	closed   bool
+
+	cacheMu   sync.Mutex
+	cache     map[pgid]*node
+	cacheSize int
+}
+
+type Stats struct {
+	PageSize  int
+	PageCount int
+	FreePages int
+	TxID      uint64
+	Readers   int
+}
+
+func Open(path string, opts *Options) (*DB, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		path:      path,
+		file:      file,
+		noSync:    opts.NoSync,
+		readers:   map[uint64]int{},
+		cache:     map[pgid]*node{},
+		cacheSize: opts.CacheSize,
+	}
+	if db.cacheSize <= 0 {
+		db.cacheSize = defaultCacheSize
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if info.Size() == 0 {
+		pageSize := opts.PageSize
+		if pageSize <= 0 {
+			pageSize = DefaultPageSize
+		}
+		err = db.init(pageSize)
+	} else {
+		err = db.load(opts.PageSize)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	return db, nil
+}
+
+// init writes two meta pages, an empty freelist and an empty root leaf.
+func (db *DB) init(pageSize int) error {
+	if pageSize < 512 {
+		return fmt.Errorf("page size %d is too small", pageSize)
+	}
+	db.pageSize = pageSize
+
+	buf := make([]byte, pageSize*4)
+	for i := 0; i < 2; i++ {
+		m := meta{
+			magic:    magic,
+			version:  version,
+			pageSize: uint32(pageSize),
+			freelist: 2,
+			root:     3,
+			pgid:     4,
+			txid:     uint64(i),
+		}
+		m.encode(buf[i*pageSize:])
+		db.meta = m
+	}
+	encodeFreelist(buf[2*pageSize:], 2, 0, nil)
+	(&node{leaf: true}).encode(buf[3*pageSize:], 3, 0)
+
+	if _, err := db.file.WriteAt(buf, 0); err != nil {
+		return err
+	}
+	db.freelist = newFreelist()
+
+	return db.sync()
+}
+
+func (db *DB) load(pageSize int) error {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	buf := make([]byte, pageHeaderSize+metaSize)
+	var metas []meta
+	if _, err := db.file.ReadAt(buf, 0); err == nil {
+		if m, err := decodeMeta(buf); err == nil {
+			metas = append(metas, m)
+			pageSize = int(m.pageSize)
+		}
+	}
+	if _, err := db.file.ReadAt(buf, int64(pageSize)); err == nil {
+		if m, err := decodeMeta(buf); err == nil && int(m.pageSize) == pageSize {
+			metas = append(metas, m)
+		}
+	}
+	if len(metas) == 0 {
+		return errInvalidMeta
+	}
+
+	db.meta = metas[0]
+	for _, m := range metas[1:] {
+		if m.txid > db.meta.txid {
+			db.meta = m
+		}
+	}
+	db.pageSize = int(db.meta.pageSize)
+
+	page, err := db.readPage(db.meta.freelist)
+	if err != nil {
+		return err
+	}
+	ids, err := decodeFreelist(page)
+	if err != nil {
+		return err
+	}
+	db.freelist = newFreelist()
+	db.freelist.ids = ids
+
+	return nil
+}
+
+func (db *DB) Path() string {
+	return db.path
+}
+
+func (db *DB) Close() error {
+	db.writer.Lock()
+	defer db.writer.Unlock()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+
+	return db.file.Close()
+}
+
+// Begin starts a transaction. Read transactions observe the last committed
+// version until they are rolled back; there can be only one write
+// transaction at a time.
+func (db *DB) Begin(writable bool) (*Tx, error) {
+	if writable {
+		db.writer.Lock()
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		if writable {
+			db.writer.Unlock()
+		}
+		return nil, ErrDatabaseClosed
+	}
+
+	tx := &Tx{db: db, meta: db.meta, writable: writable}
+	if writable {
+		tx.meta.txid++
+		db.freelist.release(db.oldestReader())
+		tx.freelist = db.freelist.copy()
+	} else {
+		db.readers[tx.meta.txid]++
+	}
+
+	return tx, nil
+}
+
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) View(fn func(tx *Tx) error) error {
+	tx, err := db.Begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return fn(tx)
+}
+
+func (db *DB) Stats() Stats {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	readers := 0
+	for _, count := range db.readers {
+		readers += count
+	}
+
+	return Stats{
+		PageSize:  db.pageSize,
+		PageCount: int(db.meta.pgid),
+		FreePages: db.freelist.count(),
+		TxID:      db.meta.txid,
+		Readers:   readers,
+	}
+}
+
+// oldestReader returns the oldest version still observed by a reader. Must
+// be called with db.mu held.
+func (db *DB) oldestReader() uint64 {
+	oldest := db.meta.txid
+	for txid := range db.readers {
+		if txid < oldest {
+			oldest = txid
+		}
+	}
+
+	return oldest
+}
+
+func (db *DB) removeReader(txid uint64) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.readers[txid]--; db.readers[txid] <= 0 {
+		delete(db.readers, txid)
+	}
+}
+
+func (db *DB) readPage(id pgid) ([]byte, error) {
+	buf := make([]byte, db.pageSize)
+	if _, err := db.file.ReadAt(buf, int64(id)*int64(db.pageSize)); err != nil {
+		return nil, fmt.Errorf("failed to read page %d: %w", id, err)
+	}
+
+	header := decodePageHeader(buf)
+	if header.id != id {
+		return nil, fmt.Errorf("page %d has unexpected id %d", id, header.id)
+	}
+	if header.overflow > 0 {
+		full := make([]byte, db.pageSize*(int(header.overflow)+1))
+		copy(full, buf)
+		if _, err := db.file.ReadAt(full[db.pageSize:], int64(id+1)*int64(db.pageSize)); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read overflow of page %d: %w", id, err)
+		}
+		buf = full
+	}
+
+	return buf, nil
+}
+
+func (db *DB) node(id pgid) (*node, error) {
+	db.cacheMu.Lock()
+	n, ok := db.cache[id]
+	db.cacheMu.Unlock()
+	if ok {
+		return n, nil
+	}
+
+	page, err := db.readPage(id)
+	if err != nil {
+		return nil, err
+	}
+	n, err = decodeNode(page)
+	if err != nil {
+		return nil, fmt.Errorf("page %d: %w", id, err)
+	}
+
+	db.cacheNode(n)
+	return n, nil
+}
+
+func (db *DB) cacheNode(n *node) {
+	db.cacheMu.Lock()
+	defer db.cacheMu.Unlock()
+
+	if len(db.cache) >= db.cacheSize {
+		db.cache = map[pgid]*node{}
+	}
+	db.cache[n.pgid] = n
+}
+
+func (db *DB) writeAt(buf []byte, id pgid) error {
+	_, err := db.file.WriteAt(buf, int64(id)*int64(db.pageSize))
+	return err
+}
+
+func (db *DB) sync() error {
+	if db.noSync {
+		return nil
+	}
+
+	return db.file.Sync()
+}
@@ -0,0 +1,277 @@
+package btree
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), &Options{NoSync: true})
+	assert.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func put(t *testing.T, db *DB, key, value string) {
+	t.Helper()
+	err := db.Update(func(tx *Tx) error {
+		return tx.Put([]byte(key), []byte(value))
+	})
+	assert.Nil(t, err)
+}
+
+func get(t *testing.T, db *DB, key string) []byte {
+	t.Helper()
+	var value []byte
+	err := db.View(func(tx *Tx) error {
+		v, err := tx.Get([]byte(key))
+		value = v
+		return err
+	})
+	assert.Nil(t, err)
+
+	return value
+}
+
+func keys(t *testing.T, db *DB, from string) []string {
+	t.Helper()
+	var result []string
+	err := db.View(func(tx *Tx) error {
+		c := tx.Cursor()
+		for k, _ := c.Seek([]byte(from)); k != nil; k, _ = c.Next() {
+			result = append(result, string(k))
+		}
+		return c.Err()
+	})
+	assert.Nil(t, err)
+
+	return result
+}
+
+func TestDB_PutGetDelete(t *testing.T) {
+	t.Run("Should store and read values", func(t *testing.T) {
+		db := openTestDB(t)
+		put(t, db, "a", "1")
+		put(t, db, "b", "2")
+
+		assert.Equal(t, []byte("1"), get(t, db, "a"))
+		assert.Equal(t, []byte("2"), get(t, db, "b"))
+		assert.Nil(t, get(t, db, "c"))
+	})
+
+	t.Run("Should replace existing value", func(t *testing.T) {
+		db := openTestDB(t)
+		put(t, db, "a", "1")
+		put(t, db, "a", "2")
+
+		assert.Equal(t, []byte("2"), get(t, db, "a"))
+	})
+
+	t.Run("Should delete value", func(t *testing.T) {
+		db := openTestDB(t)
+		put(t, db, "a", "1")
+
+		var deleted bool
+		err := db.Update(func(tx *Tx) (err error) {
+			deleted, err = tx.Delete([]byte("a"))
+			return err
+		})
+		assert.Nil(t, err)
+		assert.True(t, deleted)
+		assert.Nil(t, get(t, db, "a"))
+	})
+
+	t.Run("Should not write in read transaction", func(t *testing.T) {
+		db := openTestDB(t)
+		err := db.View(func(tx *Tx) error {
+			return tx.Put([]byte("a"), []byte("1"))
+		})
+		assert.ErrorIs(t, err, ErrTxNotWritable)
+	})
+
+	t.Run("Should discard rolled back changes", func(t *testing.T) {
+		db := openTestDB(t)
+		err := db.Update(func(tx *Tx) error {
+			if err := tx.Put([]byte("a"), []byte("1")); err != nil {
+				return err
+			}
+			return fmt.Errorf("abort")
+		})
+		assert.Error(t, err)
+		assert.Nil(t, get(t, db, "a"))
+	})
+}
+
+func TestDB_ManyKeys(t *testing.T) {
+	db := openTestDB(t)
+	expected := make([]string, 0, 2000)
+	err := db.Update(func(tx *Tx) error {
+		for i := 0; i < 2000; i++ {
+			key := fmt.Sprintf("key-%05d", (i*7919)%2000)
+			expected = append(expected, key)
+			if err := tx.Put([]byte(key), []byte(fmt.Sprintf("value-%d", i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	sort.Strings(expected)
+
+	t.Run("Should scan keys in order", func(t *testing.T) {
+		assert.Equal(t, expected, keys(t, db, ""))
+	})
+
+	t.Run("Should scan from key", func(t *testing.T) {
+		assert.Equal(t, expected[1500:], keys(t, db, "key-01500"))
+	})
+
+	t.Run("Should delete and reuse pages", func(t *testing.T) {
+		err := db.Update(func(tx *Tx) error {
+			for _, key := range expected {
+				if _, err := tx.Delete([]byte(key)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Empty(t, keys(t, db, ""))
+
+		before := db.Stats().PageCount
+		for i := 0; i < 100; i++ {
+			put(t, db, fmt.Sprintf("again-%d", i), "value")
+		}
+		assert.Equal(t, before, db.Stats().PageCount, "file should not grow while free pages exist")
+	})
+}
+
+func TestDB_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	t.Run("Should persist data between opens", func(t *testing.T) {
+		db, err := Open(path, nil)
+		assert.Nil(t, err)
+		for i := 0; i < 500; i++ {
+			put(t, db, fmt.Sprintf("key-%03d", i), fmt.Sprintf("value-%d", i))
+		}
+		assert.Nil(t, db.Close())
+
+		db, err = Open(path, nil)
+		assert.Nil(t, err)
+		defer db.Close()
+		assert.Equal(t, []byte("value-42"), get(t, db, "key-042"))
+		assert.Len(t, keys(t, db, ""), 500)
+	})
+
+	t.Run("Should fall back to previous commit on torn meta page", func(t *testing.T) {
+		db, err := Open(path, nil)
+		assert.Nil(t, err)
+		put(t, db, "last", "value")
+		txid := db.Stats().TxID
+		pageSize := db.Stats().PageSize
+		assert.Nil(t, db.Close())
+
+		file, err := os.OpenFile(path, os.O_RDWR, 0644)
+		assert.Nil(t, err)
+		_, err = file.WriteAt([]byte("garbage"), int64(txid%2)*int64(pageSize)+pageHeaderSize+40)
+		assert.Nil(t, err)
+		assert.Nil(t, file.Close())
+
+		db, err = Open(path, nil)
+		assert.Nil(t, err)
+		defer db.Close()
+		assert.Equal(t, txid-1, db.Stats().TxID)
+		assert.Nil(t, get(t, db, "last"))
+		assert.Len(t, keys(t, db, ""), 500)
+	})
+}
+
+func TestDB_ReadIsolation(t *testing.T) {
+	db := openTestDB(t)
+	put(t, db, "a", "1")
+
+	tx, err := db.Begin(false)
+	assert.Nil(t, err)
+
+	put(t, db, "a", "2")
+	put(t, db, "b", "3")
+
+	value, err := tx.Get([]byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1"), value)
+	value, _ = tx.Get([]byte("b"))
+	assert.Nil(t, value)
+	assert.Nil(t, tx.Rollback())
+
+	assert.Equal(t, []byte("2"), get(t, db, "a"))
+}
+
+func TestDB_LargeValues(t *testing.T) {
+	db := openTestDB(t)
+	large := make([]byte, DefaultPageSize*3)
+	for i := range large {
+		large[i] = byte(i)
+	}
+
+	put(t, db, "large", string(large))
+	put(t, db, "small", "value")
+
+	assert.Equal(t, large, get(t, db, "large"))
+	assert.Equal(t, []byte("value"), get(t, db, "small"))
+}
+
+func TestDB_RandomOperations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path, &Options{NoSync: true, PageSize: 1024})
+	assert.Nil(t, err)
+
+	rnd := rand.New(rand.NewSource(1))
+	model := map[string]string{}
+	for round := 0; round < 50; round++ {
+		err := db.Update(func(tx *Tx) error {
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("key-%04d", rnd.Intn(1000))
+				if rnd.Intn(3) == 0 {
+					delete(model, key)
+					if _, err := tx.Delete([]byte(key)); err != nil {
+						return err
+					}
+					continue
+				}
+
+				value := strings.Repeat("x", rnd.Intn(300))
+				model[key] = value
+				if err := tx.Put([]byte(key), []byte(value)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+
+		if round%10 == 9 {
+			assert.Nil(t, db.Close())
+			db, err = Open(path, &Options{NoSync: true})
+			assert.Nil(t, err)
+		}
+	}
+	defer db.Close()
+
+	expected := make([]string, 0, len(model))
+	for key := range model {
+		expected = append(expected, key)
+		assert.Equal(t, model[key], string(get(t, db, key)))
+	}
+	sort.Strings(expected)
+	assert.Equal(t, expected, keys(t, db, ""))
+}
@@ -0,0 +1,86 @@
+package btree
+
+import (
+	"slices"
+)
+
+// freelist tracks pages that can be reused. Pages released by a write
+// transaction stay pending until no reader can still observe them.
+type freelist struct {
+	ids     []pgid
+	pending map[uint64][]pgid
+}
+
+func newFreelist() *freelist {
+	return &freelist{pending: map[uint64][]pgid{}}
+}
+
+func (f *freelist) copy() *freelist {
+	pending := make(map[uint64][]pgid, len(f.pending))
+	for txid, ids := range f.pending {
+		pending[txid] = append([]pgid(nil), ids...)
+	}
+
+	return &freelist{ids: append([]pgid(nil), f.ids...), pending: pending}
+}
+
+// allocate returns the first id of n contiguous free pages or 0 if there is
+// no such run.
+func (f *freelist) allocate(n int) pgid {
+	var start pgid
+	run := 0
+	for i, id := range f.ids {
+		if i > 0 && id == f.ids[i-1]+1 {
+			run++
+		} else {
+			start = id
+			run = 1
+		}
+
+		if run == n {
+			f.ids = append(f.ids[:i-n+1], f.ids[i+1:]...)
+			return start
+		}
+	}
+
+	return 0
+}
+
+func (f *freelist) free(txid uint64, id pgid, overflow uint32) {
+	for i := pgid(0); i <= pgid(overflow); i++ {
+		f.pending[txid] = append(f.pending[txid], id+i)
+	}
+}
+
+// release makes pages freed by transactions up to and including txid
+// available for allocation.
+func (f *freelist) release(txid uint64) {
+	for tx, ids := range f.pending {
+		if tx <= txid {
+			f.ids = append(f.ids, ids...)
+			delete(f.pending, tx)
+		}
+	}
+	slices.Sort(f.ids)
+}
+
+// all returns free and pending pages. After a restart there are no readers,
+// so everything pending is reusable.
+func (f *freelist) all() []pgid {
+	ids := append([]pgid(nil), f.ids...)
+	for _, pending := range f.pending {
+		ids = append(ids, pending...)
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
+func (f *freelist) count() int {
+	count := len(f.ids)
+	for _, ids := range f.pending {
+		count += len(ids)
+	}
+
+	return count
+}
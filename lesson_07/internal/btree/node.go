@@ -0,0 +1,206 @@
+package btree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+const minKeysPerNode = 2
+
+// inode is a single element of a node. Leaf elements carry a value, branch
+// elements point to a child page whose smallest key is `key`.
+type inode struct {
+	key   []byte
+	value []byte
+	pgid  pgid
+	child *node
+}
+
+// node is the in-memory form of a page. Nodes read by transactions are
+// immutable; a write transaction clones the nodes it changes and writes them
+// to freshly allocated pages on commit (copy-on-write).
+type node struct {
+	leaf     bool
+	pgid     pgid
+	overflow uint32
+	inodes   []inode
+}
+
+func (n *node) clone() *node {
+	return &node{
+		leaf:     n.leaf,
+		pgid:     n.pgid,
+		overflow: n.overflow,
+		inodes:   append([]inode(nil), n.inodes...),
+	}
+}
+
+// childIndex returns the index of the child subtree that may contain key.
+func (n *node) childIndex(key []byte) int {
+	i := sort.Search(len(n.inodes), func(i int) bool {
+		return bytes.Compare(n.inodes[i].key, key) > 0
+	})
+	if i > 0 {
+		i--
+	}
+
+	return i
+}
+
+// leafIndex returns the position of the first element that is >= key.
+func (n *node) leafIndex(key []byte) (int, bool) {
+	i := sort.Search(len(n.inodes), func(i int) bool {
+		return bytes.Compare(n.inodes[i].key, key) >= 0
+	})
+
+	return i, i < len(n.inodes) && bytes.Equal(n.inodes[i].key, key)
+}
+
+func (n *node) put(key, value []byte) {
+	i, found := n.leafIndex(key)
+	if found {
+		n.inodes[i].value = value
+		return
+	}
+
+	n.inodes = append(n.inodes, inode{})
+	copy(n.inodes[i+1:], n.inodes[i:])
+	n.inodes[i] = inode{key: key, value: value}
+}
+
+func (n *node) del(key []byte) bool {
+	i, found := n.leafIndex(key)
+	if !found {
+		return false
+	}
+
+	n.inodes = append(n.inodes[:i], n.inodes[i+1:]...)
+	return true
+}
+
+func (n *node) elementSize(in inode) int {
+	size := uvarintSize(len(in.key)) + len(in.key)
+	if n.leaf {
+		return size + uvarintSize(len(in.value)) + len(in.value)
+	}
+
+	return size + 8
+}
+
+func (n *node) size() int {
+	size := pageHeaderSize
+	for _, in := range n.inodes {
+		size += n.elementSize(in)
+	}
+
+	return size
+}
+
+// split breaks the node into nodes that fit into threshold bytes each. A
+// single oversized element is kept in its own node and spans overflow pages.
+func (n *node) split(threshold int) []*node {
+	if len(n.inodes) <= minKeysPerNode*2 || n.size() <= threshold {
+		return []*node{n}
+	}
+
+	var parts []*node
+	current := &node{leaf: n.leaf}
+	size := pageHeaderSize
+	for i, in := range n.inodes {
+		elementSize := n.elementSize(in)
+		remaining := len(n.inodes) - i
+		if len(current.inodes) >= minKeysPerNode && remaining >= minKeysPerNode && size+elementSize > threshold {
+			parts = append(parts, current)
+			current = &node{leaf: n.leaf}
+			size = pageHeaderSize
+		}
+		current.inodes = append(current.inodes, in)
+		size += elementSize
+	}
+
+	return append(parts, current)
+}
+
+func (n *node) encode(buf []byte, id pgid, overflow uint32) {
+	flags := branchPageFlag
+	if n.leaf {
+		flags = leafPageFlag
+	}
+	pageHeader{flags: flags, count: uint16(len(n.inodes)), overflow: overflow, id: id}.encode(buf)
+
+	pos := pageHeaderSize
+	for _, in := range n.inodes {
+		pos += binary.PutUvarint(buf[pos:], uint64(len(in.key)))
+		pos += copy(buf[pos:], in.key)
+		if n.leaf {
+			pos += binary.PutUvarint(buf[pos:], uint64(len(in.value)))
+			pos += copy(buf[pos:], in.value)
+		} else {
+			binary.LittleEndian.PutUint64(buf[pos:], uint64(in.pgid))
+			pos += 8
+		}
+	}
+}
+
+var errCorruptNode = errors.New("corrupt node page")
+
+func decodeNode(buf []byte) (*node, error) {
+	header := decodePageHeader(buf)
+	if header.flags != branchPageFlag && header.flags != leafPageFlag {
+		return nil, errCorruptNode
+	}
+
+	n := &node{
+		leaf:     header.flags == leafPageFlag,
+		pgid:     header.id,
+		overflow: header.overflow,
+		inodes:   make([]inode, header.count),
+	}
+
+	pos := pageHeaderSize
+	readBytes := func() ([]byte, bool) {
+		length, read := binary.Uvarint(buf[pos:])
+		if read <= 0 || uint64(len(buf)-pos-read) < length {
+			return nil, false
+		}
+		pos += read
+		data := buf[pos : pos+int(length) : pos+int(length)]
+		pos += int(length)
+		return data, true
+	}
+
+	for i := range n.inodes {
+		key, ok := readBytes()
+		if !ok {
+			return nil, errCorruptNode
+		}
+		n.inodes[i].key = key
+
+		if n.leaf {
+			value, ok := readBytes()
+			if !ok {
+				return nil, errCorruptNode
+			}
+			n.inodes[i].value = value
+		} else {
+			if len(buf)-pos < 8 {
+				return nil, errCorruptNode
+			}
+			n.inodes[i].pgid = pgid(binary.LittleEndian.Uint64(buf[pos:]))
+			pos += 8
+		}
+	}
+
+	return n, nil
+}
+
+func uvarintSize(v int) int {
+	size := 1
+	for x := uint64(v); x >= 0x80; x >>= 7 {
+		size++
+	}
+
+	return size
+}
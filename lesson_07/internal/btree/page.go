@@ -0,0 +1,153 @@
+package btree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+const (
+	pageHeaderSize = 16
+	metaSize       = 56
+
+	magic   uint32 = 0x42545245
+	version uint32 = 1
+)
+
+const (
+	branchPageFlag   uint16 = 0x01
+	leafPageFlag     uint16 = 0x02
+	metaPageFlag     uint16 = 0x04
+	freelistPageFlag uint16 = 0x10
+)
+
+var errInvalidMeta = errors.New("invalid meta page")
+
+type pgid uint64
+
+// pageHeader is stored at the beginning of every page. A node that does not fit
+// into a single page spans `overflow` additional contiguous pages.
+type pageHeader struct {
+	flags    uint16
+	count    uint16
+	overflow uint32
+	id       pgid
+}
+
+func (h pageHeader) encode(buf []byte) {
+	binary.LittleEndian.PutUint16(buf[0:], h.flags)
+	binary.LittleEndian.PutUint16(buf[2:], h.count)
+	binary.LittleEndian.PutUint32(buf[4:], h.overflow)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(h.id))
+}
+
+func decodePageHeader(buf []byte) pageHeader {
+	return pageHeader{
+		flags:    binary.LittleEndian.Uint16(buf[0:]),
+		count:    binary.LittleEndian.Uint16(buf[2:]),
+		overflow: binary.LittleEndian.Uint32(buf[4:]),
+		id:       pgid(binary.LittleEndian.Uint64(buf[8:])),
+	}
+}
+
+// meta describes a committed version of the database. Two meta pages are
+// kept and written alternately, so a torn meta write falls back to the
+// previous commit.
+type meta struct {
+	magic    uint32
+	version  uint32
+	pageSize uint32
+	root     pgid
+	freelist pgid
+	pgid     pgid
+	txid     uint64
+	checksum uint64
+}
+
+func (m *meta) encode(buf []byte) {
+	pageHeader{flags: metaPageFlag, id: pgid(m.txid % 2)}.encode(buf)
+	body := buf[pageHeaderSize:]
+	binary.LittleEndian.PutUint32(body[0:], m.magic)
+	binary.LittleEndian.PutUint32(body[4:], m.version)
+	binary.LittleEndian.PutUint32(body[8:], m.pageSize)
+	binary.LittleEndian.PutUint64(body[16:], uint64(m.root))
+	binary.LittleEndian.PutUint64(body[24:], uint64(m.freelist))
+	binary.LittleEndian.PutUint64(body[32:], uint64(m.pgid))
+	binary.LittleEndian.PutUint64(body[40:], m.txid)
+	m.checksum = metaChecksum(body[:48])
+	binary.LittleEndian.PutUint64(body[48:], m.checksum)
+}
+
+func decodeMeta(buf []byte) (meta, error) {
+	if len(buf) < pageHeaderSize+metaSize {
+		return meta{}, errInvalidMeta
+	}
+	if decodePageHeader(buf).flags != metaPageFlag {
+		return meta{}, errInvalidMeta
+	}
+
+	body := buf[pageHeaderSize:]
+	m := meta{
+		magic:    binary.LittleEndian.Uint32(body[0:]),
+		version:  binary.LittleEndian.Uint32(body[4:]),
+		pageSize: binary.LittleEndian.Uint32(body[8:]),
+		root:     pgid(binary.LittleEndian.Uint64(body[16:])),
+		freelist: pgid(binary.LittleEndian.Uint64(body[24:])),
+		pgid:     pgid(binary.LittleEndian.Uint64(body[32:])),
+		txid:     binary.LittleEndian.Uint64(body[40:]),
+		checksum: binary.LittleEndian.Uint64(body[48:]),
+	}
+
+	if m.magic != magic {
+		return meta{}, fmt.Errorf("%w: bad magic", errInvalidMeta)
+	}
+	if m.version != version {
+		return meta{}, fmt.Errorf("%w: unsupported version %d", errInvalidMeta, m.version)
+	}
+	if m.checksum != metaChecksum(body[:48]) {
+		return meta{}, fmt.Errorf("%w: checksum mismatch", errInvalidMeta)
+	}
+
+	return m, nil
+}
+
+func metaChecksum(data []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64()
+}
+
+// encodeFreelist stores page ids after the header. The header count field is
+// too small for large lists, so the real count is written as the first word.
+func encodeFreelist(buf []byte, id pgid, overflow uint32, ids []pgid) {
+	pageHeader{flags: freelistPageFlag, overflow: overflow, id: id}.encode(buf)
+	body := buf[pageHeaderSize:]
+	binary.LittleEndian.PutUint64(body, uint64(len(ids)))
+	for i, free := range ids {
+		binary.LittleEndian.PutUint64(body[8+i*8:], uint64(free))
+	}
+}
+
+func decodeFreelist(buf []byte) ([]pgid, error) {
+	if decodePageHeader(buf).flags != freelistPageFlag {
+		return nil, errors.New("page is not a freelist")
+	}
+
+	body := buf[pageHeaderSize:]
+	count := binary.LittleEndian.Uint64(body)
+	if uint64(len(body)-8)/8 < count {
+		return nil, errors.New("truncated freelist page")
+	}
+
+	ids := make([]pgid, count)
+	for i := range ids {
+		ids[i] = pgid(binary.LittleEndian.Uint64(body[8+i*8:]))
+	}
+
+	return ids, nil
+}
+
+func freelistSize(count int) int {
+	return pageHeaderSize + 8 + count*8
+}
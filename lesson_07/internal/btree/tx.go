@@ -0,0 +1,416 @@
+package btree
+
+import (
+	"bytes"
+)
+
+// Tx is a read-only or read-write transaction. Keys and values returned by a
+// transaction must not be modified by the caller.
+type Tx struct {
+	db       *DB
+	meta     meta
+	writable bool
+	closed   bool
+
+	root     *node
+	freelist *freelist
+	freed    []*node
+}
+
+func (tx *Tx) Writable() bool {
+	return tx.writable
+}
+
+// ID returns the version observed by a read transaction or the version
+// produced by a write transaction.
+func (tx *Tx) ID() uint64 {
+	return tx.meta.txid
+}
+
+func (tx *Tx) Get(key []byte) ([]byte, error) {
+	if tx.closed {
+		return nil, ErrTxClosed
+	}
+
+	n, err := tx.rootNode(false)
+	if err != nil {
+		return nil, err
+	}
+	for !n.leaf {
+		if n, err = tx.child(n, n.childIndex(key), false); err != nil {
+			return nil, err
+		}
+	}
+
+	if i, found := n.leafIndex(key); found {
+		return n.inodes[i].value, nil
+	}
+
+	return nil, nil
+}
+
+func (tx *Tx) Put(key, value []byte) error {
+	if err := tx.checkWritable(); err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return ErrKeyRequired
+	}
+
+	n, err := tx.leafFor(key)
+	if err != nil {
+		return err
+	}
+	n.put(bytes.Clone(key), bytes.Clone(value))
+
+	return nil
+}
+
+// Delete removes key and reports whether it existed.
+func (tx *Tx) Delete(key []byte) (bool, error) {
+	if err := tx.checkWritable(); err != nil {
+		return false, err
+	}
+
+	if value, err := tx.Get(key); err != nil || value == nil {
+		return false, err
+	}
+
+	n, err := tx.leafFor(key)
+	if err != nil {
+		return false, err
+	}
+
+	return n.del(key), nil
+}
+
+func (tx *Tx) Cursor() *Cursor {
+	return &Cursor{tx: tx}
+}
+
+func (tx *Tx) Rollback() error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+	tx.close()
+
+	return nil
+}
+
+// Commit writes changed nodes to new pages, persists the free list and
+// publishes the new root through the meta page.
+func (tx *Tx) Commit() error {
+	if err := tx.checkWritable(); err != nil {
+		return err
+	}
+	defer tx.close()
+
+	db := tx.db
+	if tx.root != nil {
+		root, err := tx.commitTree()
+		if err != nil {
+			return err
+		}
+		tx.meta.root = root
+	}
+
+	for _, n := range tx.freed {
+		tx.freelist.free(tx.meta.txid, n.pgid, n.overflow)
+	}
+
+	if err := tx.writeFreelist(); err != nil {
+		return err
+	}
+	if err := db.sync(); err != nil {
+		return err
+	}
+
+	buf := make([]byte, db.pageSize)
+	tx.meta.encode(buf)
+	if err := db.writeAt(buf, pgid(tx.meta.txid%2)); err != nil {
+		return err
+	}
+	if err := db.sync(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	db.meta = tx.meta
+	db.freelist = tx.freelist
+	db.mu.Unlock()
+
+	return nil
+}
+
+func (tx *Tx) close() {
+	if tx.closed {
+		return
+	}
+	tx.closed = true
+
+	if tx.writable {
+		tx.db.writer.Unlock()
+	} else {
+		tx.db.removeReader(tx.meta.txid)
+	}
+}
+
+func (tx *Tx) checkWritable() error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+	if !tx.writable {
+		return ErrTxNotWritable
+	}
+
+	return nil
+}
+
+// rootNode returns the root node. With materialize set, the root is cloned
+// so that it can be modified by the write transaction.
+func (tx *Tx) rootNode(materialize bool) (*node, error) {
+	if tx.root != nil {
+		return tx.root, nil
+	}
+
+	n, err := tx.db.node(tx.meta.root)
+	if err != nil {
+		return nil, err
+	}
+	if materialize {
+		tx.root = tx.own(n)
+		return tx.root, nil
+	}
+
+	return n, nil
+}
+
+// child returns the i-th child of a branch node. With materialize set, the
+// child is cloned and attached to its parent.
+func (tx *Tx) child(parent *node, i int, materialize bool) (*node, error) {
+	if c := parent.inodes[i].child; c != nil {
+		return c, nil
+	}
+
+	n, err := tx.db.node(parent.inodes[i].pgid)
+	if err != nil {
+		return nil, err
+	}
+	if materialize {
+		n = tx.own(n)
+		parent.inodes[i].child = n
+	}
+
+	return n, nil
+}
+
+// own clones a committed node for modification and schedules its pages to
+// be freed when the transaction commits.
+func (tx *Tx) own(n *node) *node {
+	tx.freed = append(tx.freed, n)
+	return n.clone()
+}
+
+func (tx *Tx) leafFor(key []byte) (*node, error) {
+	n, err := tx.rootNode(true)
+	if err != nil {
+		return nil, err
+	}
+	for !n.leaf {
+		if n, err = tx.child(n, n.childIndex(key), true); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+func (tx *Tx) commitTree() (pgid, error) {
+	root := tx.root
+	if err := tx.rebalance(root); err != nil {
+		return 0, err
+	}
+
+	for !root.leaf && len(root.inodes) == 1 {
+		child, err := tx.child(root, 0, true)
+		if err != nil {
+			return 0, err
+		}
+		root = child
+	}
+	if !root.leaf && len(root.inodes) == 0 {
+		root = &node{leaf: true}
+	}
+
+	for {
+		parts, err := tx.spill(root)
+		if err != nil {
+			return 0, err
+		}
+		if len(parts) == 0 {
+			root = &node{leaf: true}
+			if err := tx.write(root); err != nil {
+				return 0, err
+			}
+			return root.pgid, nil
+		}
+		if len(parts) == 1 {
+			return parts[0].pgid, nil
+		}
+
+		root = &node{}
+		for _, part := range parts {
+			root.inodes = append(root.inodes, inode{key: part.inodes[0].key, pgid: part.pgid})
+		}
+	}
+}
+
+func (tx *Tx) underfilled(n *node) bool {
+	minKeys := 1
+	if !n.leaf {
+		minKeys = minKeysPerNode
+	}
+
+	return len(n.inodes) < minKeys || n.size() < tx.db.pageSize/4
+}
+
+// rebalance merges underfilled children of modified branch nodes with their
+// siblings and drops empty children.
+func (tx *Tx) rebalance(n *node) error {
+	if n.leaf {
+		return nil
+	}
+
+	for i := range n.inodes {
+		if c := n.inodes[i].child; c != nil {
+			if err := tx.rebalance(c); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := 0; i < len(n.inodes); {
+		c := n.inodes[i].child
+		if c == nil || !tx.underfilled(c) {
+			i++
+			continue
+		}
+
+		if len(c.inodes) == 0 {
+			n.inodes = append(n.inodes[:i], n.inodes[i+1:]...)
+			continue
+		}
+		if len(n.inodes) == 1 {
+			break
+		}
+
+		left, right := i, i+1
+		if right == len(n.inodes) {
+			left, right = i-1, i
+		}
+		leftNode, err := tx.child(n, left, true)
+		if err != nil {
+			return err
+		}
+		rightNode, err := tx.child(n, right, true)
+		if err != nil {
+			return err
+		}
+
+		leftNode.inodes = append(leftNode.inodes, rightNode.inodes...)
+		n.inodes = append(n.inodes[:right], n.inodes[right+1:]...)
+		i = left + 1
+	}
+
+	return nil
+}
+
+// spill writes a modified subtree to new pages and returns the nodes it was
+// split into.
+func (tx *Tx) spill(n *node) ([]*node, error) {
+	if !n.leaf {
+		inodes := make([]inode, 0, len(n.inodes))
+		for _, in := range n.inodes {
+			if in.child == nil {
+				inodes = append(inodes, in)
+				continue
+			}
+
+			parts, err := tx.spill(in.child)
+			if err != nil {
+				return nil, err
+			}
+			for _, part := range parts {
+				inodes = append(inodes, inode{key: part.inodes[0].key, pgid: part.pgid})
+			}
+		}
+		n.inodes = inodes
+	}
+	if len(n.inodes) == 0 {
+		return nil, nil
+	}
+
+	parts := n.split(tx.db.pageSize / 2)
+	for _, part := range parts {
+		if err := tx.write(part); err != nil {
+			return nil, err
+		}
+	}
+
+	return parts, nil
+}
+
+func (tx *Tx) write(n *node) error {
+	size := n.size()
+	count := (size + tx.db.pageSize - 1) / tx.db.pageSize
+	id := tx.allocate(count)
+
+	buf := make([]byte, count*tx.db.pageSize)
+	n.encode(buf, id, uint32(count-1))
+	if err := tx.db.writeAt(buf, id); err != nil {
+		return err
+	}
+
+	n.pgid = id
+	n.overflow = uint32(count - 1)
+	for i := range n.inodes {
+		n.inodes[i].child = nil
+	}
+	tx.db.cacheNode(n)
+
+	return nil
+}
+
+func (tx *Tx) allocate(count int) pgid {
+	if id := tx.freelist.allocate(count); id != 0 {
+		return id
+	}
+
+	id := tx.meta.pgid
+	tx.meta.pgid += pgid(count)
+	return id
+}
+
+func (tx *Tx) writeFreelist() error {
+	old, err := tx.db.readPage(tx.meta.freelist)
+	if err != nil {
+		return err
+	}
+	oldHeader := decodePageHeader(old)
+	tx.freelist.free(tx.meta.txid, oldHeader.id, oldHeader.overflow)
+
+	// Allocating the freelist page shrinks the list, so the size computed
+	// before the allocation is always enough.
+	count := (freelistSize(tx.freelist.count()) + tx.db.pageSize - 1) / tx.db.pageSize
+	id := tx.allocate(count)
+	ids := tx.freelist.all()
+
+	buf := make([]byte, count*tx.db.pageSize)
+	encodeFreelist(buf, id, uint32(count-1), ids)
+	if err := tx.db.writeAt(buf, id); err != nil {
+		return err
+	}
+	tx.meta.freelist = id
+
+	return nil
+}
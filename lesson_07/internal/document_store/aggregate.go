@@ -0,0 +1,513 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrInvalidStage = errors.New("invalid aggregation stage")
+
+// Stage is a step of an aggregation pipeline. Stages receive the documents
+// produced by the previous stage.
+type Stage interface {
+	apply(p *pipeline, docs []Document) ([]Document, error)
+}
+
+// pipeline carries what stages need besides their input.
+type pipeline struct {
+	ctx context.Context
+	// collection resolves collections for Lookup; nil outside of a store.
+	collection func(name string) (*Collection, bool)
+	// source is the aggregated collection.
+	source *Collection
+	// plan is how source is read, set by runPipeline.
+	plan *QueryPlan
+}
+
+// Aggregate runs stages over the documents of the collection. Lookup stages
+// need a store; use Store.Aggregate for them.
+func (s *Collection) Aggregate(stages ...Stage) ([]Document, error) {
+	return s.AggregateContext(context.Background(), stages...)
+}
+
+// AggregateContext is Aggregate that gives up when ctx is done.
+func (s *Collection) AggregateContext(ctx context.Context, stages ...Stage) (_ []Document, err error) {
+	collectionLogger().DebugContext(ctx, "Aggregate collection", "stages", len(stages))
+	ctx, o := s.startOperation(ctx, OpAggregate)
+	defer o.end(&err)
+	p := &pipeline{ctx: ctx, source: s}
+	docs, err := runPipeline(p, stages)
+	o.query(p.plan)
+	o.returned(len(docs))
+
+	return docs, err
+}
+
+// Aggregate runs stages over the documents of the named collection. Lookup
+// stages may join any collection of the store.
+func (s *Store) Aggregate(collection string, stages ...Stage) ([]Document, error) {
+	return s.AggregateContext(context.Background(), collection, stages...)
+}
+
+// AggregateContext is Aggregate that gives up when ctx is done.
+func (s *Store) AggregateContext(ctx context.Context, collection string, stages ...Stage) (_ []Document, err error) {
+	storeLogger().DebugContext(ctx, "Aggregate store collection", "collection", collection, "stages", len(stages))
+	source, ok := s.GetCollection(collection)
+	if !ok {
+		return nil, newError(collection, "", "", ErrCollectionNotFound)
+	}
+	ctx, o := source.startOperation(ctx, OpAggregate)
+	defer o.end(&err)
+
+	p := &pipeline{ctx: ctx, collection: s.GetCollection, source: source}
+	docs, err := runPipeline(p, stages)
+	o.query(p.plan)
+	o.returned(len(docs))
+
+	return docs, err
+}
+
+func runPipeline(p *pipeline, stages []Stage) ([]Document, error) {
+	if err := p.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p.plan = p.source.planQuery(stages)
+	docs, err := p.source.readPlan(p.ctx, p.plan)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, stage := range stages {
+		if err := p.ctx.Err(); err != nil {
+			return nil, err
+		}
+		if docs, err = stage.apply(p, docs); err != nil {
+			return nil, fmt.Errorf("stage %d: %w", i, err)
+		}
+	}
+	p.plan.Returned = len(docs)
+
+	return docs, nil
+}
+
+type matchStage struct {
+	filter Filter
+}
+
+// Match keeps documents matching the filter.
+func Match(filter Filter) Stage {
+	return matchStage{filter: filter}
+}
+
+func (s matchStage) apply(_ *pipeline, docs []Document) ([]Document, error) {
+	matched := make([]Document, 0, len(docs))
+	for _, doc := range docs {
+		if s.filter.Match(doc) {
+			matched = append(matched, doc)
+		}
+	}
+
+	return matched, nil
+}
+
+type groupStage struct {
+	by           []string
+	accumulators map[string]Accumulator
+}
+
+// Group produces a document per distinct combination of the by fields, in
+// order of first appearance. Result documents hold the by fields, named by
+// their paths, and a field per accumulator. Without by fields all documents
+// form a single group.
+func Group(by []string, accumulators map[string]Accumulator) Stage {
+	return groupStage{by: by, accumulators: accumulators}
+}
+
+func (s groupStage) apply(_ *pipeline, docs []Document) ([]Document, error) {
+	for name := range s.accumulators {
+		if slices.Contains(s.by, name) {
+			return nil, fmt.Errorf("%w: accumulator %q shadows a group field", ErrInvalidStage, name)
+		}
+	}
+
+	type group struct {
+		key    []interface{}
+		states map[string]accumulatorState
+	}
+	var groups []*group
+	index := map[string]*group{}
+
+	for _, doc := range docs {
+		key := make([]interface{}, len(s.by))
+		for i, path := range s.by {
+			key[i], _ = lookupPath(doc, path)
+		}
+
+		g, ok := index[valueKey(key)]
+		if !ok {
+			g = &group{key: key, states: make(map[string]accumulatorState, len(s.accumulators))}
+			for name, accumulator := range s.accumulators {
+				g.states[name] = accumulator.start()
+			}
+			index[valueKey(key)] = g
+			groups = append(groups, g)
+		}
+		for _, state := range g.states {
+			state.add(doc)
+		}
+	}
+
+	// An empty input still forms the single group of a total.
+	if len(s.by) == 0 && len(groups) == 0 {
+		g := &group{states: map[string]accumulatorState{}}
+		for name, accumulator := range s.accumulators {
+			g.states[name] = accumulator.start()
+		}
+		groups = append(groups, g)
+	}
+
+	results := make([]Document, 0, len(groups))
+	for _, g := range groups {
+		doc := Document{Fields: map[string]DocumentField{}}
+		for i, path := range s.by {
+			if g.key[i] != nil {
+				doc.Fields[path] = newField(g.key[i])
+			}
+		}
+		for name, state := range g.states {
+			if value := state.result(); value != nil {
+				doc.Fields[name] = newField(value)
+			}
+		}
+		results = append(results, doc)
+	}
+
+	return results, nil
+}
+
+// Accumulator computes a value over the documents of a group.
+type Accumulator interface {
+	start() accumulatorState
+}
+
+type accumulatorState interface {
+	add(doc Document)
+	// result returns nil when there is nothing to report, e.g. the average
+	// of no numbers.
+	result() interface{}
+}
+
+type accumulatorFunc func() accumulatorState
+
+func (f accumulatorFunc) start() accumulatorState {
+	return f()
+}
+
+// Count counts documents.
+func Count() Accumulator {
+	return accumulatorFunc(func() accumulatorState { return &countState{} })
+}
+
+// Sum adds up numeric values of a field.
+func Sum(field string) Accumulator {
+	return accumulatorFunc(func() accumulatorState { return &sumState{field: field} })
+}
+
+// Avg averages numeric values of a field.
+func Avg(field string) Accumulator {
+	return accumulatorFunc(func() accumulatorState { return &sumState{field: field, average: true} })
+}
+
+// Min takes the smallest value of a field.
+func Min(field string) Accumulator {
+	return accumulatorFunc(func() accumulatorState { return &extremeState{field: field, sign: -1} })
+}
+
+// Max takes the largest value of a field.
+func Max(field string) Accumulator {
+	return accumulatorFunc(func() accumulatorState { return &extremeState{field: field, sign: 1} })
+}
+
+// Distinct collects unique values of a field in order of first appearance.
+func Distinct(field string) Accumulator {
+	return accumulatorFunc(func() accumulatorState { return &pushState{field: field, seen: map[string]bool{}} })
+}
+
+// Push collects all values of a field.
+func Push(field string) Accumulator {
+	return accumulatorFunc(func() accumulatorState { return &pushState{field: field} })
+}
+
+type countState struct {
+	count int
+}
+
+func (s *countState) add(Document) {
+	s.count++
+}
+
+func (s *countState) result() interface{} {
+	return s.count
+}
+
+type sumState struct {
+	field   string
+	average bool
+	sum     float64
+	count   int
+}
+
+func (s *sumState) add(doc Document) {
+	value, _ := lookupPath(doc, s.field)
+	if n, ok := toNumber(value); ok {
+		s.sum += n
+		s.count++
+	}
+}
+
+func (s *sumState) result() interface{} {
+	if !s.average {
+		return s.sum
+	}
+	if s.count == 0 {
+		return nil
+	}
+
+	return s.sum / float64(s.count)
+}
+
+type extremeState struct {
+	field string
+	sign  int
+	value interface{}
+}
+
+func (s *extremeState) add(doc Document) {
+	value, ok := lookupPath(doc, s.field)
+	if !ok || value == nil {
+		return
+	}
+	if s.value == nil || compareValues(value, s.value)*s.sign > 0 {
+		s.value = value
+	}
+}
+
+func (s *extremeState) result() interface{} {
+	return s.value
+}
+
+type pushState struct {
+	field  string
+	seen   map[string]bool
+	values []interface{}
+}
+
+func (s *pushState) add(doc Document) {
+	value, ok := lookupPath(doc, s.field)
+	if !ok {
+		return
+	}
+	if s.seen != nil {
+		if s.seen[valueKey(value)] {
+			return
+		}
+		s.seen[valueKey(value)] = true
+	}
+	s.values = append(s.values, value)
+}
+
+func (s *pushState) result() interface{} {
+	if s.values == nil {
+		return []interface{}{}
+	}
+
+	return s.values
+}
+
+type projectStage struct {
+	fields map[string]string
+}
+
+// Project reshapes documents: every result field takes the value at the
+// mapped source path. Missing values are left out.
+func Project(fields map[string]string) Stage {
+	return projectStage{fields: fields}
+}
+
+func (s projectStage) apply(_ *pipeline, docs []Document) ([]Document, error) {
+	projected := make([]Document, len(docs))
+	for i, doc := range docs {
+		result := Document{Fields: make(map[string]DocumentField, len(s.fields)), ExpiresAt: doc.ExpiresAt}
+		for name, path := range s.fields {
+			if value, ok := lookupPath(doc, path); ok {
+				result.Fields[name] = newField(value)
+			}
+		}
+		projected[i] = result
+	}
+
+	return projected, nil
+}
+
+// SortKey orders documents by a field.
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+func Asc(field string) SortKey {
+	return SortKey{Field: field}
+}
+
+func Desc(field string) SortKey {
+	return SortKey{Field: field, Descending: true}
+}
+
+type sortStage struct {
+	keys []SortKey
+}
+
+// Sort orders documents by the keys; documents equal on all keys keep their
+// order. Missing values sort first.
+func Sort(keys ...SortKey) Stage {
+	return sortStage{keys: keys}
+}
+
+func (s sortStage) apply(_ *pipeline, docs []Document) ([]Document, error) {
+	sorted := slices.Clone(docs)
+	slices.SortStableFunc(sorted, func(a, b Document) int {
+		for _, key := range s.keys {
+			x, _ := lookupPath(a, key.Field)
+			y, _ := lookupPath(b, key.Field)
+			if cmp := compareValues(x, y); cmp != 0 {
+				if key.Descending {
+					return -cmp
+				}
+				return cmp
+			}
+		}
+		return 0
+	})
+
+	return sorted, nil
+}
+
+type skipStage struct {
+	n int
+}
+
+// Skip drops the first n documents.
+func Skip(n int) Stage {
+	return skipStage{n: n}
+}
+
+func (s skipStage) apply(_ *pipeline, docs []Document) ([]Document, error) {
+	if s.n < 0 {
+		return nil, fmt.Errorf("%w: negative skip %d", ErrInvalidStage, s.n)
+	}
+
+	return docs[min(s.n, len(docs)):], nil
+}
+
+type limitStage struct {
+	n int
+}
+
+// Limit keeps the first n documents.
+func Limit(n int) Stage {
+	return limitStage{n: n}
+}
+
+func (s limitStage) apply(_ *pipeline, docs []Document) ([]Document, error) {
+	if s.n < 0 {
+		return nil, fmt.Errorf("%w: negative limit %d", ErrInvalidStage, s.n)
+	}
+
+	return docs[:min(s.n, len(docs))], nil
+}
+
+type unwindStage struct {
+	field string
+}
+
+// Unwind outputs a document per element of an array field, with the field
+// set to the element. Documents without elements are dropped.
+func Unwind(field string) Stage {
+	return unwindStage{field: field}
+}
+
+func (s unwindStage) apply(_ *pipeline, docs []Document) ([]Document, error) {
+	var unwound []Document
+	for _, doc := range docs {
+		value, _ := lookupPath(doc, s.field)
+		elements, ok := normalizeContainer(value).([]interface{})
+		if !ok {
+			continue
+		}
+		for _, element := range elements {
+			if element == nil {
+				continue
+			}
+			if result, ok := setPath(doc, s.field, element); ok {
+				unwound = append(unwound, result)
+			}
+		}
+	}
+
+	return unwound, nil
+}
+
+type lookupStage struct {
+	from         string
+	localField   string
+	foreignField string
+	as           string
+}
+
+// Lookup joins documents of another collection of the store whose
+// foreignField equals localField. Matches are stored in the as field as an
+// array of objects.
+func Lookup(from string, localField string, foreignField string, as string) Stage {
+	return lookupStage{from: from, localField: localField, foreignField: foreignField, as: as}
+}
+
+func (s lookupStage) apply(p *pipeline, docs []Document) ([]Document, error) {
+	if p.collection == nil {
+		return nil, fmt.Errorf("%w: lookup requires a store", ErrInvalidStage)
+	}
+	from, ok := p.collection(s.from)
+	if !ok {
+		return nil, newError(s.from, "", "", ErrCollectionNotFound)
+	}
+
+	fromDocs, err := from.list(p.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	foreign := map[string][]interface{}{}
+	for _, doc := range fromDocs {
+		if value, ok := lookupPath(doc, s.foreignField); ok {
+			key := valueKey(value)
+			foreign[key] = append(foreign[key], documentValues(doc))
+		}
+	}
+
+	joined := make([]Document, 0, len(docs))
+	for _, doc := range docs {
+		matches := []interface{}{}
+		if value, ok := lookupPath(doc, s.localField); ok {
+			matches = append(matches, foreign[valueKey(value)]...)
+		}
+		result, ok := setPath(doc, s.as, matches)
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot set %q", ErrInvalidStage, s.as)
+		}
+		joined = append(joined, result)
+	}
+
+	return joined, nil
+}
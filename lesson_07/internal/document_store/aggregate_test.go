@@ -0,0 +1,158 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func orderDocument(id string, customer string, total float64, items ...string) Document {
+	tags := make([]interface{}, len(items))
+	for i, item := range items {
+		tags[i] = item
+	}
+
+	return Document{
+		Fields: map[string]DocumentField{
+			"id":       {Type: DocumentFieldTypeString, Value: id},
+			"customer": {Type: DocumentFieldTypeString, Value: customer},
+			"total":    {Type: DocumentFieldTypeNumber, Value: total},
+			"items":    {Type: DocumentFieldTypeArray, Value: tags},
+		},
+	}
+}
+
+func newOrdersStore(t *testing.T) *Store {
+	store := NewStore()
+	orders, _ := store.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	orders.Put(orderDocument("1", "john", 10, "apple", "pear"))
+	orders.Put(orderDocument("2", "jane", 25, "apple"))
+	orders.Put(orderDocument("3", "john", 5))
+	orders.Put(orderDocument("4", "bob", 40, "plum", "apple"))
+
+	users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	users.Put(userDocument("john", "John"))
+	users.Put(userDocument("jane", "Jane"))
+
+	return store
+}
+
+func TestCollection_Aggregate(t *testing.T) {
+	t.Run("Should group with accumulators", func(t *testing.T) {
+		store := newOrdersStore(t)
+		orders, _ := store.GetCollection("orders")
+
+		result, err := orders.Aggregate(
+			Match(Gt("total", 6)),
+			Group([]string{"customer"}, map[string]Accumulator{
+				"count": Count(),
+				"sum":   Sum("total"),
+				"avg":   Avg("total"),
+				"min":   Min("total"),
+				"max":   Max("total"),
+			}),
+			Sort(Desc("sum")),
+		)
+		assert.Nil(t, err)
+		assert.Len(t, result, 3)
+		assert.Equal(t, "bob", result[0].GetField("customer"))
+		assert.Equal(t, 1, result[0].GetField("count"))
+		assert.Equal(t, 40.0, result[0].GetField("avg"))
+		assert.Equal(t, "john", result[2].GetField("customer"))
+		assert.Equal(t, 10.0, result[2].GetField("min"))
+		assert.Nil(t, validateDocument(result[0], nil))
+	})
+
+	t.Run("Should total all documents", func(t *testing.T) {
+		store := newOrdersStore(t)
+		orders, _ := store.GetCollection("orders")
+
+		result, err := orders.Aggregate(Group(nil, map[string]Accumulator{"count": Count(), "sum": Sum("total")}))
+		assert.Nil(t, err)
+		assert.Equal(t, []Document{{Fields: map[string]DocumentField{
+			"count": {Type: DocumentFieldTypeNumber, Value: 4},
+			"sum":   {Type: DocumentFieldTypeNumber, Value: 80.0},
+		}}}, result)
+
+		result, err = orders.Aggregate(Match(Eq("customer", "nobody")), Group(nil, map[string]Accumulator{"count": Count(), "avg": Avg("total")}))
+		assert.Nil(t, err)
+		assert.Equal(t, 0, result[0].GetField("count"))
+		_, hasAvg := result[0].Fields["avg"]
+		assert.False(t, hasAvg)
+	})
+
+	t.Run("Should unwind arrays and collect distinct values", func(t *testing.T) {
+		store := newOrdersStore(t)
+		orders, _ := store.GetCollection("orders")
+
+		result, err := orders.Aggregate(
+			Unwind("items"),
+			Group([]string{"customer"}, map[string]Accumulator{"items": Distinct("items"), "all": Push("items")}),
+			Sort(Asc("customer")),
+		)
+		assert.Nil(t, err)
+		assert.Len(t, result, 3)
+		assert.Equal(t, []interface{}{"plum", "apple"}, result[0].GetField("items"))
+		assert.Equal(t, "john", result[2].GetField("customer"))
+		assert.Equal(t, []interface{}{"apple", "pear"}, result[2].GetField("all"))
+	})
+
+	t.Run("Should project, skip and limit", func(t *testing.T) {
+		store := newOrdersStore(t)
+		orders, _ := store.GetCollection("orders")
+
+		result, err := orders.Aggregate(
+			Sort(Desc("total")),
+			Skip(1),
+			Limit(2),
+			Project(map[string]string{"order": "id", "firstItem": "items.0"}),
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, []Document{
+			{Fields: map[string]DocumentField{
+				"order":     {Type: DocumentFieldTypeString, Value: "2"},
+				"firstItem": {Type: DocumentFieldTypeString, Value: "apple"},
+			}},
+			{Fields: map[string]DocumentField{
+				"order":     {Type: DocumentFieldTypeString, Value: "1"},
+				"firstItem": {Type: DocumentFieldTypeString, Value: "apple"},
+			}},
+		}, result)
+	})
+
+	t.Run("Should reject invalid stages", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+
+		_, err := collection.Aggregate(Limit(-1))
+		assert.ErrorIs(t, err, ErrInvalidStage)
+		_, err = collection.Aggregate(Lookup("users", "customer", "id", "user"))
+		assert.ErrorIs(t, err, ErrInvalidStage)
+		_, err = collection.Aggregate(Group([]string{"a"}, map[string]Accumulator{"a": Count()}))
+		assert.ErrorIs(t, err, ErrInvalidStage)
+	})
+}
+
+func TestStore_Aggregate(t *testing.T) {
+	t.Run("Should lookup documents of another collection", func(t *testing.T) {
+		store := newOrdersStore(t)
+
+		result, err := store.Aggregate("orders",
+			Match(In("id", "1", "4")),
+			Lookup("users", "customer", "id", "user"),
+			Project(map[string]string{"id": "id", "name": "user.0.name", "user": "user"}),
+		)
+		assert.Nil(t, err)
+		assert.Len(t, result, 2)
+		assert.Equal(t, "John", result[0].GetField("name"))
+		assert.Equal(t, []interface{}{}, result[1].GetField("user"))
+		assert.Nil(t, validateDocument(result[0], nil))
+	})
+
+	t.Run("Should fail for unknown collection", func(t *testing.T) {
+		store := newOrdersStore(t)
+
+		_, err := store.Aggregate("missing")
+		assert.ErrorIs(t, err, ErrCollectionNotFound)
+		_, err = store.Aggregate("orders", Lookup("missing", "customer", "id", "user"))
+		assert.ErrorIs(t, err, ErrCollectionNotFound)
+	})
+}
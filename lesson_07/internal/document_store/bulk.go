@@ -0,0 +1,196 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lesson_07/internal/btree"
+)
+
+var ErrBulkSkipped = errors.New("operation skipped after an earlier error")
+
+// BulkOpType is the kind of a BulkOp.
+type BulkOpType string
+
+const (
+	BulkInsert  BulkOpType = "insert"
+	BulkReplace BulkOpType = "replace"
+	BulkUpdate  BulkOpType = "update"
+	BulkDelete  BulkOpType = "delete"
+)
+
+// BulkOp is an operation of Collection.BulkWrite. Inserts and replaces take
+// Document, updates take ID and Update, deletes take ID.
+type BulkOp struct {
+	Type     BulkOpType
+	Document Document
+	ID       string
+	Update   Update
+}
+
+func InsertOp(doc Document) BulkOp {
+	return BulkOp{Type: BulkInsert, Document: doc}
+}
+
+func ReplaceOp(doc Document) BulkOp {
+	return BulkOp{Type: BulkReplace, Document: doc}
+}
+
+func UpdateOp(id string, update Update) BulkOp {
+	return BulkOp{Type: BulkUpdate, ID: id, Update: update}
+}
+
+func DeleteOp(id string) BulkOp {
+	return BulkOp{Type: BulkDelete, ID: id}
+}
+
+// BulkOptions tune Collection.BulkWrite.
+type BulkOptions struct {
+	// Unordered runs every operation regardless of failures; by default
+	// operations after the first failure are skipped.
+	Unordered bool
+}
+
+// BulkResult is the outcome of a BulkOp. Document is the stored document
+// of a successful insert, replace or update.
+type BulkResult struct {
+	Document *Document
+	Err      error
+}
+
+// BulkWrite runs operations in order, like the corresponding methods, and
+// persists their writes at once. It returns a result per operation and the
+// joined errors of failed operations. Operations skipped in ordered mode
+// fail with ErrBulkSkipped, which is not part of the returned error.
+//
+// Deletes cascading to other collections write to them directly, outside
+// of the batch.
+func (s *Collection) BulkWrite(ops []BulkOp, opts BulkOptions) ([]BulkResult, error) {
+	return s.BulkWriteContext(context.Background(), ops, opts)
+}
+
+// BulkWriteContext is BulkWrite that stops when ctx is done, also in
+// unordered mode. Operations not run fail with ErrBulkSkipped; writes of the
+// operations run before are persisted.
+func (s *Collection) BulkWriteContext(ctx context.Context, ops []BulkOp, opts BulkOptions) (_ []BulkResult, err error) {
+	collectionLogger().DebugContext(ctx, "Bulk write", "ops", len(ops), "opts", opts)
+	ctx, o := s.startOperation(ctx, OpBulkWrite)
+	defer o.end(&err)
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+
+	results := make([]BulkResult, len(ops))
+	var errs []error
+	flushErr := s.batch(func() {
+		for i, op := range ops {
+			results[i] = s.bulkOp(ctx, op)
+			if results[i].Err == nil {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("operation %d: %w", i, results[i].Err))
+			if !opts.Unordered || ctx.Err() != nil {
+				for j := i + 1; j < len(ops); j++ {
+					results[j].Err = ErrBulkSkipped
+				}
+				return
+			}
+		}
+	})
+	if flushErr != nil {
+		flushErr = fmt.Errorf("failed to persist bulk write: %w", flushErr)
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = BulkResult{Err: flushErr}
+			}
+		}
+		errs = append(errs, flushErr)
+	}
+
+	return results, errors.Join(errs...)
+}
+
+func (s *Collection) bulkOp(ctx context.Context, op BulkOp) BulkResult {
+	var doc *Document
+	var err error
+	switch op.Type {
+	case BulkInsert:
+		doc, err = s.PutContext(ctx, op.Document)
+	case BulkReplace:
+		doc, err = s.ReplaceContext(ctx, op.Document)
+	case BulkUpdate:
+		doc, err = s.UpdateContext(ctx, op.ID, op.Update)
+	case BulkDelete:
+		err = s.DeleteContext(ctx, op.ID)
+	default:
+		err = s.docError(op.ID, "", fmt.Errorf("%w: unknown bulk operation %q", ErrValidationFailed, op.Type))
+	}
+
+	return BulkResult{Document: doc, Err: err}
+}
+
+// batchTable is a table keeping writes in memory during a batch.
+type batchTable interface {
+	begin()
+	flush(tx *btree.Tx) error
+	end(err error) error
+}
+
+// batch runs fn persisting the writes made to the collection at once: tables
+// of the store file commit a single transaction and the log of an LSM table
+// is synced once. Writes of other goroutines to the collection while fn runs
+// are persisted with the batch.
+func (s *Collection) batch(fn func()) error {
+	s.bulkMu.Lock()
+	defer s.bulkMu.Unlock()
+
+	var db *btree.DB
+	var tables []batchTable
+	s.mu.Lock()
+	if t, ok := s.documents.(*treeTable[Document]); ok {
+		db = t.db
+		tables = append(tables, t)
+	}
+	if t, ok := s.history.(*treeTable[[]DocumentVersion]); ok {
+		db = t.db
+		tables = append(tables, t)
+	}
+	for _, t := range tables {
+		t.begin()
+	}
+	s.mu.Unlock()
+
+	var err error
+	if t, ok := s.documents.(*lsmTable[Document]); ok {
+		err = t.db.Batch(fn)
+	} else {
+		fn()
+	}
+	if db == nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flushErr := db.Update(func(tx *btree.Tx) error {
+		for _, t := range tables {
+			if err := t.flush(tx); err != nil {
+				return err
+			}
+		}
+		if s.store == nil {
+			return nil
+		}
+		return s.saveLastWrite(tx)
+	})
+	errs := []error{err, flushErr}
+	for _, t := range tables {
+		errs = append(errs, t.end(flushErr))
+	}
+	if flushErr != nil {
+		errs = append(errs, s.buildIndexes())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,180 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestCollection_BulkWrite(t *testing.T) {
+	collections := map[string]func(t *testing.T) *Collection{
+		"memory": func(t *testing.T) *Collection {
+			return NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+		},
+		"file": func(t *testing.T) *Collection {
+			store, err := OpenStore(filepath.Join(t.TempDir(), "store.db"))
+			assert.Nil(t, err)
+			t.Cleanup(func() { store.Close() })
+			collection, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+			return collection
+		},
+		"lsm": func(t *testing.T) *Collection {
+			collection, err := OpenCollection(&CollectionConfig{
+				PrimaryKey: "id",
+				Engine:     CollectionEngineLSM,
+				LSM:        &LSMConfig{Dir: t.TempDir(), SyncWrites: true},
+			})
+			assert.Nil(t, err)
+			t.Cleanup(func() { collection.Close() })
+			return collection
+		},
+	}
+
+	for name, newCollection := range collections {
+		t.Run(name+": should run mixed operations", func(t *testing.T) {
+			collection := newCollection(t)
+			collection.Put(userDocument("1", "John"))
+			collection.Put(userDocument("2", "Jane"))
+
+			results, err := collection.BulkWrite([]BulkOp{
+				InsertOp(userDocument("3", "Ann")),
+				ReplaceOp(userDocument("1", "Johnny")),
+				UpdateOp("3", Update{Set: map[string]interface{}{"name": "Anna"}}),
+				DeleteOp("2"),
+			}, BulkOptions{})
+			assert.Nil(t, err)
+			assert.Len(t, results, 4)
+			assert.Equal(t, "Ann", results[0].Document.GetField("name"))
+			assert.Equal(t, "Anna", results[2].Document.GetField("name"))
+			assert.Nil(t, results[3].Document)
+			assert.Nil(t, results[3].Err)
+
+			names := map[string]interface{}{}
+			for _, doc := range collection.List() {
+				names[doc.GetField("id").(string)] = doc.GetField("name")
+			}
+			assert.Equal(t, map[string]interface{}{"1": "Johnny", "3": "Anna"}, names)
+		})
+
+		t.Run(name+": should stop at the first error when ordered", func(t *testing.T) {
+			collection := newCollection(t)
+			collection.Put(userDocument("1", "John"))
+
+			results, err := collection.BulkWrite([]BulkOp{
+				InsertOp(userDocument("2", "Jane")),
+				InsertOp(userDocument("1", "Duplicate")),
+				InsertOp(userDocument("3", "Ann")),
+			}, BulkOptions{})
+			assert.ErrorIs(t, err, ErrDocumentExists)
+			assert.NotErrorIs(t, err, ErrBulkSkipped)
+			assert.Nil(t, results[0].Err)
+			assert.ErrorIs(t, results[1].Err, ErrDocumentExists)
+			assert.ErrorIs(t, results[2].Err, ErrBulkSkipped)
+
+			assert.Len(t, collection.List(), 2)
+			_, err = collection.Get("3")
+			assert.ErrorIs(t, err, ErrDocumentNotFound)
+		})
+
+		t.Run(name+": should report every error when unordered", func(t *testing.T) {
+			collection := newCollection(t)
+			collection.Put(userDocument("1", "John"))
+
+			results, err := collection.BulkWrite([]BulkOp{
+				InsertOp(userDocument("1", "Duplicate")),
+				DeleteOp("missing"),
+				InsertOp(userDocument("2", "Jane")),
+				{Type: "upsert"},
+			}, BulkOptions{Unordered: true})
+			assert.ErrorIs(t, err, ErrDocumentExists)
+			assert.ErrorIs(t, err, ErrDocumentNotFound)
+			assert.ErrorIs(t, err, ErrValidationFailed)
+			assert.ErrorIs(t, results[1].Err, ErrDocumentNotFound)
+			assert.Nil(t, results[2].Err)
+			assert.Len(t, collection.List(), 2)
+		})
+
+		t.Run(name+": should see earlier operations of the batch", func(t *testing.T) {
+			collection := newCollection(t)
+
+			ops := make([]BulkOp, 0, 300)
+			for i := range 100 {
+				id := strconv.Itoa(i)
+				ops = append(ops,
+					InsertOp(userDocument(id, "User")),
+					UpdateOp(id, Update{Inc: map[string]float64{"visits": 1}}),
+				)
+				if i%2 == 0 {
+					ops = append(ops, DeleteOp(id))
+				}
+			}
+			results, err := collection.BulkWrite(ops, BulkOptions{})
+			assert.Nil(t, err)
+			assert.Len(t, results, 250)
+
+			docs := collection.List()
+			assert.Len(t, docs, 50)
+			assert.Equal(t, "1", docs[0].GetField("id"))
+			assert.Equal(t, 1.0, docs[0].GetField("visits"))
+			assert.Equal(t, 50, collection.documents.len())
+		})
+	}
+
+	t.Run("Should persist a batch in a single transaction", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "store.db")
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+		users.Put(userDocument("0", "Old"))
+
+		before := store.db.Stats()
+		ops := []BulkOp{DeleteOp("0")}
+		for i := 1; i <= 20; i++ {
+			ops = append(ops, InsertOp(userDocument(strconv.Itoa(i), "User")))
+		}
+		_, err = users.BulkWrite(ops, BulkOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, before.TxID+1, store.db.Stats().TxID)
+		assert.Nil(t, store.Close())
+
+		store, err = OpenStore(filename)
+		assert.Nil(t, err)
+		defer store.Close()
+		users, _ = store.GetCollection("users")
+		assert.Len(t, users.List(), 20)
+		assert.Equal(t, 20, users.documents.len())
+		history, err := users.History("0")
+		assert.Nil(t, err)
+		assert.Len(t, history, 2)
+	})
+
+	t.Run("Should apply references", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceCascade)
+		authors, _ := store.GetCollection("authors")
+		books, _ := store.GetCollection("books")
+
+		results, err := books.BulkWrite([]BulkOp{
+			InsertOp(bookDocument("b3", "a1")),
+			InsertOp(bookDocument("b4", "a3")),
+		}, BulkOptions{Unordered: true})
+		assert.ErrorIs(t, err, ErrReferenceViolation)
+		assert.Nil(t, results[0].Err)
+
+		_, err = authors.BulkWrite([]BulkOp{DeleteOp("a1")}, BulkOptions{})
+		assert.Nil(t, err)
+		assert.Len(t, books.List(), 1)
+	})
+
+	t.Run("Should reject read-only collections", func(t *testing.T) {
+		store := NewStore()
+		store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		snapshot, err := store.Snapshot()
+		assert.Nil(t, err)
+		defer snapshot.Close()
+
+		users, _ := snapshot.GetCollection("users")
+		_, err = users.BulkWrite([]BulkOp{InsertOp(userDocument("1", "John"))}, BulkOptions{})
+		assert.ErrorIs(t, err, ErrReadOnly)
+	})
+}
@@ -1,115 +1,718 @@
 package documentstore
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"lesson_07/internal/btree"
 	"log/slog"
 	"reflect"
+	"strings"
+	"sync"
+	"time"
 )
 
 var ErrDocumentNotFound = errors.New("document not found")
 var ErrValidationFailed = errors.New("validation failed")
 
-var ErrUserUniquenessValidation = errors.New("user already exists")
+var ErrDocumentExists = errors.New("document already exists")
+
+var ErrInvalidCollectionConfig = errors.New("invalid collection config")
+var ErrReadOnly = errors.New("collection is read-only")
+
+// now is the clock used for expiry and version timestamps; tests replace it.
+var now = time.Now
 
 type Collection struct {
+	// name and store are set for collections of a Store.
+	name  string
+	store *Store
+	// bulkMu serializes bulk writes, see batch.
+	bulkMu sync.Mutex
+
+	mu        sync.RWMutex
 	cfg       CollectionConfig
-	documents map[string]Document
+	documents table[Document]
+	history   table[[]DocumentVersion]
+	textIndex *textIndex
+	geoIndex  *geoIndex
+	readOnly  bool
+	// lastWrite is the time of the last put or delete, in UTC without a
+	// monotonic reading so that it survives dumps unchanged.
+	lastWrite time.Time
 }
 
 type PublicCollection struct {
-	Cfg       CollectionConfig    `json:"cfg"`
-	Documents map[string]Document `json:"documents"`
+	Cfg       CollectionConfig             `json:"cfg"`
+	Documents map[string]Document          `json:"documents"`
+	History   map[string][]DocumentVersion `json:"history,omitempty"`
+	LastWrite *time.Time                   `json:"lastWrite,omitempty"`
 }
 
 type CollectionConfig struct {
-	PrimaryKey string `json:"primaryKey"`
+	PrimaryKey string           `json:"primaryKey"`
+	Engine     CollectionEngine `json:"engine,omitempty"`
+	LSM        *LSMConfig       `json:"lsm,omitempty"`
+	History    *HistoryConfig   `json:"history,omitempty"`
+	// DefaultTTL sets ExpiresAt of written documents that have none.
+	DefaultTTL time.Duration `json:"defaultTTL,omitempty"`
+	// TextIndex enables Collection.Search.
+	TextIndex *TextIndexConfig `json:"textIndex,omitempty"`
+	// GeoIndex enables geo queries such as Collection.WithinRadius.
+	GeoIndex *GeoIndexConfig `json:"geoIndex,omitempty"`
+	// References are fields holding primary keys of other collections.
+	References []ReferenceConfig `json:"references,omitempty"`
+	// Schema is checked on every write; see InferSchema to derive one from
+	// existing documents.
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// CollectionEngine selects where documents of a collection are kept.
+type CollectionEngine string
+
+const (
+	// CollectionEngineDefault keeps documents in memory, or in the database
+	// file of a store opened with OpenStore.
+	CollectionEngineDefault CollectionEngine = ""
+	// CollectionEngineLSM keeps documents in a log-structured merge tree,
+	// which suits append-heavy collections.
+	CollectionEngineLSM CollectionEngine = "lsm"
+)
+
+// LSMConfig configures CollectionEngineLSM. Zero values use engine defaults.
+type LSMConfig struct {
+	Dir                 string `json:"dir"`
+	MemtableSize        int    `json:"memtableSize,omitempty"`
+	L0CompactionTrigger int    `json:"l0CompactionTrigger,omitempty"`
+	BaseLevelSize       int64  `json:"baseLevelSize,omitempty"`
+	LevelSizeMultiplier int    `json:"levelSizeMultiplier,omitempty"`
+	TargetFileSize      int64  `json:"targetFileSize,omitempty"`
+	BloomBitsPerKey     int    `json:"bloomBitsPerKey,omitempty"`
+	SyncWrites          bool   `json:"syncWrites,omitempty"`
 }
 
+// NewCollection creates an in-memory collection. Use OpenCollection to honor
+// the engine settings of the config.
 func NewCollection(cfg *CollectionConfig) *Collection {
+	var history table[[]DocumentVersion]
+	if cfg.History != nil {
+		history = newMemoryTable[[]DocumentVersion](nil)
+	}
+
+	col := newCollection(cfg, newDocumentMemoryTable(nil), history)
+	_ = col.buildIndexes()
+
+	return col
+}
+
+// OpenCollection creates a collection backed by the engine selected in cfg.
+func OpenCollection(cfg *CollectionConfig) (*Collection, error) {
+	return openCollectionTables(nil, "", cfg)
+}
+
+// openCollectionTables opens every table of a collection. db is the database
+// file of the store, if any.
+func openCollectionTables(db *btree.DB, name string, cfg *CollectionConfig) (*Collection, error) {
+	if err := errors.Join(validateTextIndexConfig(cfg.TextIndex), validateGeoIndexConfig(cfg.GeoIndex), validateReferences(cfg.References), validateSchema(cfg.Schema)); err != nil {
+		return nil, err
+	}
+
+	documents, err := openDocumentTable(db, name, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := openHistoryTable(db, name, cfg)
+	if err != nil {
+		_ = documents.close()
+		return nil, err
+	}
+
+	col := newCollection(cfg, documents, history)
+	if err := col.buildIndexes(); err != nil {
+		_ = col.close()
+		return nil, err
+	}
+
+	return col, nil
+}
+
+// buildIndexes rebuilds the in-memory indexes from stored documents. Must be
+// called with s.mu held, or before the collection is shared.
+func (s *Collection) buildIndexes() error {
+	return errors.Join(s.buildTextIndex(), s.buildGeoIndex())
+}
+
+// updateIndexes replaces the indexed state of a document; old is nil for a
+// new document and doc is nil for a removed one. Must be called with s.mu
+// held.
+func (s *Collection) updateIndexes(id string, old *Document, doc *Document) {
+	if s.textIndex != nil {
+		if old != nil {
+			s.textIndex.remove(id, *old)
+		}
+		if doc != nil {
+			s.textIndex.add(id, *doc)
+		}
+	}
+	if s.geoIndex != nil {
+		s.geoIndex.remove(id)
+		if doc != nil {
+			s.geoIndex.add(id, *doc)
+		}
+	}
+}
+
+// traceIndexes is updateIndexes in a span of the operation of ctx.
+func (s *Collection) traceIndexes(ctx context.Context, id string, old *Document, doc *Document) {
+	if !s.hasIndexes() {
+		return
+	}
+
+	var names []string
+	if s.textIndex != nil {
+		names = append(names, "text")
+	}
+	if s.geoIndex != nil {
+		names = append(names, "geo")
+	}
+	span := startIndexSpan(ctx, strings.Join(names, ","))
+	defer span.End()
+
+	s.updateIndexes(id, old, doc)
+}
+
+// hasIndexes reports whether writes need the previous state of documents.
+func (s *Collection) hasIndexes() bool {
+	return s.textIndex != nil || s.geoIndex != nil
+}
+
+// openDocumentTable opens storage for the documents of a collection. db is
+// the database file of the store, if any.
+func openDocumentTable(db *btree.DB, name string, cfg *CollectionConfig) (table[Document], error) {
+	switch cfg.Engine {
+	case CollectionEngineLSM:
+		return openLSMTable[Document](cfg.LSM)
+	case CollectionEngineDefault:
+		if db != nil {
+			return openTreeTable[Document](db, tableKeyPrefix(documentPrefix, name), []byte(countPrefix+name))
+		}
+		return newDocumentMemoryTable(nil), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown engine %q", ErrInvalidCollectionConfig, cfg.Engine)
+	}
+}
+
+func newCollection(cfg *CollectionConfig, documents table[Document], history table[[]DocumentVersion]) *Collection {
 	col := Collection{
 		cfg:       *cfg,
-		documents: map[string]Document{},
+		documents: documents,
+		history:   history,
 	}
 
 	return &col
 }
 
 func (s *Collection) Put(doc Document) (*Document, error) {
-	slog.Debug("Put document", "doc", doc)
+	return s.PutContext(context.Background(), doc)
+}
+
+// PutContext is Put that gives up when ctx is done before the document is
+// written.
+func (s *Collection) PutContext(ctx context.Context, doc Document) (_ *Document, err error) {
+	collectionLogger().DebugContext(ctx, "Put document", "doc", doc)
+	ctx, o := s.startOperation(ctx, OpPut)
+	defer o.end(&err)
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+
+	release := s.lockReferences()
+	defer release()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.checkReferences(doc); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	id, err := s.primaryKey(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, exists := s.get(id); exists == nil {
+		return nil, s.docError(id, "", ErrDocumentExists)
+	}
+
+	return s.write(ctx, id, doc)
+}
+
+// Replace overwrites an existing document that has the same primary key.
+func (s *Collection) Replace(doc Document) (*Document, error) {
+	return s.ReplaceContext(context.Background(), doc)
+}
+
+// ReplaceContext is Replace that gives up when ctx is done before the
+// document is written.
+func (s *Collection) ReplaceContext(ctx context.Context, doc Document) (_ *Document, err error) {
+	collectionLogger().DebugContext(ctx, "Replace document", "doc", doc)
+	ctx, o := s.startOperation(ctx, OpReplace)
+	defer o.end(&err)
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+
+	release := s.lockReferences()
+	defer release()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.checkReferences(doc); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	id, err := s.primaryKey(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.get(id); err != nil {
+		return nil, err
+	}
+
+	return s.write(ctx, id, doc)
+}
+
+// primaryKey validates and returns the primary key of doc.
+func (s *Collection) primaryKey(doc Document) (string, error) {
 	primaryKeyField, ok := doc.Fields[s.cfg.PrimaryKey]
 	if !ok {
-		return nil, fmt.Errorf("%w: PrimaryKey is missing Fields", ErrValidationFailed)
+		return "", s.docError("", s.cfg.PrimaryKey, fmt.Errorf("%w: PrimaryKey is missing Fields", ErrValidationFailed))
 	}
 
 	id, typedCorrectly := primaryKeyField.Value.(string)
 
 	if !typedCorrectly {
-		return nil, fmt.Errorf("%w: PrimaryKey has incorrect type. Should be a string, passed %v", ErrValidationFailed, reflect.TypeOf(doc.Fields))
+		return "", s.docError("", s.cfg.PrimaryKey, fmt.Errorf("%w: PrimaryKey has incorrect type. Should be a string, passed %v", ErrValidationFailed, reflect.TypeOf(primaryKeyField.Value)))
 	}
 
 	if id == "" {
-		return nil, fmt.Errorf("%w: PrimaryKey cannot be empty", ErrValidationFailed)
+		return "", s.docError("", s.cfg.PrimaryKey, fmt.Errorf("%w: PrimaryKey cannot be empty", ErrValidationFailed))
+	}
+
+	return id, nil
+}
+
+// write validates and stores doc. Must be called with s.mu held.
+func (s *Collection) write(ctx context.Context, id string, doc Document) (*Document, error) {
+	validationErrors := traceStep(ctx, "validate", func() error {
+		return validateDocument(doc, s.cfg.Schema)
+	}, slog.Int("documentstore.fields", len(doc.Fields)))
+	if validationErrors != nil {
+		return nil, s.docError(id, "", validationErrors)
+	}
+
+	if doc.ExpiresAt != nil {
+		expiresAt := doc.ExpiresAt.UTC()
+		doc.ExpiresAt = &expiresAt
+	} else if s.cfg.DefaultTTL > 0 {
+		expiresAt := now().Add(s.cfg.DefaultTTL).UTC()
+		doc.ExpiresAt = &expiresAt
 	}
 
-	if _, exists := s.Get(id); exists == nil {
-		return nil, fmt.Errorf("%w; ID: '%s'", ErrUserUniquenessValidation, id)
+	var old *Document
+	if s.hasIndexes() {
+		stored, found, err := s.documents.get(id)
+		if err != nil {
+			return nil, s.docError(id, "", fmt.Errorf("failed to read document: %w", err))
+		}
+		if found {
+			old = &stored
+		}
 	}
 
-	if validationErrors := validateDocument(doc); validationErrors != nil {
-		return nil, fmt.Errorf("%w: document validation failed: %w", ErrValidationFailed, validationErrors)
+	undo, err := s.recordVersion(id, &doc)
+	if err != nil {
+		return nil, err
 	}
+	err = traceStep(ctx, "persist", func() error {
+		return s.documents.put(id, doc)
+	}, slog.String(AttrEngine, tableEngine(s.documents)))
+	if err != nil {
+		undo()
+		return nil, s.docError(id, "", fmt.Errorf("failed to store document: %w", err))
+	}
+	s.traceIndexes(ctx, id, old, &doc)
+	s.lastWrite = now().UTC().Round(0)
 
-	s.documents[id] = doc
 	return &doc, nil
 }
 
-func (s *Collection) Get(key string) (*Document, error) {
-	slog.Debug("Get document:", "key", key)
-	if doc, ok := s.documents[key]; ok {
+func (s *Collection) Get(key string, opts ...ReadOption) (*Document, error) {
+	return s.GetContext(context.Background(), key, opts...)
+}
+
+// GetContext is Get that gives up when ctx is done.
+func (s *Collection) GetContext(ctx context.Context, key string, opts ...ReadOption) (_ *Document, err error) {
+	collectionLogger().DebugContext(ctx, "Get document:", "key", key)
+	ctx, o := s.startOperation(ctx, OpGet)
+	defer o.end(&err)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	doc, err := s.get(key)
+	s.mu.RUnlock()
+	if err != nil || len(opts) == 0 {
+		return doc, err
+	}
+
+	read, err := s.read(ctx, *doc, newReadOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return &read, nil
+}
+
+func (s *Collection) get(key string) (*Document, error) {
+	doc, ok, err := s.documents.get(key)
+	if err != nil {
+		return nil, s.docError(key, "", fmt.Errorf("failed to read document: %w", err))
+	}
+	if ok && !doc.expired(now()) {
 		return &doc, nil
 	}
 
-	return nil, fmt.Errorf("failed to find document with key %s: %w", key, ErrDocumentNotFound)
+	return nil, s.docError(key, "", ErrDocumentNotFound)
 }
 
-func (s *Collection) Delete(key string) bool {
-	slog.Debug("Delete document:", "key", key)
-	if _, err := s.Get(key); err == nil {
-		delete(s.documents, key)
-		return true
+// Delete removes a document. It fails with ErrDocumentNotFound for a missing
+// document and with ErrReferenceViolation when a restricting reference to it
+// remains. Referencing documents that cannot be deleted or set to null, e.g.
+// by their schema, reject the delete as well.
+func (s *Collection) Delete(key string) error {
+	return s.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is Delete that gives up when ctx is done before the document
+// is deleted.
+func (s *Collection) DeleteContext(ctx context.Context, key string) (err error) {
+	collectionLogger().DebugContext(ctx, "Delete document:", "key", key)
+	ctx, o := s.startOperation(ctx, OpDelete)
+	defer o.end(&err)
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	release := s.lockReferences()
+	defer release()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.store == nil {
+		return s.deleteDocument(ctx, key)
 	}
 
-	return false
+	plan, err := s.store.planDocumentDelete(s.name, key)
+	if err != nil {
+		return s.docError(key, "", err)
+	}
+	if err := s.deleteDocument(ctx, key); err != nil {
+		return err
+	}
+	if err := plan.apply(ctx); err != nil {
+		collectionLogger().Error("Failed to update referencing documents", "key", key, "err", err)
+		return s.docError(key, "", fmt.Errorf("failed to update referencing documents: %w", err))
+	}
+
+	return nil
 }
 
-func (s *Collection) List() []Document {
-	slog.Debug("List documents in collection")
-	docs := make([]Document, 0, len(s.documents))
-	for _, doc := range s.documents {
-		docs = append(docs, doc)
+// deleteDocument removes a document without looking at references.
+func (s *Collection) deleteDocument(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, found, err := s.documents.get(key)
+	if err != nil {
+		return s.docError(key, "", fmt.Errorf("failed to read document: %w", err))
+	}
+	if !found {
+		return s.docError(key, "", ErrDocumentNotFound)
+	}
+
+	// An expired document is removed as well, but reported as missing.
+	if doc.expired(now()) {
+		if err := s.removeExpired(key, doc); err != nil {
+			collectionLogger().Error("Failed to remove expired document", "key", key, "err", err)
+		}
+		return s.docError(key, "", ErrDocumentNotFound)
+	}
+
+	undo, err := s.recordVersion(key, nil)
+	if err != nil {
+		return err
+	}
+	err = traceStep(ctx, "persist", func() error {
+		_, err := s.documents.delete(key)
+		return err
+	}, slog.String(AttrEngine, tableEngine(s.documents)))
+	if err != nil {
+		undo()
+		return s.docError(key, "", fmt.Errorf("failed to delete document: %w", err))
+	}
+	s.traceIndexes(ctx, key, &doc, nil)
+	s.lastWrite = now().UTC().Round(0)
+
+	return nil
+}
+
+// List returns documents ordered by primary key. It reads from a snapshot,
+// so concurrent writes are neither blocked nor partially visible.
+func (s *Collection) List(opts ...ReadOption) []Document {
+	docs, err := s.ListContext(context.Background(), opts...)
+	if err != nil {
+		collectionLogger().Error("Failed to list documents", "err", err)
 	}
 
 	return docs
 }
 
+// ListContext is List that reports errors and gives up when ctx is done.
+// Documents read before an error are returned with it.
+func (s *Collection) ListContext(ctx context.Context, opts ...ReadOption) (_ []Document, err error) {
+	collectionLogger().DebugContext(ctx, "List documents in collection")
+	ctx, o := s.startOperation(ctx, OpList)
+	defer o.end(&err)
+
+	docs, err := s.list(ctx, opts...)
+	o.scanned(len(docs))
+	o.returned(len(docs))
+
+	return docs, err
+}
+
+func (s *Collection) list(ctx context.Context, opts ...ReadOption) ([]Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	documents, err := s.snapshotDocuments()
+	if err != nil {
+		return nil, err
+	}
+	defer documents.close()
+
+	docs := make([]Document, 0, documents.len())
+	at := now()
+	var ctxErr error
+	err = documents.scan("", func(_ string, doc Document) bool {
+		if ctxErr = ctx.Err(); ctxErr != nil {
+			return false
+		}
+		if !doc.expired(at) {
+			docs = append(docs, doc)
+		}
+		return true
+	})
+	if err = errors.Join(err, ctxErr); err != nil {
+		return docs, err
+	}
+
+	return s.listWith(ctx, docs, newReadOptions(opts))
+}
+
+func (s *Collection) snapshotDocuments() (table[Document], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.documents.snapshot()
+}
+
+// snapshot returns a read-only copy of the collection. Must be called with
+// s.mu held.
+func (s *Collection) snapshot() (*Collection, error) {
+	documents, err := s.documents.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	var history table[[]DocumentVersion]
+	if s.history != nil {
+		if history, err = s.history.snapshot(); err != nil {
+			_ = documents.close()
+			return nil, err
+		}
+	}
+
+	return &Collection{cfg: s.cfg, documents: documents, history: history, readOnly: true, lastWrite: s.lastWrite}, nil
+}
+
 func (s *Collection) MarshalJSON() ([]byte, error) {
+	return s.marshal(context.Background())
+}
+
+// marshal encodes a snapshot of the collection, giving up when ctx is done.
+func (s *Collection) marshal(ctx context.Context) ([]byte, error) {
+	s.mu.RLock()
+	snapshot, err := s.snapshot()
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Close()
+
+	items, err := tableItemsContext(ctx, snapshot.documents)
+	if err != nil {
+		return nil, err
+	}
+
 	collection := PublicCollection{
 		Cfg:       s.cfg,
-		Documents: s.documents,
+		Documents: items,
+	}
+	if !snapshot.lastWrite.IsZero() {
+		collection.LastWrite = &snapshot.lastWrite
+	}
+	if snapshot.history != nil {
+		if collection.History, err = tableItemsContext(ctx, snapshot.history); err != nil {
+			return nil, err
+		}
 	}
 
 	return json.Marshal(&collection)
 }
 
 func (s *Collection) UnmarshalJSON(data []byte) error {
-	publicCollection := PublicCollection{}
+	return s.unmarshal(context.Background(), data)
+}
+
+// unmarshal decodes a collection document by document, giving up when ctx
+// is done.
+func (s *Collection) unmarshal(ctx context.Context, data []byte) error {
+	var publicCollection struct {
+		Cfg       CollectionConfig           `json:"cfg"`
+		Documents map[string]json.RawMessage `json:"documents"`
+		History   map[string]json.RawMessage `json:"history,omitempty"`
+		LastWrite *time.Time                 `json:"lastWrite,omitempty"`
+	}
 	if err := json.Unmarshal(data, &publicCollection); err != nil {
 		return err
 	}
 	s.cfg = publicCollection.Cfg
-	s.documents = publicCollection.Documents
+	if publicCollection.LastWrite != nil {
+		s.lastWrite = *publicCollection.LastWrite
+	}
+
+	if s.cfg.History != nil {
+		history, err := unmarshalItems[[]DocumentVersion](ctx, publicCollection.History)
+		if err != nil {
+			return err
+		}
+		s.history = newMemoryTable(history)
+	}
+
+	items, err := unmarshalItems[Document](ctx, publicCollection.Documents)
+	if err != nil {
+		return err
+	}
+
+	if s.cfg.Engine == CollectionEngineDefault {
+		s.documents = newDocumentMemoryTable(items)
+		return s.buildIndexes()
+	}
+
+	documents, err := openDocumentTable(nil, "", &s.cfg)
+	if err != nil {
+		return err
+	}
+	if err := replaceDocuments(documents, items); err != nil {
+		_ = documents.close()
+		return err
+	}
+	s.documents = documents
+
+	return s.buildIndexes()
+}
+
+func unmarshalItems[V any](ctx context.Context, raw map[string]json.RawMessage) (map[string]V, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	items := make(map[string]V, len(raw))
+	for key, data := range raw {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var value V
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		items[key] = value
+	}
+
+	return items, nil
+}
+
+// Close releases resources of the collection engine.
+// replaceDocuments makes the documents of a table those of items. Documents
+// left in the directory of an engine, e.g. by the store a dump was taken
+// from, are deleted unless the dump has them.
+func replaceDocuments(documents table[Document], items map[string]Document) error {
+	var stale []string
+	err := documents.scan("", func(key string, _ Document) bool {
+		if _, ok := items[key]; !ok {
+			stale = append(stale, key)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range stale {
+		if _, err := documents.delete(key); err != nil {
+			return err
+		}
+	}
+	for id, doc := range items {
+		if err := documents.put(id, doc); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
+
+func (s *Collection) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.close()
+}
+
+func (s *Collection) close() error {
+	var errs []error
+	errs = append(errs, s.documents.close())
+	if s.history != nil {
+		errs = append(errs, s.history.close())
+	}
+
+	return errors.Join(errs...)
+}
@@ -1,7 +1,9 @@
 package documentstore
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"math/rand"
 	"testing"
 )
 
@@ -22,13 +24,13 @@ func TestCollection_Put(t *testing.T) {
 			},
 		})
 
-		assert.Equal(t, 1, len(collection.documents))
+		assert.Equal(t, 1, collection.documents.len())
 	})
 
 	t.Run("Should not add invalid document", func(t *testing.T) {
 		collection := NewCollection(&CollectionConfig{PrimaryKey: "primaryKey"})
 		collection.Put(Document{})
-		assert.Equal(t, 0, len(collection.documents))
+		assert.Equal(t, 0, collection.documents.len())
 	})
 
 	t.Run("Should not add already existing document", func(t *testing.T) {
@@ -43,7 +45,7 @@ func TestCollection_Put(t *testing.T) {
 				"primaryKey": {Type: DocumentFieldTypeString, Value: "123"},
 			},
 		})
-		assert.Equal(t, 1, len(collection.documents))
+		assert.Equal(t, 1, collection.documents.len())
 	})
 }
 
@@ -55,14 +57,14 @@ func TestCollection_Delete(t *testing.T) {
 				"primaryKey": {Type: DocumentFieldTypeString, Value: "123"},
 			},
 		})
-		ok := collection.Delete("123")
-		assert.Equal(t, true, ok, "Should delete document in collection")
+		err := collection.Delete("123")
+		assert.Nil(t, err, "Should delete document in collection")
 	})
 
-	t.Run("Should return false if document was not deleted", func(t *testing.T) {
+	t.Run("Should return an error if document was not deleted", func(t *testing.T) {
 		collection := NewCollection(&CollectionConfig{PrimaryKey: "primaryKey"})
-		ok := collection.Delete("123")
-		assert.Equal(t, false, ok, "collection should return false if document was not deleted")
+		err := collection.Delete("123")
+		assert.ErrorIs(t, err, ErrDocumentNotFound, "collection should return an error if document was not deleted")
 	})
 }
 
@@ -78,3 +80,86 @@ func TestCollection_List(t *testing.T) {
 		assert.Equal(t, 1, len(docs), "collection should return false if document was not deleted")
 	})
 }
+
+func TestCollection_LSMEngine(t *testing.T) {
+	lsmConfig := func(dir string) *CollectionConfig {
+		return &CollectionConfig{
+			PrimaryKey: "id",
+			Engine:     CollectionEngineLSM,
+			LSM:        &LSMConfig{Dir: dir, MemtableSize: 2 << 10, L0CompactionTrigger: 2, BaseLevelSize: 8 << 10, TargetFileSize: 4 << 10},
+		}
+	}
+
+	t.Run("Should match in-memory collection under random operations", func(t *testing.T) {
+		dir := t.TempDir()
+		collection, err := OpenCollection(lsmConfig(dir))
+		assert.Nil(t, err)
+		model := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+
+		rnd := rand.New(rand.NewSource(7))
+		for i := 0; i < 3000; i++ {
+			id := fmt.Sprintf("event-%04d", rnd.Intn(600))
+			if rnd.Intn(4) == 0 {
+				assert.Equal(t, model.Delete(id), collection.Delete(id))
+				continue
+			}
+
+			doc := Document{
+				Fields: map[string]DocumentField{
+					"id":      {Type: DocumentFieldTypeString, Value: id},
+					"payload": {Type: DocumentFieldTypeString, Value: fmt.Sprintf("payload-%d", i)},
+				},
+			}
+			_, modelErr := model.Put(doc)
+			_, err := collection.Put(doc)
+			assert.Equal(t, modelErr == nil, err == nil)
+
+			if i%1000 == 999 {
+				assert.Nil(t, collection.Close())
+				collection, err = OpenCollection(lsmConfig(dir))
+				assert.Nil(t, err)
+			}
+		}
+		defer collection.Close()
+
+		assert.Equal(t, model.documents.len(), collection.documents.len())
+		assert.Equal(t, model.List(), collection.List())
+		for _, doc := range model.List() {
+			id := doc.GetField("id").(string)
+			stored, err := collection.Get(id)
+			assert.Nil(t, err)
+			assert.Equal(t, doc, *stored)
+		}
+	})
+
+	t.Run("Should reject config without directory", func(t *testing.T) {
+		_, err := OpenCollection(&CollectionConfig{PrimaryKey: "id", Engine: CollectionEngineLSM})
+		assert.ErrorIs(t, err, ErrInvalidCollectionConfig)
+	})
+
+	t.Run("Should reject unknown engine", func(t *testing.T) {
+		_, err := OpenCollection(&CollectionConfig{PrimaryKey: "id", Engine: "unknown"})
+		assert.ErrorIs(t, err, ErrInvalidCollectionConfig)
+	})
+}
+
+func TestCollection_Replace(t *testing.T) {
+	t.Run("Should replace existing document", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		collection.Put(userDocument("1", "John"))
+
+		_, err := collection.Replace(userDocument("1", "Johnny"))
+		assert.Nil(t, err)
+
+		doc, _ := collection.Get("1")
+		assert.Equal(t, "Johnny", doc.GetField("name"))
+	})
+
+	t.Run("Should not replace missing document", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+
+		_, err := collection.Replace(userDocument("1", "John"))
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+		assert.Equal(t, 0, collection.documents.len())
+	})
+}
@@ -0,0 +1,104 @@
+package documentstore
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func newUsersStore(t *testing.T) (*Store, *Collection) {
+	store := NewStore()
+	collection, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	collection.Put(userDocument("1", "John"))
+	collection.Put(userDocument("2", "Jane"))
+
+	return store, collection
+}
+
+func TestCollection_Context(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("Should not read with a cancelled context", func(t *testing.T) {
+		_, collection := newUsersStore(t)
+
+		_, err := collection.GetContext(cancelled, "1")
+		assert.ErrorIs(t, err, context.Canceled)
+
+		docs, err := collection.ListContext(cancelled)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, docs)
+
+		_, err = collection.AggregateContext(cancelled, Limit(1))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Should not write with a cancelled context", func(t *testing.T) {
+		_, collection := newUsersStore(t)
+
+		_, err := collection.PutContext(cancelled, userDocument("3", "Ann"))
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = collection.ReplaceContext(cancelled, userDocument("1", "Johnny"))
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = collection.UpdateContext(cancelled, "1", Update{Set: map[string]interface{}{"name": "Johnny"}})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, collection.DeleteContext(cancelled, "2"), context.Canceled)
+
+		assert.Len(t, collection.List(), 2)
+		doc, err := collection.Get("1")
+		assert.Nil(t, err)
+		assert.Equal(t, "John", doc.GetField("name"))
+	})
+
+	t.Run("Should skip bulk operations after cancellation", func(t *testing.T) {
+		_, collection := newUsersStore(t)
+
+		results, err := collection.BulkWriteContext(cancelled, []BulkOp{
+			InsertOp(userDocument("3", "Ann")),
+			DeleteOp("1"),
+		}, BulkOptions{Unordered: true})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, results[0].Err, context.Canceled)
+		assert.ErrorIs(t, results[1].Err, ErrBulkSkipped)
+		assert.Len(t, collection.List(), 2)
+	})
+}
+
+func TestStore_DumpContext(t *testing.T) {
+	t.Run("Should dump the same as Dump", func(t *testing.T) {
+		store, _ := newUsersStore(t)
+
+		expected, err := store.Dump()
+		assert.Nil(t, err)
+		dump, err := store.DumpContext(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, string(expected), string(dump))
+
+		restored, err := NewStoreFromDumpContext(context.Background(), dump)
+		assert.Nil(t, err)
+		assert.Equal(t, store, restored)
+	})
+
+	t.Run("Should abort dump and load with a cancelled context", func(t *testing.T) {
+		store, _ := newUsersStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := store.DumpContext(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		filename := filepath.Join(t.TempDir(), "dump.json")
+		assert.ErrorIs(t, store.DumpToFileContext(ctx, filename), context.Canceled)
+		assert.NoFileExists(t, filename)
+
+		dump, err := store.Dump()
+		assert.Nil(t, err)
+		_, err = NewStoreFromDumpContext(ctx, dump)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		assert.Nil(t, store.DumpToFile(filename))
+		_, err = NewStoreFromFileContext(ctx, filename)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -1,5 +1,13 @@
 package documentstore
 
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
 type DocumentFieldType string
 
 const (
@@ -8,15 +16,94 @@ const (
 	DocumentFieldTypeBool   DocumentFieldType = "bool"
 	DocumentFieldTypeArray  DocumentFieldType = "array"
 	DocumentFieldTypeObject DocumentFieldType = "object"
+	// DocumentFieldTypeGeoPoint holds a GeoPoint.
+	DocumentFieldTypeGeoPoint DocumentFieldType = "geopoint"
+	// DocumentFieldTypeDateTime holds a time.Time.
+	DocumentFieldTypeDateTime DocumentFieldType = "datetime"
+	// DocumentFieldTypeNull holds nil.
+	DocumentFieldTypeNull DocumentFieldType = "null"
+	// DocumentFieldTypeBinary holds a []byte.
+	DocumentFieldTypeBinary DocumentFieldType = "binary"
+	// DocumentFieldTypeDecimal holds a Decimal.
+	DocumentFieldTypeDecimal DocumentFieldType = "decimal"
 )
 
+// Decimal is an exact decimal number kept in its text form, e.g. "12.50".
+// It compares with other numbers by value.
+type Decimal string
+
+// ParseDecimal validates s as a decimal number.
+func ParseDecimal(s string) (Decimal, error) {
+	d := Decimal(s)
+	if _, ok := d.rat(); !ok {
+		return "", fmt.Errorf("invalid decimal %q", s)
+	}
+
+	return d, nil
+}
+
+func (d Decimal) rat() (*big.Rat, bool) {
+	if strings.Contains(string(d), "/") {
+		return nil, false
+	}
+
+	return new(big.Rat).SetString(string(d))
+}
+
 type DocumentField struct {
 	Type  DocumentFieldType `json:"type"`
 	Value interface{}       `json:"value"`
 }
 
+// UnmarshalJSON decodes values of typed fields into their Go types:
+// GeoPoint, time.Time, []byte and Decimal. Other values are decoded as by
+// json.Unmarshal into interface{}.
+func (f *DocumentField) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type  DocumentFieldType `json:"type"`
+		Value json.RawMessage   `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	f.Type = raw.Type
+	f.Value = nil
+	if len(raw.Value) == 0 {
+		return nil
+	}
+
+	switch raw.Type {
+	case DocumentFieldTypeGeoPoint:
+		return unmarshalFieldValue[GeoPoint](raw.Value, &f.Value)
+	case DocumentFieldTypeDateTime:
+		return unmarshalFieldValue[time.Time](raw.Value, &f.Value)
+	case DocumentFieldTypeBinary:
+		return unmarshalFieldValue[[]byte](raw.Value, &f.Value)
+	case DocumentFieldTypeDecimal:
+		return unmarshalFieldValue[Decimal](raw.Value, &f.Value)
+	case DocumentFieldTypeNull:
+		return nil
+	default:
+		return json.Unmarshal(raw.Value, &f.Value)
+	}
+}
+
+func unmarshalFieldValue[V any](data []byte, value *interface{}) error {
+	var v V
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*value = v
+
+	return nil
+}
+
 type Document struct {
 	Fields map[string]DocumentField `json:"fields"`
+	// ExpiresAt hides the document from reads once passed. Expired documents
+	// are removed by RemoveExpired or a Sweeper.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
 
 func (d Document) GetField(key string) interface{} {
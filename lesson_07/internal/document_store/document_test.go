@@ -1,8 +1,11 @@
 package documentstore
 
 import (
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestGetField_StringValue(t *testing.T) {
@@ -18,3 +21,68 @@ func TestGetField_StringValue(t *testing.T) {
 	value := doc.GetField("name")
 	assert.Equal(t, "123456", value)
 }
+
+func typedDocument() Document {
+	return Document{
+		Fields: map[string]DocumentField{
+			"id":        {Type: DocumentFieldTypeString, Value: "1"},
+			"createdAt": {Type: DocumentFieldTypeDateTime, Value: time.Date(2024, 5, 1, 10, 30, 0, 500, time.UTC)},
+			"deletedAt": {Type: DocumentFieldTypeNull, Value: nil},
+			"avatar":    {Type: DocumentFieldTypeBinary, Value: []byte{0, 1, 2, 255}},
+			"balance":   {Type: DocumentFieldTypeDecimal, Value: Decimal("10.25")},
+		},
+	}
+}
+
+func TestDocument_Types(t *testing.T) {
+	t.Run("Should validate typed fields", func(t *testing.T) {
+		assert.Nil(t, validateDocument(typedDocument(), nil))
+	})
+
+	t.Run("Should reject nil with other type instead of panicking", func(t *testing.T) {
+		doc := Document{Fields: map[string]DocumentField{"name": {Type: DocumentFieldTypeString, Value: nil}}}
+		assert.NotNil(t, validateDocument(doc, nil))
+	})
+
+	t.Run("Should reject invalid decimal", func(t *testing.T) {
+		doc := Document{Fields: map[string]DocumentField{"balance": {Type: DocumentFieldTypeDecimal, Value: Decimal("1/3")}}}
+		assert.NotNil(t, validateDocument(doc, nil))
+
+		_, err := ParseDecimal("ten")
+		assert.NotNil(t, err)
+		d, err := ParseDecimal("-0.5")
+		assert.Nil(t, err)
+		assert.Equal(t, Decimal("-0.5"), d)
+	})
+
+	t.Run("Should detect value types", func(t *testing.T) {
+		assert.Equal(t, DocumentFieldTypeNull, GetValueType(nil))
+		assert.Equal(t, DocumentFieldTypeNull, GetType(reflect.Invalid))
+		assert.Equal(t, DocumentFieldTypeDateTime, GetValueType(time.Now()))
+		assert.Equal(t, DocumentFieldTypeBinary, GetValueType([]byte("a")))
+		assert.Equal(t, DocumentFieldTypeDecimal, GetValueType(Decimal("1")))
+		assert.Equal(t, DocumentFieldTypeArray, GetValueType([]int{1}))
+	})
+
+	t.Run("Should round-trip typed fields through JSON", func(t *testing.T) {
+		doc := typedDocument()
+		data, err := json.Marshal(doc)
+		assert.Nil(t, err)
+
+		decoded := Document{}
+		assert.Nil(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, doc, decoded)
+	})
+
+	t.Run("Should keep typed fields in dump", func(t *testing.T) {
+		store := NewStore()
+		collection, _ := store.CreateCollection("items", &CollectionConfig{PrimaryKey: "id"})
+		_, err := collection.Put(typedDocument())
+		assert.Nil(t, err)
+
+		dump, _ := store.Dump()
+		restored, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+		assert.Equal(t, store, restored)
+	})
+}
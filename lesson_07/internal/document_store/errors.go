@@ -0,0 +1,175 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCode classifies errors of the store, so that callers such as an HTTP
+// layer can react to them without matching every sentinel error.
+type ErrorCode string
+
+const (
+	CodeNotFound           ErrorCode = "not_found"
+	CodeAlreadyExists      ErrorCode = "already_exists"
+	CodeInvalidArgument    ErrorCode = "invalid_argument"
+	CodeReferenceViolation ErrorCode = "reference_violation"
+	CodeReadOnly           ErrorCode = "read_only"
+	// CodeFailedPrecondition is returned for operations the collection is
+	// not configured for, e.g. a search without a text index.
+	CodeFailedPrecondition ErrorCode = "failed_precondition"
+	// CodeAborted is returned for bulk operations skipped after an error.
+	CodeAborted          ErrorCode = "aborted"
+	CodeCanceled         ErrorCode = "canceled"
+	CodeDeadlineExceeded ErrorCode = "deadline_exceeded"
+	// CodeInternal is returned for everything else, such as storage errors.
+	CodeInternal ErrorCode = "internal"
+)
+
+// errorCodes maps sentinel errors to codes; the first match wins.
+var errorCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{ErrDocumentNotFound, CodeNotFound},
+	{ErrCollectionNotFound, CodeNotFound},
+	{ErrDocumentExists, CodeAlreadyExists},
+	{ErrCollectionExists, CodeAlreadyExists},
+	{ErrReferenceViolation, CodeReferenceViolation},
+	{ErrReadOnly, CodeReadOnly},
+	{ErrValidationFailed, CodeInvalidArgument},
+	{ErrInvalidCollectionConfig, CodeInvalidArgument},
+	{ErrInvalidJSONSchema, CodeInvalidArgument},
+	{ErrInvalidCollectionName, CodeInvalidArgument},
+	{ErrInvalidUpdate, CodeInvalidArgument},
+	{ErrInvalidPopulate, CodeInvalidArgument},
+	{ErrInvalidProjection, CodeInvalidArgument},
+	{ErrInvalidStage, CodeInvalidArgument},
+	{ErrInvalidGeoQuery, CodeInvalidArgument},
+	{ErrInvalidMigration, CodeInvalidArgument},
+	{ErrInvalidInputType, CodeInvalidArgument},
+	{ErrInvalidOutputType, CodeInvalidArgument},
+	{ErrUnmarshalDocument, CodeInvalidArgument},
+	{ErrNoTextIndex, CodeFailedPrecondition},
+	{ErrNoGeoIndex, CodeFailedPrecondition},
+	{ErrHistoryDisabled, CodeFailedPrecondition},
+	{ErrMigrationFailed, CodeFailedPrecondition},
+	{ErrBulkSkipped, CodeAborted},
+	{context.Canceled, CodeCanceled},
+	{context.DeadlineExceeded, CodeDeadlineExceeded},
+}
+
+// Error is an error of an operation on a collection. It tells what failed
+// through Code and where through Collection, ID and Field, which are empty
+// when unknown; Err is the cause, usually wrapping a sentinel error such as
+// ErrDocumentNotFound.
+//
+// Besides errors.As, an Error matches errors.Is with an *Error target whose
+// non-empty fields are equal, e.g. errors.Is(err, &Error{Code: CodeNotFound}).
+type Error struct {
+	Code       ErrorCode
+	Collection string
+	ID         string
+	// Field is a dotted path, e.g. "address.city".
+	Field string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	var where []string
+	if e.Collection != "" {
+		where = append(where, fmt.Sprintf("collection %q", e.Collection))
+	}
+	if e.ID != "" {
+		where = append(where, fmt.Sprintf("document '%s'", e.ID))
+	}
+	if e.Field != "" {
+		where = append(where, "field "+e.Field)
+	}
+	// Targets of errors.Is may have no cause.
+	cause := string(e.Code)
+	if e.Err != nil {
+		cause = e.Err.Error()
+	}
+	if len(where) == 0 {
+		return cause
+	}
+
+	return strings.Join(where, ", ") + ": " + cause
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+
+	return (t.Code == "" || t.Code == e.Code) &&
+		(t.Collection == "" || t.Collection == e.Collection) &&
+		(t.ID == "" || t.ID == e.ID) &&
+		(t.Field == "" || t.Field == e.Field)
+}
+
+// ErrorCodeOf returns the code of err: the code of the outermost *Error, or
+// the code of the sentinel error err wraps. It is empty for a nil error.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) && e.Code != "" {
+		return e.Code
+	}
+
+	return codeOf(err)
+}
+
+func codeOf(err error) ErrorCode {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+
+	return CodeInternal
+}
+
+// newError annotates err with where it happened. An *Error is completed
+// rather than wrapped again.
+func newError(collection string, id string, field string, err error) *Error {
+	if e, ok := err.(*Error); ok {
+		annotated := *e
+		if annotated.Collection == "" {
+			annotated.Collection = collection
+		}
+		if annotated.ID == "" {
+			annotated.ID = id
+		}
+		if annotated.Field == "" {
+			annotated.Field = field
+		}
+		return &annotated
+	}
+
+	return &Error{Code: codeOf(err), Collection: collection, ID: id, Field: field, Err: err}
+}
+
+// docError annotates err with the collection and a document of it.
+func (s *Collection) docError(id string, field string, err error) *Error {
+	return newError(s.name, id, field, err)
+}
+
+// writable fails for read-only collections, such as those of a Snapshot.
+func (s *Collection) writable() error {
+	if s.readOnly {
+		return s.docError("", "", ErrReadOnly)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	t.Run("Should describe where an error happened", func(t *testing.T) {
+		store, users := newUsersStore(t)
+
+		_, err := users.Put(userDocument("1", "John"))
+		var e *Error
+		assert.True(t, errors.As(err, &e))
+		assert.Equal(t, &Error{Code: CodeAlreadyExists, Collection: "users", ID: "1", Err: ErrDocumentExists}, e)
+		assert.ErrorIs(t, err, ErrDocumentExists)
+		assert.Equal(t, `collection "users", document '1': document already exists`, err.Error())
+
+		_, err = users.Get("3")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+		assert.ErrorIs(t, err, &Error{Code: CodeNotFound, Collection: "users", ID: "3"})
+		assert.NotErrorIs(t, err, &Error{Code: CodeNotFound, ID: "1"})
+
+		err = store.DeleteCollection("orders")
+		assert.ErrorIs(t, err, &Error{Code: CodeNotFound, Collection: "orders"})
+	})
+
+	t.Run("Should point to invalid fields", func(t *testing.T) {
+		_, users := newUsersStore(t)
+
+		doc := userDocument("3", "Ann")
+		doc.Fields["age"] = DocumentField{Type: DocumentFieldTypeString, Value: 30}
+		_, err := users.Put(doc)
+		assert.ErrorIs(t, err, ErrValidationFailed)
+		assert.Equal(t, CodeInvalidArgument, ErrorCodeOf(err))
+		assert.ErrorIs(t, err, &Error{Collection: "users", ID: "3"})
+		assert.ErrorIs(t, err, &Error{Field: "age"})
+
+		_, err = users.Update("1", Update{Inc: map[string]float64{"name": 1}})
+		assert.ErrorIs(t, err, ErrInvalidUpdate)
+		assert.ErrorIs(t, err, &Error{Code: CodeInvalidArgument, Collection: "users", ID: "1", Field: "name"})
+	})
+
+	t.Run("Should point to violated references", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceRestrict)
+		books, _ := store.GetCollection("books")
+
+		_, err := books.Put(bookDocument("b3", "a9"))
+		assert.ErrorIs(t, err, &Error{Code: CodeReferenceViolation, Collection: "books", ID: "b3", Field: "author"})
+	})
+
+	t.Run("Should classify errors by code", func(t *testing.T) {
+		assert.Equal(t, ErrorCode(""), ErrorCodeOf(nil))
+		assert.Equal(t, CodeCanceled, ErrorCodeOf(fmt.Errorf("list: %w", context.Canceled)))
+		assert.Equal(t, CodeFailedPrecondition, ErrorCodeOf(ErrNoTextIndex))
+		assert.Equal(t, CodeInternal, ErrorCodeOf(errors.New("disk is full")))
+
+		_, err := NewCollection(&CollectionConfig{PrimaryKey: "id"}).Search("john", SearchOptions{})
+		assert.Equal(t, CodeFailedPrecondition, ErrorCodeOf(err))
+	})
+}
@@ -0,0 +1,265 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PlanKind is how an aggregation reads the documents of its collection.
+type PlanKind string
+
+const (
+	// PlanFullScan reads every document.
+	PlanFullScan PlanKind = "full_scan"
+	// PlanPrimaryKey reads the documents whose primary keys a Match stage
+	// compares with Eq or In.
+	PlanPrimaryKey PlanKind = "primary_key"
+	// PlanGeoIndex reads the documents the geo index finds for a
+	// WithinRadius or WithinBox filter of a Match stage.
+	PlanGeoIndex PlanKind = "geo_index"
+)
+
+// QueryPlan describes how an aggregation ran, see Collection.Explain.
+type QueryPlan struct {
+	Kind PlanKind
+	// Index is the field of the primary key or of the geo index used; empty
+	// for a full scan.
+	Index string
+	// Filter is the shape of the leading Match stages: fields and operators
+	// without values, e.g. "and(age gt ?, exists(email))".
+	Filter string
+	// Estimated is the number of documents the plan expected to read.
+	Estimated int
+	// Scanned is the number of documents read from the collection.
+	Scanned int
+	// Returned is the number of documents produced by the pipeline.
+	Returned int
+
+	keys []string
+	ids  []string
+}
+
+// Explain runs stages like Aggregate and reports the plan used to read the
+// collection with the numbers of documents expected, read and returned.
+func (s *Collection) Explain(stages ...Stage) (*QueryPlan, error) {
+	return s.ExplainContext(context.Background(), stages...)
+}
+
+// ExplainContext is Explain that gives up when ctx is done.
+func (s *Collection) ExplainContext(ctx context.Context, stages ...Stage) (*QueryPlan, error) {
+	collectionLogger().DebugContext(ctx, "Explain aggregation", "stages", len(stages))
+	p := &pipeline{ctx: ctx, source: s}
+	if _, err := runPipeline(p, stages); err != nil {
+		return nil, err
+	}
+
+	return p.plan, nil
+}
+
+// Explain runs stages over the named collection like Aggregate and reports
+// the plan used to read it.
+func (s *Store) Explain(collection string, stages ...Stage) (*QueryPlan, error) {
+	return s.ExplainContext(context.Background(), collection, stages...)
+}
+
+// ExplainContext is Explain that gives up when ctx is done.
+func (s *Store) ExplainContext(ctx context.Context, collection string, stages ...Stage) (*QueryPlan, error) {
+	storeLogger().DebugContext(ctx, "Explain store aggregation", "collection", collection, "stages", len(stages))
+	source, ok := s.GetCollection(collection)
+	if !ok {
+		return nil, newError(collection, "", "", ErrCollectionNotFound)
+	}
+
+	p := &pipeline{ctx: ctx, collection: s.GetCollection, source: source}
+	if _, err := runPipeline(p, stages); err != nil {
+		return nil, err
+	}
+
+	return p.plan, nil
+}
+
+// planQuery chooses how to read the documents for stages. Only the Match
+// stages before any other stage see the stored documents, so only they can
+// narrow the read; they still run over what the plan reads.
+func (s *Collection) planQuery(stages []Stage) *QueryPlan {
+	var filters []Filter
+	for _, stage := range stages {
+		match, ok := stage.(matchStage)
+		if !ok {
+			break
+		}
+		filters = append(filters, match.filter)
+	}
+
+	var filter Filter
+	switch len(filters) {
+	case 0:
+	case 1:
+		filter = filters[0]
+	default:
+		filter = AndFilter(filters)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	plan := &QueryPlan{Kind: PlanFullScan, Estimated: s.documents.len()}
+	if filter == nil {
+		return plan
+	}
+	plan.Filter = filterShape(filter)
+
+	if keys, ok := s.primaryKeys(filter); ok {
+		plan.Kind, plan.Index, plan.keys = PlanPrimaryKey, s.cfg.PrimaryKey, keys
+		plan.Estimated = len(keys)
+	} else if box, ok := s.geoBox(filter); ok {
+		plan.Kind, plan.Index = PlanGeoIndex, s.geoIndex.field
+		s.geoIndex.within(box, func(id string, _ GeoPoint) {
+			plan.ids = append(plan.ids, id)
+		})
+		slices.Sort(plan.ids)
+		plan.ids = slices.Compact(plan.ids)
+		plan.Estimated = len(plan.ids)
+	}
+
+	return plan
+}
+
+// primaryKeys returns the sorted primary keys a filter is limited to, when
+// it compares the primary key with strings.
+func (s *Collection) primaryKeys(filter Filter) ([]string, bool) {
+	switch f := filter.(type) {
+	case Condition:
+		if f.Field != s.cfg.PrimaryKey {
+			return nil, false
+		}
+		var values []interface{}
+		switch f.Operator {
+		case OperatorEq:
+			values = []interface{}{f.Value}
+		case OperatorIn:
+			values, _ = normalizeContainer(f.Value).([]interface{})
+		default:
+			return nil, false
+		}
+		keys := make([]string, 0, len(values))
+		for _, value := range values {
+			key, ok := value.(string)
+			if !ok {
+				return nil, false
+			}
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		return slices.Compact(keys), true
+	case AndFilter:
+		for _, child := range f {
+			if keys, ok := s.primaryKeys(child); ok {
+				return keys, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// geoBox returns a box the geo index can look up for a filter on the
+// indexed field.
+func (s *Collection) geoBox(filter Filter) (GeoBox, bool) {
+	if s.geoIndex == nil {
+		return GeoBox{}, false
+	}
+
+	switch f := filter.(type) {
+	case GeoRadiusFilter:
+		if f.Field == s.geoIndex.field && f.Center.valid() && f.Meters >= 0 {
+			return radiusBox(f.Center, f.Meters), true
+		}
+	case GeoBoxFilter:
+		box := f.Box
+		if f.Field == s.geoIndex.field && box.SouthWest.valid() && box.NorthEast.valid() && box.SouthWest.Lat <= box.NorthEast.Lat {
+			return box, true
+		}
+	case AndFilter:
+		for _, child := range f {
+			if box, ok := s.geoBox(child); ok {
+				return box, true
+			}
+		}
+	}
+
+	return GeoBox{}, false
+}
+
+// readPlan returns the documents of the plan ordered by primary key, like
+// list, and records how many were read.
+func (s *Collection) readPlan(ctx context.Context, plan *QueryPlan) ([]Document, error) {
+	if plan.Kind == PlanFullScan {
+		docs, err := s.list(ctx)
+		plan.Scanned = len(docs)
+		return docs, err
+	}
+
+	ids := plan.ids
+	if plan.Kind == PlanPrimaryKey {
+		ids = plan.keys
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := make([]Document, 0, len(ids))
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		doc, err := s.get(id)
+		if errors.Is(err, ErrDocumentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *doc)
+	}
+	plan.Scanned = len(docs)
+
+	return docs, nil
+}
+
+// filterShape describes a filter without its values, so that queries
+// differing only in values have the same shape.
+func filterShape(filter Filter) string {
+	switch f := filter.(type) {
+	case Condition:
+		return fmt.Sprintf("%s %s ?", f.Field, f.Operator)
+	case ExistsFilter:
+		return fmt.Sprintf("exists(%s)", f.Field)
+	case RegexFilter:
+		return fmt.Sprintf("%s regex ?", f.Field)
+	case GeoRadiusFilter:
+		return fmt.Sprintf("%s within_radius ?", f.Field)
+	case GeoBoxFilter:
+		return fmt.Sprintf("%s within_box ?", f.Field)
+	case AndFilter:
+		return "and(" + filterShapes(f) + ")"
+	case OrFilter:
+		return "or(" + filterShapes(f) + ")"
+	case NotFilter:
+		return "not(" + filterShape(f.Filter) + ")"
+	default:
+		return "custom"
+	}
+}
+
+func filterShapes(filters []Filter) string {
+	shapes := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		shapes = append(shapes, filterShape(filter))
+	}
+
+	return strings.Join(shapes, ", ")
+}
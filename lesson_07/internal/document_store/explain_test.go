@@ -0,0 +1,92 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestCollection_Explain(t *testing.T) {
+	t.Run("Should explain a full scan", func(t *testing.T) {
+		_, users := newUsersStore(t)
+		users.Put(userDocument("3", "Jack"))
+
+		plan, err := users.Explain(Match(Regex("name", regexp.MustCompile("^J.*k$"))), Limit(10))
+		assert.Nil(t, err)
+		assert.Equal(t, PlanFullScan, plan.Kind)
+		assert.Equal(t, "", plan.Index)
+		assert.Equal(t, "name regex ?", plan.Filter)
+		assert.Equal(t, 3, plan.Estimated)
+		assert.Equal(t, 3, plan.Scanned)
+		assert.Equal(t, 1, plan.Returned)
+	})
+
+	t.Run("Should read documents by primary key", func(t *testing.T) {
+		_, users := newUsersStore(t)
+
+		plan, err := users.Explain(Match(In("id", "2", "missing", "2")), Match(Exists("name")))
+		assert.Nil(t, err)
+		assert.Equal(t, PlanPrimaryKey, plan.Kind)
+		assert.Equal(t, "id", plan.Index)
+		assert.Equal(t, "and(id in ?, exists(name))", plan.Filter)
+		assert.Equal(t, 2, plan.Estimated)
+		assert.Equal(t, 1, plan.Scanned)
+		assert.Equal(t, 1, plan.Returned)
+
+		docs, err := users.Aggregate(Match(And(Eq("id", "1"), Eq("name", "Jane"))))
+		assert.Nil(t, err)
+		assert.Empty(t, docs)
+	})
+
+	t.Run("Should read documents with the geo index", func(t *testing.T) {
+		places := newPlaces(t)
+
+		plan, err := places.Explain(Match(WithinRadius("location", kyiv, 30_000)))
+		assert.Nil(t, err)
+		assert.Equal(t, PlanGeoIndex, plan.Kind)
+		assert.Equal(t, "location", plan.Index)
+		assert.Equal(t, "location within_radius ?", plan.Filter)
+		assert.Equal(t, 2, plan.Scanned)
+		assert.Equal(t, 2, plan.Returned)
+
+		docs, err := places.Aggregate(Match(WithinBox("location", GeoBox{SouthWest: GeoPoint{Lat: -20, Lon: 170}, NorthEast: GeoPoint{Lat: -10, Lon: -170}})))
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"fiji", "samoa"}, []interface{}{docs[0].GetField("id"), docs[1].GetField("id")})
+	})
+
+	t.Run("Should scan when a filter does not use an index", func(t *testing.T) {
+		places := newPlaces(t)
+
+		plan, err := places.Explain(Limit(1), Match(Eq("id", "kyiv")))
+		assert.Nil(t, err)
+		assert.Equal(t, PlanFullScan, plan.Kind)
+		assert.Equal(t, "", plan.Filter)
+		assert.Equal(t, 6, plan.Scanned)
+
+		plan, err = places.Explain(Match(Or(Eq("id", "kyiv"), Eq("id", "lviv"))))
+		assert.Nil(t, err)
+		assert.Equal(t, PlanFullScan, plan.Kind)
+		assert.Equal(t, "or(id eq ?, id eq ?)", plan.Filter)
+		assert.Equal(t, 2, plan.Returned)
+	})
+
+	t.Run("Should explain with a store", func(t *testing.T) {
+		store, _ := newUsersStore(t)
+
+		plan, err := store.Explain("users", Match(Eq("id", "1")))
+		assert.Nil(t, err)
+		assert.Equal(t, PlanPrimaryKey, plan.Kind)
+		assert.Equal(t, 1, plan.Returned)
+
+		_, err = store.Explain("missing")
+		assert.ErrorIs(t, err, ErrCollectionNotFound)
+	})
+}
+
+func TestFilterShape(t *testing.T) {
+	t.Run("Should describe filters without values", func(t *testing.T) {
+		filter := And(Gt("age", 18), Not(Or(Exists("email"), In("role", "admin"))), FilterFunc(func(Document) bool { return true }))
+
+		assert.Equal(t, "and(age gt ?, not(or(exists(email), role in ?)), custom)", filterShape(filter))
+	})
+}
@@ -0,0 +1,180 @@
+package documentstore
+
+import "regexp"
+
+// Filter selects documents. Field names are dotted paths.
+type Filter interface {
+	Match(doc Document) bool
+}
+
+// FilterFunc adapts a function to a Filter.
+type FilterFunc func(doc Document) bool
+
+func (f FilterFunc) Match(doc Document) bool {
+	return f(doc)
+}
+
+// Operator is a comparison of a field with a value.
+type Operator string
+
+const (
+	OperatorEq  Operator = "eq"
+	OperatorNe  Operator = "ne"
+	OperatorGt  Operator = "gt"
+	OperatorGte Operator = "gte"
+	OperatorLt  Operator = "lt"
+	OperatorLte Operator = "lte"
+	OperatorIn  Operator = "in"
+)
+
+// Condition compares a field with a value. Ordering operators only match
+// values of the same type; numbers of different Go types are comparable.
+type Condition struct {
+	Field    string
+	Operator Operator
+	Value    interface{}
+}
+
+func (c Condition) Match(doc Document) bool {
+	value, ok := lookupPath(doc, c.Field)
+	if !ok {
+		return c.Operator == OperatorNe
+	}
+
+	switch c.Operator {
+	case OperatorEq:
+		return valuesEqual(value, c.Value)
+	case OperatorNe:
+		return !valuesEqual(value, c.Value)
+	case OperatorIn:
+		values, _ := normalizeContainer(c.Value).([]interface{})
+		for _, v := range values {
+			if valuesEqual(value, v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if valueRank(value) != valueRank(c.Value) {
+		return false
+	}
+	cmp := compareValues(value, c.Value)
+	switch c.Operator {
+	case OperatorGt:
+		return cmp > 0
+	case OperatorGte:
+		return cmp >= 0
+	case OperatorLt:
+		return cmp < 0
+	case OperatorLte:
+		return cmp <= 0
+	default:
+		return false
+	}
+}
+
+func Eq(field string, value interface{}) Condition {
+	return Condition{Field: field, Operator: OperatorEq, Value: value}
+}
+
+func Ne(field string, value interface{}) Condition {
+	return Condition{Field: field, Operator: OperatorNe, Value: value}
+}
+
+func Gt(field string, value interface{}) Condition {
+	return Condition{Field: field, Operator: OperatorGt, Value: value}
+}
+
+func Gte(field string, value interface{}) Condition {
+	return Condition{Field: field, Operator: OperatorGte, Value: value}
+}
+
+func Lt(field string, value interface{}) Condition {
+	return Condition{Field: field, Operator: OperatorLt, Value: value}
+}
+
+func Lte(field string, value interface{}) Condition {
+	return Condition{Field: field, Operator: OperatorLte, Value: value}
+}
+
+func In(field string, values ...interface{}) Condition {
+	return Condition{Field: field, Operator: OperatorIn, Value: values}
+}
+
+// ExistsFilter matches documents that have the field.
+type ExistsFilter struct {
+	Field string
+}
+
+func Exists(field string) ExistsFilter {
+	return ExistsFilter{Field: field}
+}
+
+func (f ExistsFilter) Match(doc Document) bool {
+	_, ok := lookupPath(doc, f.Field)
+	return ok
+}
+
+// RegexFilter matches string fields against a regular expression.
+type RegexFilter struct {
+	Field   string
+	Pattern *regexp.Regexp
+}
+
+func Regex(field string, pattern *regexp.Regexp) RegexFilter {
+	return RegexFilter{Field: field, Pattern: pattern}
+}
+
+func (f RegexFilter) Match(doc Document) bool {
+	value, ok := lookupPath(doc, f.Field)
+	s, isString := value.(string)
+	return ok && isString && f.Pattern.MatchString(s)
+}
+
+// AndFilter matches documents matching all filters.
+type AndFilter []Filter
+
+func And(filters ...Filter) AndFilter {
+	return filters
+}
+
+func (f AndFilter) Match(doc Document) bool {
+	for _, filter := range f {
+		if !filter.Match(doc) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// OrFilter matches documents matching any of the filters.
+type OrFilter []Filter
+
+func Or(filters ...Filter) OrFilter {
+	return filters
+}
+
+func (f OrFilter) Match(doc Document) bool {
+	for _, filter := range f {
+		if filter.Match(doc) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NotFilter inverts a filter.
+type NotFilter struct {
+	Filter Filter
+}
+
+func Not(filter Filter) NotFilter {
+	return NotFilter{Filter: filter}
+}
+
+func (f NotFilter) Match(doc Document) bool {
+	return !f.Filter.Match(doc)
+}
@@ -0,0 +1,63 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFilter_Match(t *testing.T) {
+	doc := Document{
+		Fields: map[string]DocumentField{
+			"id":      {Type: DocumentFieldTypeString, Value: "1"},
+			"age":     {Type: DocumentFieldTypeNumber, Value: 30},
+			"active":  {Type: DocumentFieldTypeBool, Value: true},
+			"tags":    {Type: DocumentFieldTypeArray, Value: []interface{}{"a", "b"}},
+			"address": {Type: DocumentFieldTypeObject, Value: map[string]interface{}{"city": "Kyiv", "zip": 1001.0}},
+			"born":    {Type: DocumentFieldTypeDateTime, Value: time.Date(1994, 1, 2, 0, 0, 0, 0, time.UTC)},
+			"balance": {Type: DocumentFieldTypeDecimal, Value: Decimal("0.30")},
+			"avatar":  {Type: DocumentFieldTypeBinary, Value: []byte{1, 2}},
+			"deleted": {Type: DocumentFieldTypeNull, Value: nil},
+		},
+	}
+
+	cases := map[string]struct {
+		filter   Filter
+		expected bool
+	}{
+		"eq number of other type":  {Eq("age", 30.0), true},
+		"eq mismatch":              {Eq("age", 31), false},
+		"ne missing field":         {Ne("missing", 1), true},
+		"gt":                       {Gt("age", 29), true},
+		"gte":                      {Gte("age", 30), true},
+		"lt":                       {Lt("age", 30), false},
+		"lte string":               {Lte("id", "1"), true},
+		"ordering other type":      {Gt("age", "1"), false},
+		"in":                       {In("address.city", "Lviv", "Kyiv"), true},
+		"nested path":              {Eq("address.zip", 1001), true},
+		"array index":              {Eq("tags.1", "b"), true},
+		"array index out of range": {Exists("tags.2"), false},
+		"exists":                   {Exists("active"), true},
+		"regex":                    {Regex("address.city", regexp.MustCompile("^K")), true},
+		"and":                      {And(Eq("active", true), Gt("age", 40)), false},
+		"or":                       {Or(Eq("active", false), Gt("age", 20)), true},
+		"not":                      {Not(Eq("active", false)), true},
+		"func":                     {FilterFunc(func(d Document) bool { return len(d.Fields) == 9 }), true},
+		"datetime before":          {Lt("born", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)), true},
+		"datetime equal in zone":   {Eq("born", time.Date(1994, 1, 2, 2, 0, 0, 0, time.FixedZone("EET", 2*60*60))), true},
+		"datetime with string":     {Gt("born", "1990"), false},
+		"decimal exactly":          {Eq("balance", Decimal("0.3")), true},
+		"decimal with number":      {Gt("balance", 0.29), true},
+		"decimal not float sum":    {Eq("balance", 0.1+0.2), false},
+		"binary":                   {Eq("avatar", []byte{1, 2}), true},
+		"null":                     {Eq("deleted", nil), true},
+		"null is not missing":      {Exists("deleted"), true},
+	}
+
+	for name, c := range cases {
+		t.Run("Should match "+name, func(t *testing.T) {
+			assert.Equal(t, c.expected, c.filter.Match(doc))
+		})
+	}
+}
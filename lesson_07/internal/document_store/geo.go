@@ -0,0 +1,458 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+var ErrNoGeoIndex = errors.New("collection has no geo index")
+var ErrInvalidGeoQuery = errors.New("invalid geo query")
+
+// GeoPoint is the value of a DocumentFieldTypeGeoPoint field, in degrees.
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+func (p GeoPoint) valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// GeoBox is an area between two corners. A box whose west longitude is
+// greater than the east one crosses the antimeridian.
+type GeoBox struct {
+	SouthWest GeoPoint
+	NorthEast GeoPoint
+}
+
+func (b GeoBox) contains(p GeoPoint) bool {
+	if p.Lat < b.SouthWest.Lat || p.Lat > b.NorthEast.Lat {
+		return false
+	}
+	if b.SouthWest.Lon <= b.NorthEast.Lon {
+		return p.Lon >= b.SouthWest.Lon && p.Lon <= b.NorthEast.Lon
+	}
+
+	return p.Lon >= b.SouthWest.Lon || p.Lon <= b.NorthEast.Lon
+}
+
+// GeoIndexConfig enables geo queries over a geopoint field. The index is kept
+// in memory and rebuilt when the collection is opened or loaded.
+type GeoIndexConfig struct {
+	Field string `json:"field"`
+}
+
+// GeoResult is a document found by a geo query with its distance in meters
+// from the query center.
+type GeoResult struct {
+	Document Document
+	Distance float64
+}
+
+const earthRadius = 6371008.8
+
+// Distance returns the great-circle distance between points in meters.
+func Distance(a GeoPoint, b GeoPoint) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+const (
+	geohashAlphabet  = "0123456789bcdefghjkmnpqrstuvwxyz"
+	geohashPrecision = 12
+	// geoMaxCells bounds the number of cells a query scans.
+	geoMaxCells = 32
+)
+
+// geohashCell encodes a cell given by its row and column at a precision.
+func geohashCell(latIdx, lonIdx uint64, precision int) string {
+	bits := 5 * precision
+	lonBits, latBits := (bits+1)/2, bits/2
+
+	hash := make([]byte, 0, precision)
+	var ch byte
+	for i := 0; i < bits; i++ {
+		var bit uint64
+		if i%2 == 0 {
+			lonBits--
+			bit = lonIdx >> lonBits & 1
+		} else {
+			latBits--
+			bit = latIdx >> latBits & 1
+		}
+		ch = ch<<1 | byte(bit)
+		if i%5 == 4 {
+			hash = append(hash, geohashAlphabet[ch])
+			ch = 0
+		}
+	}
+
+	return string(hash)
+}
+
+// geoCellIndex returns the row and column of the cell holding a point.
+func geoCellIndex(lat, lon float64, precision int) (uint64, uint64) {
+	bits := 5 * precision
+	lonCells, latCells := float64(uint64(1)<<((bits+1)/2)), float64(uint64(1)<<(bits/2))
+
+	latIdx := math.Min(math.Floor((lat+90)/180*latCells), latCells-1)
+	lonIdx := math.Min(math.Floor((lon+180)/360*lonCells), lonCells-1)
+
+	return uint64(math.Max(latIdx, 0)), uint64(math.Max(lonIdx, 0))
+}
+
+func geohash(p GeoPoint) string {
+	latIdx, lonIdx := geoCellIndex(p.Lat, p.Lon, geohashPrecision)
+	return geohashCell(latIdx, lonIdx, geohashPrecision)
+}
+
+// coveringCells returns geohash prefixes of cells covering a box that does
+// not cross the antimeridian, at the finest precision within geoMaxCells.
+func coveringCells(box GeoBox) []string {
+	for precision := geohashPrecision; precision > 0; precision-- {
+		minLat, minLon := geoCellIndex(box.SouthWest.Lat, box.SouthWest.Lon, precision)
+		maxLat, maxLon := geoCellIndex(box.NorthEast.Lat, box.NorthEast.Lon, precision)
+		if (maxLat-minLat+1)*(maxLon-minLon+1) > geoMaxCells && precision > 1 {
+			continue
+		}
+
+		var cells []string
+		for lat := minLat; lat <= maxLat; lat++ {
+			for lon := minLon; lon <= maxLon; lon++ {
+				cells = append(cells, geohashCell(lat, lon, precision))
+			}
+		}
+		return cells
+	}
+
+	return nil
+}
+
+type geoEntry struct {
+	hash string
+	id   string
+}
+
+func compareGeoEntries(a, b geoEntry) int {
+	if c := strings.Compare(a.hash, b.hash); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.id, b.id)
+}
+
+// geoIndex keeps points ordered by geohash, so the points of a cell are a
+// contiguous range found by its prefix.
+type geoIndex struct {
+	field   string
+	entries []geoEntry
+	points  map[string]GeoPoint
+}
+
+func newGeoIndex(cfg *GeoIndexConfig) *geoIndex {
+	return &geoIndex{field: cfg.Field, points: map[string]GeoPoint{}}
+}
+
+func (idx *geoIndex) point(doc Document) (GeoPoint, bool) {
+	value, _ := lookupPath(doc, idx.field)
+	p, ok := value.(GeoPoint)
+	return p, ok && p.valid()
+}
+
+func (idx *geoIndex) add(id string, doc Document) {
+	p, ok := idx.point(doc)
+	if !ok {
+		return
+	}
+
+	entry := geoEntry{hash: geohash(p), id: id}
+	i, _ := slices.BinarySearchFunc(idx.entries, entry, compareGeoEntries)
+	idx.entries = slices.Insert(idx.entries, i, entry)
+	idx.points[id] = p
+}
+
+func (idx *geoIndex) remove(id string) {
+	p, ok := idx.points[id]
+	if !ok {
+		return
+	}
+
+	if i, found := slices.BinarySearchFunc(idx.entries, geoEntry{hash: geohash(p), id: id}, compareGeoEntries); found {
+		idx.entries = slices.Delete(idx.entries, i, i+1)
+	}
+	delete(idx.points, id)
+}
+
+// within calls fn for every indexed point inside the box.
+func (idx *geoIndex) within(box GeoBox, fn func(id string, p GeoPoint)) {
+	boxes := []GeoBox{box}
+	if box.SouthWest.Lon > box.NorthEast.Lon {
+		boxes = []GeoBox{
+			{SouthWest: box.SouthWest, NorthEast: GeoPoint{Lat: box.NorthEast.Lat, Lon: 180}},
+			{SouthWest: GeoPoint{Lat: box.SouthWest.Lat, Lon: -180}, NorthEast: box.NorthEast},
+		}
+	}
+
+	for _, b := range boxes {
+		for _, cell := range coveringCells(b) {
+			i, _ := slices.BinarySearchFunc(idx.entries, geoEntry{hash: cell}, compareGeoEntries)
+			for ; i < len(idx.entries) && strings.HasPrefix(idx.entries[i].hash, cell); i++ {
+				id := idx.entries[i].id
+				if p := idx.points[id]; b.contains(p) {
+					fn(id, p)
+				}
+			}
+		}
+	}
+}
+
+// radiusBox returns a box enclosing the circle around center.
+func radiusBox(center GeoPoint, meters float64) GeoBox {
+	angle := meters / earthRadius
+	dLat := angle * 180 / math.Pi
+	box := GeoBox{
+		SouthWest: GeoPoint{Lat: math.Max(center.Lat-dLat, -90), Lon: -180},
+		NorthEast: GeoPoint{Lat: math.Min(center.Lat+dLat, 90), Lon: 180},
+	}
+
+	// Near a pole or for huge circles every longitude may be in range.
+	sinLon := math.Sin(angle) / math.Cos(center.Lat*math.Pi/180)
+	if angle >= math.Pi/2 || box.SouthWest.Lat == -90 || box.NorthEast.Lat == 90 || sinLon >= 1 {
+		return box
+	}
+
+	dLon := math.Asin(sinLon) * 180 / math.Pi
+	box.SouthWest.Lon = center.Lon - dLon
+	box.NorthEast.Lon = center.Lon + dLon
+	if box.SouthWest.Lon < -180 {
+		box.SouthWest.Lon += 360
+	}
+	if box.NorthEast.Lon > 180 {
+		box.NorthEast.Lon -= 360
+	}
+
+	return box
+}
+
+// withinRadius returns IDs of points not farther than meters from center
+// with their distances, nearest first.
+func (idx *geoIndex) withinRadius(center GeoPoint, meters float64) []geoMatch {
+	var matches []geoMatch
+	idx.within(radiusBox(center, meters), func(id string, p GeoPoint) {
+		if d := Distance(center, p); d <= meters {
+			matches = append(matches, geoMatch{id: id, distance: d})
+		}
+	})
+	slices.SortFunc(matches, func(a, b geoMatch) int {
+		if a.distance != b.distance {
+			if a.distance < b.distance {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.id, b.id)
+	})
+
+	return matches
+}
+
+type geoMatch struct {
+	id       string
+	distance float64
+}
+
+// buildGeoIndex indexes every stored document. Must be called with s.mu
+// held, or before the collection is shared.
+func (s *Collection) buildGeoIndex() error {
+	if s.cfg.GeoIndex == nil {
+		s.geoIndex = nil
+		return nil
+	}
+
+	s.geoIndex = newGeoIndex(s.cfg.GeoIndex)
+	return s.documents.scan("", func(id string, doc Document) bool {
+		s.geoIndex.add(id, doc)
+		return true
+	})
+}
+
+// WithinRadius returns documents whose indexed point is not farther than
+// meters from center, nearest first.
+func (s *Collection) WithinRadius(center GeoPoint, meters float64) (_ []GeoResult, err error) {
+	collectionLogger().Debug("Find documents within radius", "center", center, "meters", meters)
+	ctx, o := s.startOperation(context.Background(), OpWithinRadius)
+	defer o.end(&err)
+	if !center.valid() || meters < 0 || math.IsNaN(meters) {
+		return nil, s.docError("", "", fmt.Errorf("%w: center %v, radius %v", ErrInvalidGeoQuery, center, meters))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.geoIndex == nil {
+		return nil, s.docError("", "", ErrNoGeoIndex)
+	}
+
+	span := startIndexSpan(ctx, "geo")
+	matches := s.geoIndex.withinRadius(center, meters)
+	span.End()
+	o.filter = filterShape(GeoRadiusFilter{Field: s.geoIndex.field})
+	o.scanned(len(matches))
+
+	results, err := s.geoResults(matches, -1)
+	o.returned(len(results))
+
+	return results, err
+}
+
+// WithinBox returns documents whose indexed point lies in the box, ordered by
+// primary key.
+func (s *Collection) WithinBox(box GeoBox) (_ []Document, err error) {
+	collectionLogger().Debug("Find documents within box", "box", box)
+	ctx, o := s.startOperation(context.Background(), OpWithinBox)
+	defer o.end(&err)
+	if !box.SouthWest.valid() || !box.NorthEast.valid() || box.SouthWest.Lat > box.NorthEast.Lat {
+		return nil, s.docError("", "", fmt.Errorf("%w: box %v", ErrInvalidGeoQuery, box))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.geoIndex == nil {
+		return nil, s.docError("", "", ErrNoGeoIndex)
+	}
+
+	span := startIndexSpan(ctx, "geo")
+	var ids []string
+	s.geoIndex.within(box, func(id string, _ GeoPoint) {
+		ids = append(ids, id)
+	})
+	span.End()
+	o.filter = filterShape(GeoBoxFilter{Field: s.geoIndex.field})
+	o.scanned(len(ids))
+	slices.Sort(ids)
+
+	docs := make([]Document, 0, len(ids))
+	for _, id := range ids {
+		doc, err := s.get(id)
+		if errors.Is(err, ErrDocumentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *doc)
+	}
+	o.returned(len(docs))
+
+	return docs, nil
+}
+
+// Nearest returns up to n documents nearest to center.
+func (s *Collection) Nearest(center GeoPoint, n int) (_ []GeoResult, err error) {
+	collectionLogger().Debug("Find nearest documents", "center", center, "n", n)
+	ctx, o := s.startOperation(context.Background(), OpNearest)
+	defer o.end(&err)
+	if !center.valid() || n < 0 {
+		return nil, s.docError("", "", fmt.Errorf("%w: center %v, count %d", ErrInvalidGeoQuery, center, n))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.geoIndex == nil {
+		return nil, s.docError("", "", ErrNoGeoIndex)
+	}
+	if n == 0 {
+		return []GeoResult{}, nil
+	}
+
+	// The radius grows until it holds n points; points outside of it are
+	// farther than any inside, so the nearest ones are among the matches.
+	span := startIndexSpan(ctx, "geo")
+	var matches []geoMatch
+	for meters := 1000.0; ; meters *= 4 {
+		matches = s.geoIndex.withinRadius(center, meters)
+		if len(matches) >= n || meters >= math.Pi*earthRadius {
+			break
+		}
+	}
+	span.End()
+	o.scanned(len(matches))
+
+	results, err := s.geoResults(matches, n)
+	o.returned(len(results))
+
+	return results, err
+}
+
+// geoResults loads documents of matches, skipping expired ones, up to limit
+// results when limit is not negative. Must be called with s.mu held.
+func (s *Collection) geoResults(matches []geoMatch, limit int) ([]GeoResult, error) {
+	results := make([]GeoResult, 0, len(matches))
+	for _, match := range matches {
+		if limit >= 0 && len(results) == limit {
+			break
+		}
+		doc, err := s.get(match.id)
+		if errors.Is(err, ErrDocumentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, GeoResult{Document: *doc, Distance: match.distance})
+	}
+
+	return results, nil
+}
+
+func validateGeoIndexConfig(cfg *GeoIndexConfig) error {
+	if cfg != nil && cfg.Field == "" {
+		return fmt.Errorf("%w: geo index requires a field", ErrInvalidCollectionConfig)
+	}
+
+	return nil
+}
+
+// GeoRadiusFilter matches documents whose geopoint field is not farther
+// than Meters from Center.
+type GeoRadiusFilter struct {
+	Field  string
+	Center GeoPoint
+	Meters float64
+}
+
+func WithinRadius(field string, center GeoPoint, meters float64) GeoRadiusFilter {
+	return GeoRadiusFilter{Field: field, Center: center, Meters: meters}
+}
+
+func (f GeoRadiusFilter) Match(doc Document) bool {
+	value, _ := lookupPath(doc, f.Field)
+	p, ok := value.(GeoPoint)
+	return ok && Distance(f.Center, p) <= f.Meters
+}
+
+// GeoBoxFilter matches documents whose geopoint field lies in Box.
+type GeoBoxFilter struct {
+	Field string
+	Box   GeoBox
+}
+
+func WithinBox(field string, box GeoBox) GeoBoxFilter {
+	return GeoBoxFilter{Field: field, Box: box}
+}
+
+func (f GeoBoxFilter) Match(doc Document) bool {
+	value, _ := lookupPath(doc, f.Field)
+	p, ok := value.(GeoPoint)
+	return ok && f.Box.contains(p)
+}
@@ -0,0 +1,218 @@
+package documentstore
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"maps"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func placeDocument(id string, lat float64, lon float64) Document {
+	return Document{
+		Fields: map[string]DocumentField{
+			"id":       {Type: DocumentFieldTypeString, Value: id},
+			"location": {Type: DocumentFieldTypeGeoPoint, Value: GeoPoint{Lat: lat, Lon: lon}},
+		},
+	}
+}
+
+var (
+	kyiv   = GeoPoint{Lat: 50.4501, Lon: 30.5234}
+	lviv   = GeoPoint{Lat: 49.8397, Lon: 24.0297}
+	odesa  = GeoPoint{Lat: 46.4825, Lon: 30.7233}
+	irpin  = GeoPoint{Lat: 50.5218, Lon: 30.2506}
+	fiji   = GeoPoint{Lat: -17.7134, Lon: 178.0650}
+	samoa  = GeoPoint{Lat: -13.7590, Lon: -172.1046}
+	places = map[string]GeoPoint{"kyiv": kyiv, "lviv": lviv, "odesa": odesa, "irpin": irpin, "fiji": fiji, "samoa": samoa}
+)
+
+func newPlaces(t *testing.T) *Collection {
+	collection := NewCollection(&CollectionConfig{PrimaryKey: "id", GeoIndex: &GeoIndexConfig{Field: "location"}})
+	for id, p := range places {
+		_, err := collection.Put(placeDocument(id, p.Lat, p.Lon))
+		assert.Nil(t, err)
+	}
+
+	return collection
+}
+
+func geoIDs(results []GeoResult) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Document.GetField("id").(string)
+	}
+
+	return ids
+}
+
+func TestGeoPoint_Validation(t *testing.T) {
+	t.Run("Should accept geo point", func(t *testing.T) {
+		assert.Nil(t, validateDocument(placeDocument("1", 50, 30), nil))
+	})
+
+	t.Run("Should reject out of range coordinates", func(t *testing.T) {
+		assert.NotNil(t, validateDocument(placeDocument("1", 91, 30), nil))
+		assert.NotNil(t, validateDocument(placeDocument("1", 50, -181), nil))
+	})
+
+	t.Run("Should reject other values", func(t *testing.T) {
+		doc := Document{Fields: map[string]DocumentField{
+			"location": {Type: DocumentFieldTypeGeoPoint, Value: map[string]interface{}{"lat": 1.0, "lon": 2.0}},
+		}}
+		assert.NotNil(t, validateDocument(doc, nil))
+	})
+}
+
+func TestDistance(t *testing.T) {
+	t.Run("Should compute great-circle distance", func(t *testing.T) {
+		assert.InDelta(t, 467_000, Distance(kyiv, lviv), 3_000)
+		assert.Equal(t, 0.0, Distance(kyiv, kyiv))
+	})
+}
+
+func TestCollection_GeoQueries(t *testing.T) {
+	t.Run("Should find documents within radius", func(t *testing.T) {
+		results, err := newPlaces(t).WithinRadius(kyiv, 50_000)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"kyiv", "irpin"}, geoIDs(results))
+		assert.Equal(t, 0.0, results[0].Distance)
+		assert.InDelta(t, Distance(kyiv, irpin), results[1].Distance, 1e-6)
+	})
+
+	t.Run("Should find documents within box", func(t *testing.T) {
+		docs, err := newPlaces(t).WithinBox(GeoBox{SouthWest: GeoPoint{Lat: 46, Lon: 23}, NorthEast: GeoPoint{Lat: 50, Lon: 31}})
+		assert.Nil(t, err)
+		assert.Len(t, docs, 2)
+		assert.Equal(t, "lviv", docs[0].GetField("id"))
+		assert.Equal(t, "odesa", docs[1].GetField("id"))
+	})
+
+	t.Run("Should handle the antimeridian", func(t *testing.T) {
+		collection := newPlaces(t)
+
+		docs, err := collection.WithinBox(GeoBox{SouthWest: GeoPoint{Lat: -20, Lon: 170}, NorthEast: GeoPoint{Lat: -10, Lon: -170}})
+		assert.Nil(t, err)
+		assert.Len(t, docs, 2)
+
+		results, err := collection.WithinRadius(fiji, 1_200_000)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"fiji", "samoa"}, geoIDs(results))
+	})
+
+	t.Run("Should find nearest documents", func(t *testing.T) {
+		collection := newPlaces(t)
+
+		results, err := collection.Nearest(GeoPoint{Lat: 50, Lon: 29}, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"irpin", "kyiv", "lviv"}, geoIDs(results))
+
+		results, _ = collection.Nearest(kyiv, 10)
+		assert.Len(t, results, len(places))
+	})
+
+	t.Run("Should follow writes", func(t *testing.T) {
+		collection := newPlaces(t)
+		collection.Delete("irpin")
+		collection.Replace(placeDocument("odesa", 50.46, 30.52))
+
+		results, _ := collection.WithinRadius(kyiv, 50_000)
+		assert.Equal(t, []string{"kyiv", "odesa"}, geoIDs(results))
+		assert.Len(t, collection.geoIndex.entries, len(places)-1)
+	})
+
+	t.Run("Should match brute force", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", GeoIndex: &GeoIndexConfig{Field: "location"}})
+		points := map[string]GeoPoint{}
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 500; i++ {
+			id := fmt.Sprintf("p%03d", i)
+			points[id] = GeoPoint{Lat: r.Float64()*180 - 90, Lon: r.Float64()*360 - 180}
+			collection.Put(placeDocument(id, points[id].Lat, points[id].Lon))
+		}
+
+		for i := 0; i < 50; i++ {
+			center := GeoPoint{Lat: r.Float64()*180 - 90, Lon: r.Float64()*360 - 180}
+			meters := r.Float64() * 3_000_000
+
+			var expected []string
+			for id, p := range points {
+				if Distance(center, p) <= meters {
+					expected = append(expected, id)
+				}
+			}
+			results, err := collection.WithinRadius(center, meters)
+			assert.Nil(t, err)
+			assert.ElementsMatch(t, expected, geoIDs(results))
+
+			nearest, _ := collection.Nearest(center, 5)
+			ids := slices.Collect(maps.Keys(points))
+			slices.SortFunc(ids, func(a, b string) int {
+				da, db := Distance(center, points[a]), Distance(center, points[b])
+				if da < db {
+					return -1
+				} else if da > db {
+					return 1
+				}
+				return 0
+			})
+			assert.Equal(t, ids[:5], geoIDs(nearest))
+		}
+	})
+
+	t.Run("Should reject invalid queries", func(t *testing.T) {
+		collection := newPlaces(t)
+
+		_, err := collection.WithinRadius(GeoPoint{Lat: 100}, 10)
+		assert.ErrorIs(t, err, ErrInvalidGeoQuery)
+		_, err = collection.WithinBox(GeoBox{SouthWest: GeoPoint{Lat: 10}, NorthEast: GeoPoint{Lat: 0}})
+		assert.ErrorIs(t, err, ErrInvalidGeoQuery)
+
+		_, err = NewCollection(&CollectionConfig{PrimaryKey: "id"}).Nearest(kyiv, 1)
+		assert.ErrorIs(t, err, ErrNoGeoIndex)
+	})
+
+	t.Run("Should filter in aggregation", func(t *testing.T) {
+		result, err := newPlaces(t).Aggregate(Match(WithinRadius("location", kyiv, 50_000)), Sort(Asc("id")))
+		assert.Nil(t, err)
+		assert.Len(t, result, 2)
+		assert.Equal(t, "irpin", result[0].GetField("id"))
+	})
+}
+
+func TestStore_GeoIndex(t *testing.T) {
+	cfg := &CollectionConfig{PrimaryKey: "id", GeoIndex: &GeoIndexConfig{Field: "location"}}
+
+	t.Run("Should decode geo points from dump", func(t *testing.T) {
+		store := NewStore()
+		collection, _ := store.CreateCollection("places", cfg)
+		collection.Put(placeDocument("kyiv", kyiv.Lat, kyiv.Lon))
+
+		dump, _ := store.Dump()
+		restored, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+		assert.Equal(t, store, restored)
+
+		restoredPlaces, _ := restored.GetCollection("places")
+		results, _ := restoredPlaces.Nearest(lviv, 1)
+		assert.Equal(t, []string{"kyiv"}, geoIDs(results))
+	})
+
+	t.Run("Should rebuild index from database file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "store.db")
+		store, _ := OpenStore(filename)
+		collection, _ := store.CreateCollection("places", cfg)
+		collection.Put(placeDocument("kyiv", kyiv.Lat, kyiv.Lon))
+		assert.Nil(t, store.Close())
+
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		collection, _ = store.GetCollection("places")
+		results, _ := collection.WithinRadius(irpin, 50_000)
+		assert.Equal(t, []string{"kyiv"}, geoIDs(results))
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"time"
 )
 
 type DocumentValidatorErrors = []error
@@ -20,20 +22,45 @@ func GetType(k reflect.Kind) DocumentFieldType {
 		return DocumentFieldTypeArray
 	case reflect.Map, reflect.Struct:
 		return DocumentFieldTypeObject
+	case reflect.Invalid:
+		return DocumentFieldTypeNull
 	default:
 		return "unknown"
 	}
 }
 
-func validateDocument(doc Document) error {
+// GetValueType returns the field type of a value. Unlike GetType it
+// recognizes types that are told apart by more than their kind, such as
+// time.Time or []byte, and nil.
+func GetValueType(value interface{}) DocumentFieldType {
+	switch value.(type) {
+	case nil:
+		return DocumentFieldTypeNull
+	case GeoPoint:
+		return DocumentFieldTypeGeoPoint
+	case time.Time:
+		return DocumentFieldTypeDateTime
+	case []byte:
+		return DocumentFieldTypeBinary
+	case Decimal:
+		return DocumentFieldTypeDecimal
+	default:
+		return GetType(reflect.TypeOf(value).Kind())
+	}
+}
+
+// validateDocument checks that fields hold values of their types and match
+// schema, which may be nil.
+func validateDocument(doc Document, schema *Schema) error {
 	validatorErrors := DocumentValidatorErrors{}
 	for key, value := range doc.Fields {
-		var errorMsg string
-		if t := GetType(reflect.TypeOf(value.Value).Kind()); t != value.Type {
-			errorMsg = fmt.Sprintf("Document field %s type mismatch. Expected: %s, got: %s", key, value.Type, t)
-			validatorErrors = append(validatorErrors, errors.New(errorMsg))
+		if err := validateValue(value.Type, value.Value); err != nil {
+			validatorErrors = append(validatorErrors, newError("", "", key, err))
 		}
 	}
+	if schema != nil {
+		validatorErrors = append(validatorErrors, schema.validate(doc)...)
+	}
 
 	if len(validatorErrors) > 0 {
 		return errors.Join(validatorErrors...)
@@ -41,3 +68,23 @@ func validateDocument(doc Document) error {
 
 	return nil
 }
+
+// validateValue checks that value holds a valid value of type t.
+func validateValue(t DocumentFieldType, value interface{}) error {
+	if actual := GetValueType(value); actual != t {
+		return fmt.Errorf("%w: type mismatch. Expected: %s, got: %s", ErrValidationFailed, t, actual)
+	}
+	if !slices.Contains(fieldTypes, t) {
+		return fmt.Errorf("%w: unsupported type of %T", ErrValidationFailed, value)
+	}
+	if point, ok := value.(GeoPoint); ok && !point.valid() {
+		return fmt.Errorf("%w: not a valid geo point: %v", ErrValidationFailed, point)
+	}
+	if d, ok := value.(Decimal); ok {
+		if _, valid := d.rat(); !valid {
+			return fmt.Errorf("%w: not a valid decimal: %q", ErrValidationFailed, d)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"lesson_07/internal/btree"
+	"slices"
+	"time"
+)
+
+var ErrHistoryDisabled = errors.New("history is disabled for collection")
+
+// HistoryConfig enables document history for a collection. Every Put,
+// Replace and Delete records a version. Zero limits keep versions forever.
+type HistoryConfig struct {
+	// MaxVersions is the number of versions kept per document.
+	MaxVersions int `json:"maxVersions,omitempty"`
+	// MaxAge drops versions that were superseded longer ago than this.
+	MaxAge time.Duration `json:"maxAge,omitempty"`
+}
+
+// DocumentVersion is a state of a document starting from Timestamp. A
+// deleted version has no document.
+type DocumentVersion struct {
+	Version   int       `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	Document  *Document `json:"document,omitempty"`
+}
+
+// openHistoryTable opens storage for versions of a collection, or returns nil
+// when history is disabled.
+func openHistoryTable(db *btree.DB, name string, cfg *CollectionConfig) (table[[]DocumentVersion], error) {
+	if cfg.History == nil {
+		return nil, nil
+	}
+	if cfg.History.MaxVersions < 0 || cfg.History.MaxAge < 0 {
+		return nil, fmt.Errorf("%w: history limits cannot be negative", ErrInvalidCollectionConfig)
+	}
+	if db != nil {
+		return openTreeTable[[]DocumentVersion](db, tableKeyPrefix(historyPrefix, name), []byte(countPrefix+historyPrefix+name))
+	}
+
+	return newMemoryTable[[]DocumentVersion](nil), nil
+}
+
+// History returns all retained versions of a document, oldest first.
+func (s *Collection) History(key string) ([]DocumentVersion, error) {
+	collectionLogger().Debug("History of document:", "key", key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.versions(key)
+}
+
+func (s *Collection) versions(key string) ([]DocumentVersion, error) {
+	if s.history == nil {
+		return nil, s.docError("", "", ErrHistoryDisabled)
+	}
+
+	versions, ok, err := s.history.get(key)
+	if err != nil {
+		return nil, s.docError(key, "", fmt.Errorf("failed to read history: %w", err))
+	}
+	if !ok {
+		return nil, s.docError(key, "", fmt.Errorf("history not found: %w", ErrDocumentNotFound))
+	}
+
+	return versions, nil
+}
+
+// GetAsOf returns the document as it was at the given time. ErrDocumentNotFound
+// is returned when the document did not exist then, or when the version was
+// already dropped by retention.
+func (s *Collection) GetAsOf(key string, at time.Time) (*Document, error) {
+	collectionLogger().Debug("Get document as of:", "key", key, "at", at)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions, err := s.versions(key)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Timestamp.After(at) {
+			continue
+		}
+		if versions[i].Deleted || versions[i].Document.expired(at) {
+			break
+		}
+		doc := *versions[i].Document
+		return &doc, nil
+	}
+
+	return nil, s.docError(key, "", fmt.Errorf("%w as of %s", ErrDocumentNotFound, at.Format(time.RFC3339Nano)))
+}
+
+// PruneHistory applies retention to every document, which is otherwise done
+// only when a document is written. Histories that end with an expired delete
+// are removed. It returns the number of dropped versions.
+func (s *Collection) PruneHistory() (int, error) {
+	collectionLogger().Debug("Prune history")
+	if err := s.writable(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.history == nil {
+		return 0, s.docError("", "", ErrHistoryDisabled)
+	}
+
+	histories, err := tableItems(s.history)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for key, versions := range histories {
+		kept := s.retain(versions)
+		if last := kept[len(kept)-1]; last.Deleted && s.outdated(last.Timestamp) {
+			kept = nil
+		}
+
+		switch {
+		case len(kept) == len(versions):
+			continue
+		case len(kept) == 0:
+			_, err = s.history.delete(key)
+		default:
+			err = s.history.put(key, kept)
+		}
+		if err != nil {
+			return pruned, s.docError(key, "", fmt.Errorf("failed to prune history: %w", err))
+		}
+		pruned += len(versions) - len(kept)
+	}
+
+	return pruned, nil
+}
+
+// recordVersion appends the new state of a document to its history. doc is
+// nil for a delete. Must be called with s.mu held, before the document is
+// written: a failed write calls the returned undo, so history only holds
+// versions that were written.
+func (s *Collection) recordVersion(key string, doc *Document) (undo func(), err error) {
+	return s.recordVersionAt(key, doc, now())
+}
+
+func (s *Collection) recordVersionAt(key string, doc *Document, at time.Time) (undo func(), err error) {
+	if s.history == nil {
+		return func() {}, nil
+	}
+
+	previous, _, err := s.history.get(key)
+	if err != nil {
+		return nil, s.docError(key, "", fmt.Errorf("failed to read history: %w", err))
+	}
+
+	version := DocumentVersion{Version: 1, Timestamp: at, Deleted: doc == nil, Document: doc}
+	if len(previous) > 0 {
+		last := previous[len(previous)-1]
+		version.Version = last.Version + 1
+		if version.Timestamp.Before(last.Timestamp) {
+			version.Timestamp = last.Timestamp
+		}
+	}
+	// Versions may be shared with a snapshot, so they are never changed in place.
+	versions := append(slices.Clone(previous), version)
+
+	if err := s.history.put(key, s.retain(versions)); err != nil {
+		return nil, s.docError(key, "", fmt.Errorf("failed to store history: %w", err))
+	}
+
+	return func() {
+		var err error
+		if len(previous) == 0 {
+			_, err = s.history.delete(key)
+		} else {
+			err = s.history.put(key, previous)
+		}
+		if err != nil {
+			collectionLogger().Error("Failed to restore history", "key", key, "err", err)
+		}
+	}, nil
+}
+
+// retain drops versions beyond the configured limits. The latest version is
+// always kept since it describes the current state.
+func (s *Collection) retain(versions []DocumentVersion) []DocumentVersion {
+	cfg := s.cfg.History
+	if cfg.MaxVersions > 0 && len(versions) > cfg.MaxVersions {
+		versions = versions[len(versions)-cfg.MaxVersions:]
+	}
+	if cfg.MaxAge > 0 {
+		// A version stops being visible once the next one is written.
+		first := 0
+		for first < len(versions)-1 && s.outdated(versions[first+1].Timestamp) {
+			first++
+		}
+		versions = versions[first:]
+	}
+
+	return versions
+}
+
+func (s *Collection) outdated(supersededAt time.Time) bool {
+	return s.cfg.History.MaxAge > 0 && now().Sub(supersededAt) > s.cfg.History.MaxAge
+}
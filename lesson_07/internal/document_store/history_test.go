@@ -0,0 +1,293 @@
+package documentstore
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useClock makes version timestamps deterministic. The returned function
+// moves the clock forward.
+func useClock(t *testing.T) (time.Time, func(time.Duration)) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	current := start
+	now = func() time.Time { return current }
+	t.Cleanup(func() { now = time.Now })
+
+	return start, func(d time.Duration) { current = current.Add(d) }
+}
+
+func TestCollection_History(t *testing.T) {
+	t.Run("Should record put, replace and delete", func(t *testing.T) {
+		start, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+
+		collection.Put(userDocument("1", "John"))
+		advance(time.Minute)
+		_, err := collection.Replace(userDocument("1", "Johnny"))
+		assert.Nil(t, err)
+		advance(time.Minute)
+		assert.Nil(t, collection.Delete("1"))
+
+		versions, err := collection.History("1")
+		assert.Nil(t, err)
+		assert.Len(t, versions, 3)
+		assert.Equal(t, 1, versions[0].Version)
+		assert.Equal(t, start, versions[0].Timestamp)
+		assert.Equal(t, "John", versions[0].Document.GetField("name"))
+		assert.Equal(t, "Johnny", versions[1].Document.GetField("name"))
+		assert.True(t, versions[2].Deleted)
+		assert.Nil(t, versions[2].Document)
+	})
+
+	t.Run("Should read document as of a time", func(t *testing.T) {
+		start, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+
+		collection.Put(userDocument("1", "John"))
+		advance(time.Minute)
+		collection.Replace(userDocument("1", "Johnny"))
+		advance(time.Minute)
+		collection.Delete("1")
+
+		_, err := collection.GetAsOf("1", start.Add(-time.Second))
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+
+		doc, err := collection.GetAsOf("1", start.Add(30*time.Second))
+		assert.Nil(t, err)
+		assert.Equal(t, "John", doc.GetField("name"))
+
+		doc, err = collection.GetAsOf("1", start.Add(time.Minute))
+		assert.Nil(t, err)
+		assert.Equal(t, "Johnny", doc.GetField("name"))
+
+		_, err = collection.GetAsOf("1", start.Add(time.Hour))
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+	})
+
+	t.Run("Should keep numbering after re-creating document", func(t *testing.T) {
+		useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+
+		collection.Put(userDocument("1", "John"))
+		collection.Delete("1")
+		collection.Put(userDocument("1", "Jane"))
+
+		versions, _ := collection.History("1")
+		assert.Equal(t, 3, versions[2].Version)
+		assert.False(t, versions[2].Deleted)
+	})
+
+	t.Run("Should fail when history is disabled", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		collection.Put(userDocument("1", "John"))
+
+		_, err := collection.History("1")
+		assert.ErrorIs(t, err, ErrHistoryDisabled)
+		_, err = collection.GetAsOf("1", time.Now())
+		assert.ErrorIs(t, err, ErrHistoryDisabled)
+	})
+
+	t.Run("Should fail for unknown document", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+
+		_, err := collection.History("1")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+	})
+}
+
+// failingTable fails writes while err is set.
+type failingTable[V any] struct {
+	table[V]
+	err error
+}
+
+func (t *failingTable[V]) put(key string, value V) error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.table.put(key, value)
+}
+
+func (t *failingTable[V]) delete(key string) (bool, error) {
+	if t.err != nil {
+		return false, t.err
+	}
+	return t.table.delete(key)
+}
+
+func TestCollection_HistoryWrites(t *testing.T) {
+	diskFull := errors.New("disk is full")
+
+	t.Run("Should not write documents when history fails", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+		collection.Put(userDocument("1", "John"))
+		history := &failingTable[[]DocumentVersion]{table: collection.history, err: diskFull}
+		collection.history = history
+
+		_, err := collection.Put(userDocument("2", "Jane"))
+		assert.ErrorIs(t, err, diskFull)
+		_, err = collection.Replace(userDocument("1", "Johnny"))
+		assert.ErrorIs(t, err, diskFull)
+		assert.ErrorIs(t, collection.Delete("1"), diskFull)
+
+		history.err = nil
+		docs := collection.List()
+		assert.Len(t, docs, 1)
+		assert.Equal(t, "John", docs[0].GetField("name"))
+		versions, _ := collection.History("1")
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("Should not record versions of failed writes", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+		collection.Put(userDocument("1", "John"))
+		documents := &failingTable[Document]{table: collection.documents, err: diskFull}
+		collection.documents = documents
+
+		_, err := collection.Put(userDocument("2", "Jane"))
+		assert.ErrorIs(t, err, diskFull)
+		_, err = collection.Replace(userDocument("1", "Johnny"))
+		assert.ErrorIs(t, err, diskFull)
+		assert.ErrorIs(t, collection.Delete("1"), diskFull)
+
+		versions, err := collection.History("1")
+		assert.Nil(t, err)
+		assert.Len(t, versions, 1)
+		_, err = collection.History("2")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+	})
+}
+
+func TestCollection_HistoryRetention(t *testing.T) {
+	t.Run("Should keep configured number of versions", func(t *testing.T) {
+		useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{MaxVersions: 2}})
+
+		collection.Put(userDocument("1", "v1"))
+		collection.Replace(userDocument("1", "v2"))
+		collection.Replace(userDocument("1", "v3"))
+
+		versions, _ := collection.History("1")
+		assert.Len(t, versions, 2)
+		assert.Equal(t, 2, versions[0].Version)
+		assert.Equal(t, "v3", versions[1].Document.GetField("name"))
+	})
+
+	t.Run("Should drop versions superseded before max age", func(t *testing.T) {
+		start, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{MaxAge: time.Hour}})
+
+		collection.Put(userDocument("1", "v1"))
+		advance(time.Minute)
+		collection.Replace(userDocument("1", "v2"))
+		advance(2 * time.Hour)
+		collection.Replace(userDocument("1", "v3"))
+
+		versions, _ := collection.History("1")
+		assert.Len(t, versions, 2)
+		assert.Equal(t, "v2", versions[0].Document.GetField("name"))
+
+		_, err := collection.GetAsOf("1", start)
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+	})
+
+	t.Run("Should prune untouched documents", func(t *testing.T) {
+		_, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{MaxAge: time.Hour}})
+
+		collection.Put(userDocument("1", "v1"))
+		collection.Replace(userDocument("1", "v2"))
+		collection.Put(userDocument("2", "v1"))
+		collection.Delete("2")
+		collection.Put(userDocument("3", "v1"))
+		advance(2 * time.Hour)
+
+		pruned, err := collection.PruneHistory()
+		assert.Nil(t, err)
+		assert.Equal(t, 3, pruned)
+
+		versions, _ := collection.History("1")
+		assert.Len(t, versions, 1)
+		_, err = collection.History("2")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+		versions, _ = collection.History("3")
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("Should reject negative limits", func(t *testing.T) {
+		_, err := OpenCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{MaxVersions: -1}})
+		assert.ErrorIs(t, err, ErrInvalidCollectionConfig)
+	})
+}
+
+func TestStore_History(t *testing.T) {
+	t.Run("Should keep history in dump", func(t *testing.T) {
+		start, advance := useClock(t)
+		store := NewStore()
+		users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{MaxVersions: 10}})
+		users.Put(userDocument("1", "John"))
+		advance(time.Minute)
+		users.Replace(userDocument("1", "Johnny"))
+
+		dump, err := store.Dump()
+		assert.Nil(t, err)
+		restored, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+
+		restoredUsers, _ := restored.GetCollection("users")
+		doc, err := restoredUsers.GetAsOf("1", start)
+		assert.Nil(t, err)
+		assert.Equal(t, "John", doc.GetField("name"))
+
+		expected, _ := users.History("1")
+		versions, _ := restoredUsers.History("1")
+		assert.Equal(t, expected, versions)
+	})
+
+	t.Run("Should keep history in database file", func(t *testing.T) {
+		start, advance := useClock(t)
+		filename := filepath.Join(t.TempDir(), "store.db")
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+		users.Put(userDocument("1", "John"))
+		advance(time.Minute)
+		users.Delete("1")
+		assert.Nil(t, store.Close())
+
+		store, err = OpenStore(filename)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		users, _ = store.GetCollection("users")
+		doc, err := users.GetAsOf("1", start)
+		assert.Nil(t, err)
+		assert.Equal(t, "John", doc.GetField("name"))
+		versions, _ := users.History("1")
+		assert.Len(t, versions, 2)
+
+		assert.Nil(t, store.DeleteCollection("users"))
+		users, _ = store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+		_, err = users.History("1")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+	})
+
+	t.Run("Should not see later versions in snapshot", func(t *testing.T) {
+		useClock(t)
+		store := NewStore()
+		users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+		users.Put(userDocument("1", "John"))
+
+		snapshot, err := store.Snapshot()
+		assert.Nil(t, err)
+		defer snapshot.Close()
+		users.Replace(userDocument("1", "Johnny"))
+
+		snapshotUsers, _ := snapshot.GetCollection("users")
+		versions, _ := snapshotUsers.History("1")
+		assert.Len(t, versions, 1)
+	})
+}
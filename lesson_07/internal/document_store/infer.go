@@ -0,0 +1,301 @@
+package documentstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+)
+
+const (
+	defaultRequiredRatio = 1
+	defaultOutlierRatio  = 0.05
+)
+
+// SchemaInferenceOptions tune InferSchema.
+type SchemaInferenceOptions struct {
+	// RequiredRatio is the presence from which a field is required, 1 by
+	// default: only fields of every document are required.
+	RequiredRatio float64
+	// OutlierRatio is the presence below which a field, or a type of a
+	// field, is rare, 0.05 by default. Rare fields and types are left out of
+	// the schema, and documents having them are outliers.
+	OutlierRatio float64
+}
+
+// SchemaInference is the schema of existing documents.
+type SchemaInference struct {
+	// Schema is meant for CollectionConfig.Schema. Outliers do not match it.
+	Schema Schema `json:"schema"`
+	// Documents is the number of documents read.
+	Documents int `json:"documents"`
+	// Fields describes every field seen by dotted path; elements of arrays
+	// are "*", e.g. "tags.*" or "items.*.price".
+	Fields map[string]InferredField `json:"fields"`
+	// Outliers are documents that do not match Schema or have rare fields,
+	// ordered by primary key.
+	Outliers []SchemaOutlier `json:"outliers"`
+}
+
+// InferredField describes a field seen in documents.
+type InferredField struct {
+	// Count is the number of values of the field.
+	Count int `json:"count"`
+	// Presence is the fraction of the objects holding the field that have
+	// it: documents for top-level fields, values of the parent object for
+	// nested ones. It is 1 for elements of arrays.
+	Presence float64 `json:"presence"`
+	// Types counts the values by type.
+	Types    map[DocumentFieldType]int `json:"types"`
+	Required bool                      `json:"required"`
+	Rare     bool                      `json:"rare"`
+}
+
+// SchemaOutlier is a document that stands out of the inferred schema.
+type SchemaOutlier struct {
+	ID string `json:"id"`
+	// Reasons tell how the document differs, e.g. "field age: rare field".
+	Reasons []string `json:"reasons"`
+}
+
+// InferSchema reads the documents of the collection twice, to infer their
+// schema and then to find outliers. Documents written meanwhile may be
+// reported as outliers.
+func (s *Collection) InferSchema(opts SchemaInferenceOptions) (*SchemaInference, error) {
+	return s.InferSchemaContext(context.Background(), opts)
+}
+
+// InferSchemaContext is InferSchema that gives up when ctx is done.
+func (s *Collection) InferSchemaContext(ctx context.Context, opts SchemaInferenceOptions) (*SchemaInference, error) {
+	collectionLogger().DebugContext(ctx, "Infer collection schema", "opts", opts)
+	pk := s.cfg.PrimaryKey
+
+	inference, err := inferSchema(func(fn func(id string, doc Document)) error {
+		for doc, err := range s.Scan(ctx, ScanOptions{}) {
+			if err != nil {
+				return err
+			}
+			id, _ := doc.GetField(pk).(string)
+			fn(id, doc)
+		}
+		return nil
+	}, opts)
+	if err != nil {
+		return nil, s.docError("", "", err)
+	}
+
+	return inference, nil
+}
+
+// InferSchemaFromFile infers the schema of a collection of a dump file
+// written by Store.DumpToFile, without loading the store.
+func InferSchemaFromFile(filename string, collection string, opts SchemaInferenceOptions) (*SchemaInference, error) {
+	return InferSchemaFromFileContext(context.Background(), filename, collection, opts)
+}
+
+// InferSchemaFromFileContext is InferSchemaFromFile that gives up when ctx is
+// done.
+func InferSchemaFromFileContext(ctx context.Context, filename string, collection string, opts SchemaInferenceOptions) (*SchemaInference, error) {
+	persistenceLogger().DebugContext(ctx, "Infer schema from file", "filename", filename, "collection", collection)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var dump struct {
+		Collections map[string]json.RawMessage `json:"collections"`
+	}
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, err
+	}
+	raw, ok := dump.Collections[collection]
+	if !ok {
+		return nil, newError(collection, "", "", ErrCollectionNotFound)
+	}
+	var publicCollection struct {
+		Documents map[string]json.RawMessage `json:"documents"`
+	}
+	if err := json.Unmarshal(raw, &publicCollection); err != nil {
+		return nil, newError(collection, "", "", err)
+	}
+	docs, err := unmarshalItems[Document](ctx, publicCollection.Documents)
+	if err != nil {
+		return nil, newError(collection, "", "", err)
+	}
+
+	at := now()
+	return inferSchema(func(fn func(id string, doc Document)) error {
+		for _, id := range slices.Sorted(maps.Keys(docs)) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if doc := docs[id]; !doc.expired(at) {
+				fn(id, doc)
+			}
+		}
+		return nil
+	}, opts)
+}
+
+// inferSchema infers the schema of the documents each reads, in primary key
+// order. Documents are read twice.
+func inferSchema(each func(fn func(id string, doc Document)) error, opts SchemaInferenceOptions) (*SchemaInference, error) {
+	if opts.RequiredRatio <= 0 {
+		opts.RequiredRatio = defaultRequiredRatio
+	}
+	if opts.OutlierRatio <= 0 {
+		opts.OutlierRatio = defaultOutlierRatio
+	}
+
+	root := &fieldNode{}
+	err := each(func(_ string, doc Document) {
+		root.objects++
+		for name, field := range doc.Fields {
+			root.child(name).observe(field.Type, field.Value)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inference := &SchemaInference{
+		Schema:    Schema{Fields: map[string]FieldSchema{}},
+		Documents: root.objects,
+		Fields:    map[string]InferredField{},
+		Outliers:  []SchemaOutlier{},
+	}
+	for name, node := range root.fields {
+		if field, ok := node.infer(name, root.objects, opts, inference.Fields); ok {
+			inference.Schema.Fields[name] = field
+		}
+	}
+
+	err = each(func(id string, doc Document) {
+		var reasons, rare []string
+		for _, err := range inference.Schema.validate(doc) {
+			reasons = append(reasons, err.Error())
+		}
+		for _, name := range slices.Sorted(maps.Keys(doc.Fields)) {
+			rare = append(rare, root.fields[name].rareFields(name, doc.Fields[name].Value)...)
+		}
+		// Elements of an array share the reasons of their rare fields.
+		slices.Sort(rare)
+		reasons = append(reasons, slices.Compact(rare)...)
+		if len(reasons) > 0 {
+			inference.Outliers = append(inference.Outliers, SchemaOutlier{ID: id, Reasons: reasons})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inference, nil
+}
+
+// fieldNode gathers the values seen at a path.
+type fieldNode struct {
+	count int
+	types map[DocumentFieldType]int
+	// objects is the number of object values, which hold fields.
+	objects int
+	fields  map[string]*fieldNode
+	// items gathers the elements of array values.
+	items *fieldNode
+	// presence and rare are set by infer.
+	presence float64
+	rare     bool
+}
+
+func (n *fieldNode) child(name string) *fieldNode {
+	if n.fields == nil {
+		n.fields = map[string]*fieldNode{}
+	}
+	if n.fields[name] == nil {
+		n.fields[name] = &fieldNode{}
+	}
+
+	return n.fields[name]
+}
+
+func (n *fieldNode) observe(t DocumentFieldType, value interface{}) {
+	n.count++
+	if n.types == nil {
+		n.types = map[DocumentFieldType]int{}
+	}
+	n.types[t]++
+
+	switch container := normalizeContainer(value).(type) {
+	case map[string]interface{}:
+		n.objects++
+		for name, item := range container {
+			n.child(name).observe(GetValueType(item), item)
+		}
+	case []interface{}:
+		if n.items == nil {
+			n.items = &fieldNode{}
+		}
+		for _, item := range container {
+			n.items.observe(GetValueType(item), item)
+		}
+	}
+}
+
+// infer returns the schema of the field at path, which may be in parents
+// objects, and records what was seen in fields. ok is false for rare fields.
+func (n *fieldNode) infer(path string, parents int, opts SchemaInferenceOptions, fields map[string]InferredField) (_ FieldSchema, ok bool) {
+	presence := 1.0
+	if parents > 0 {
+		presence = float64(n.count) / float64(parents)
+	}
+	n.presence, n.rare = presence, presence < opts.OutlierRatio
+	field := FieldSchema{Required: parents > 0 && presence >= opts.RequiredRatio}
+	fields[path] = InferredField{Count: n.count, Presence: presence, Types: n.types, Required: field.Required, Rare: n.rare}
+
+	for _, t := range fieldTypes {
+		if count := n.types[t]; count > 0 && float64(count)/float64(n.count) >= opts.OutlierRatio {
+			field.Types = append(field.Types, t)
+		}
+	}
+	for name, child := range n.fields {
+		if childField, ok := child.infer(path+"."+name, n.objects, opts, fields); ok {
+			if field.Fields == nil {
+				field.Fields = map[string]FieldSchema{}
+			}
+			field.Fields[name] = childField
+		}
+	}
+	if n.items != nil && n.items.count > 0 {
+		items, _ := n.items.infer(path+".*", 0, opts, fields)
+		field.Items = &items
+	}
+
+	return field, !n.rare
+}
+
+// rareFields describes the rare fields of the value at path, the value
+// itself included. n is nil, or empty for items of arrays, when the path was
+// not seen by the first pass, e.g. for a document written in between.
+func (n *fieldNode) rareFields(path string, value interface{}) []string {
+	if n == nil || n.count == 0 {
+		return []string{fmt.Sprintf("field %s: unknown field", path)}
+	}
+	if n.rare {
+		return []string{fmt.Sprintf("field %s: rare field, presence %.3g", path, n.presence)}
+	}
+
+	var reasons []string
+	switch container := normalizeContainer(value).(type) {
+	case map[string]interface{}:
+		for _, name := range slices.Sorted(maps.Keys(container)) {
+			reasons = append(reasons, n.fields[name].rareFields(path+"."+name, container[name])...)
+		}
+	case []interface{}:
+		for _, item := range container {
+			reasons = append(reasons, n.items.rareFields(path+".*", item)...)
+		}
+	}
+
+	return reasons
+}
@@ -0,0 +1,154 @@
+package documentstore
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newCustomers returns a collection of 40 customers: every one has a name,
+// most have an address, one has a numeric name and one has a nickname.
+func newCustomers(t *testing.T, store *Store) *Collection {
+	collection, err := store.CreateCollection("customers", &CollectionConfig{PrimaryKey: "id"})
+	assert.Nil(t, err)
+
+	for i := range 40 {
+		doc := userDocument(fmt.Sprintf("%02d", i), "Customer")
+		if i < 30 {
+			doc.Fields["address"] = newField(map[string]interface{}{"city": "Kyiv", "zip": float64(i)})
+		}
+		doc.Fields["tags"] = newField([]interface{}{"new", map[string]interface{}{"label": "vip"}})
+		switch i {
+		case 3:
+			doc.Fields["name"] = newField(float64(3))
+		case 7:
+			doc.Fields["nickname"] = newField("Seven")
+		}
+		_, err := collection.Put(doc)
+		assert.Nil(t, err)
+	}
+
+	return collection
+}
+
+func TestCollection_InferSchema(t *testing.T) {
+	t.Run("Should infer the schema of documents", func(t *testing.T) {
+		customers := newCustomers(t, NewStore())
+
+		inference, err := customers.InferSchema(SchemaInferenceOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, 40, inference.Documents)
+		assert.Equal(t, Schema{Fields: map[string]FieldSchema{
+			"id":   {Types: []DocumentFieldType{DocumentFieldTypeString}, Required: true},
+			"name": {Types: []DocumentFieldType{DocumentFieldTypeString}, Required: true},
+			"address": {Types: []DocumentFieldType{DocumentFieldTypeObject}, Fields: map[string]FieldSchema{
+				"city": {Types: []DocumentFieldType{DocumentFieldTypeString}, Required: true},
+				"zip":  {Types: []DocumentFieldType{DocumentFieldTypeNumber}, Required: true},
+			}},
+			"tags": {Types: []DocumentFieldType{DocumentFieldTypeArray}, Required: true, Items: &FieldSchema{
+				Types:  []DocumentFieldType{DocumentFieldTypeString, DocumentFieldTypeObject},
+				Fields: map[string]FieldSchema{"label": {Types: []DocumentFieldType{DocumentFieldTypeString}, Required: true}},
+			}},
+		}}, inference.Schema)
+
+		assert.Equal(t, InferredField{Count: 30, Presence: 0.75, Types: map[DocumentFieldType]int{DocumentFieldTypeObject: 30}}, inference.Fields["address"])
+		assert.Equal(t, InferredField{Count: 30, Presence: 1, Types: map[DocumentFieldType]int{DocumentFieldTypeNumber: 30}, Required: true}, inference.Fields["address.zip"])
+		assert.Equal(t, map[DocumentFieldType]int{DocumentFieldTypeString: 39, DocumentFieldTypeNumber: 1}, inference.Fields["name"].Types)
+		assert.Equal(t, InferredField{Count: 80, Presence: 1, Types: map[DocumentFieldType]int{DocumentFieldTypeString: 40, DocumentFieldTypeObject: 40}}, inference.Fields["tags.*"])
+		assert.True(t, inference.Fields["nickname"].Rare)
+		assert.NotContains(t, inference.Schema.Fields, "nickname")
+
+		assert.Equal(t, []SchemaOutlier{
+			{ID: "03", Reasons: []string{"field name: validation failed: type number is not allowed by the schema, expected one of [string]"}},
+			{ID: "07", Reasons: []string{"field nickname: rare field, presence 0.025"}},
+		}, inference.Outliers)
+	})
+
+	t.Run("Should make frequent fields required", func(t *testing.T) {
+		customers := newCustomers(t, NewStore())
+
+		inference, err := customers.InferSchema(SchemaInferenceOptions{RequiredRatio: 0.7, OutlierRatio: 0.01})
+		assert.Nil(t, err)
+		assert.True(t, inference.Schema.Fields["address"].Required)
+		assert.Equal(t, []DocumentFieldType{DocumentFieldTypeString, DocumentFieldTypeNumber}, inference.Schema.Fields["name"].Types)
+		assert.Contains(t, inference.Schema.Fields, "nickname")
+		assert.Len(t, inference.Outliers, 10)
+		assert.Equal(t, []string{"field address: validation failed: required field is missing"}, inference.Outliers[0].Reasons)
+	})
+
+	t.Run("Should infer a schema the collection accepts", func(t *testing.T) {
+		customers := newCustomers(t, NewStore())
+		inference, err := customers.InferSchema(SchemaInferenceOptions{})
+		assert.Nil(t, err)
+
+		checked := NewCollection(&CollectionConfig{PrimaryKey: "id", Schema: &inference.Schema})
+		outliers := map[string]bool{}
+		for _, outlier := range inference.Outliers {
+			outliers[outlier.ID] = true
+		}
+		for _, doc := range customers.List() {
+			_, err := checked.Put(doc)
+			id := doc.GetField("id").(string)
+			assert.Equal(t, outliers[id] && id != "07", err != nil, id)
+		}
+	})
+
+	t.Run("Should skip expired documents", func(t *testing.T) {
+		_, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		collection.Put(userDocument("1", "John"))
+		expiring := userDocument("2", "Jane")
+		expiresAt := now().Add(time.Minute)
+		expiring.ExpiresAt = &expiresAt
+		expiring.Fields["age"] = newField(30)
+		collection.Put(expiring)
+		advance(time.Hour)
+
+		inference, err := collection.InferSchema(SchemaInferenceOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, inference.Documents)
+		assert.NotContains(t, inference.Fields, "age")
+	})
+}
+
+func TestInferSchema(t *testing.T) {
+	t.Run("Should report fields written between the passes", func(t *testing.T) {
+		before := userDocument("1", "John")
+		before.Fields["tags"] = newField([]interface{}{})
+		after := userDocument("1", "John")
+		after.Fields["tags"] = newField([]interface{}{"new"})
+		after.Fields["address"] = newField(map[string]interface{}{"city": "Kyiv"})
+		docs := []Document{before, after}
+
+		inference, err := inferSchema(func(fn func(id string, doc Document)) error {
+			fn("1", docs[0])
+			docs = docs[1:]
+			return nil
+		}, SchemaInferenceOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, []SchemaOutlier{{ID: "1", Reasons: []string{
+			"field address: unknown field",
+			"field tags.*: unknown field",
+		}}}, inference.Outliers)
+	})
+}
+
+func TestInferSchemaFromFile(t *testing.T) {
+	t.Run("Should infer the schema of a collection of a dump file", func(t *testing.T) {
+		store := NewStore()
+		customers := newCustomers(t, store)
+		filename := filepath.Join(t.TempDir(), "dump.json")
+		assert.Nil(t, store.DumpToFile(filename))
+
+		inference, err := InferSchemaFromFile(filename, "customers", SchemaInferenceOptions{})
+		assert.Nil(t, err)
+		expected, err := customers.InferSchema(SchemaInferenceOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, inference)
+
+		_, err = InferSchemaFromFile(filename, "orders", SchemaInferenceOptions{})
+		assert.ErrorIs(t, err, ErrCollectionNotFound)
+	})
+}
@@ -0,0 +1,293 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+var ErrInvalidJSONSchema = errors.New("invalid JSON schema")
+
+// JSONSchemaDialect is the JSON Schema draft read by ParseJSONSchema and
+// written by Schema.JSONSchema.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of JSON Schema a Schema converts to and from.
+// Types without a JSON counterpart are told apart by format or encoding:
+// datetime is a "date-time" string, decimal a "decimal" string, binary a
+// base64 string and geopoint a "geopoint" object.
+type jsonSchema struct {
+	Schema          string                 `json:"$schema,omitempty"`
+	Type            jsonTypes              `json:"type,omitempty"`
+	Format          string                 `json:"format,omitempty"`
+	ContentEncoding string                 `json:"contentEncoding,omitempty"`
+	Properties      map[string]*jsonSchema `json:"properties,omitempty"`
+	Required        []string               `json:"required,omitempty"`
+	Items           *jsonSchema            `json:"items,omitempty"`
+	Enum            []interface{}          `json:"enum,omitempty"`
+	Minimum         *float64               `json:"minimum,omitempty"`
+	Maximum         *float64               `json:"maximum,omitempty"`
+	Pattern         string                 `json:"pattern,omitempty"`
+	MinLength       *int                   `json:"minLength,omitempty"`
+	MaxLength       *int                   `json:"maxLength,omitempty"`
+}
+
+// jsonSchemaKeywords are the keywords of jsonSchema and annotations, which
+// do not affect validation. Other keywords are rejected rather than ignored.
+var jsonSchemaKeywords = map[string]bool{
+	"$schema": true, "type": true, "format": true, "contentEncoding": true,
+	"properties": true, "required": true, "items": true, "enum": true,
+	"minimum": true, "maximum": true, "pattern": true, "minLength": true, "maxLength": true,
+	"$id": true, "$comment": true, "title": true, "description": true, "default": true,
+	"examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+func (s *jsonSchema) UnmarshalJSON(data []byte) error {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return fmt.Errorf("%w: a schema must be an object", ErrInvalidJSONSchema)
+	}
+	for _, keyword := range slices.Sorted(maps.Keys(keywords)) {
+		if !jsonSchemaKeywords[keyword] {
+			return fmt.Errorf("%w: unsupported keyword %q", ErrInvalidJSONSchema, keyword)
+		}
+	}
+
+	type plain jsonSchema
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// jsonTypes is the type keyword, a type name or an array of them.
+type jsonTypes []string
+
+func (t jsonTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+
+	return json.Marshal([]string(t))
+}
+
+func (t *jsonTypes) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = jsonTypes{name}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// ParseJSONSchema reads a JSON Schema of documents for CollectionConfig.Schema:
+// an object schema whose properties are the fields. Only the keywords type,
+// required, properties, items, enum, minimum, maximum, pattern, minLength
+// and maxLength are supported, besides annotations such as title; patterns
+// use the syntax of the regexp package.
+func ParseJSONSchema(data []byte) (*Schema, error) {
+	var root jsonSchema
+	if err := json.Unmarshal(data, &root); err != nil {
+		if errors.Is(err, ErrInvalidJSONSchema) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidJSONSchema, err)
+	}
+	if root.Schema != "" && root.Schema != JSONSchemaDialect {
+		return nil, fmt.Errorf("%w: unsupported dialect %q", ErrInvalidJSONSchema, root.Schema)
+	}
+
+	field, err := root.fieldSchema("")
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(field.Types, []DocumentFieldType{DocumentFieldTypeObject}) && len(field.Types) > 0 {
+		return nil, fmt.Errorf("%w: documents must be objects", ErrInvalidJSONSchema)
+	}
+	if field.Items != nil || field.Enum != nil || field.Minimum != nil || field.Maximum != nil || field.Integer ||
+		field.Pattern != "" || field.MinLength != nil || field.MaxLength != nil {
+		return nil, fmt.Errorf("%w: only properties and required apply to documents", ErrInvalidJSONSchema)
+	}
+
+	schema := &Schema{Fields: field.Fields}
+	if err := validateSchema(schema); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidJSONSchema, err)
+	}
+
+	return schema, nil
+}
+
+func (s *jsonSchema) fieldSchema(path string) (FieldSchema, error) {
+	field := FieldSchema{
+		Enum:      s.Enum,
+		Minimum:   s.Minimum,
+		Maximum:   s.Maximum,
+		Pattern:   s.Pattern,
+		MinLength: s.MinLength,
+		MaxLength: s.MaxLength,
+		Integer:   slices.Contains(s.Type, "integer") && !slices.Contains(s.Type, "number"),
+	}
+	for _, name := range s.Type {
+		t, err := s.fieldType(name)
+		if err != nil {
+			return FieldSchema{}, fmt.Errorf("%w of field %q", err, path)
+		}
+		if !slices.Contains(field.Types, t) {
+			field.Types = append(field.Types, t)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(s.Properties)) {
+		child, err := s.Properties[name].fieldSchema(joinPath(path, name))
+		if err != nil {
+			return FieldSchema{}, err
+		}
+		child.Required = slices.Contains(s.Required, name)
+		if field.Fields == nil {
+			field.Fields = map[string]FieldSchema{}
+		}
+		field.Fields[name] = child
+	}
+	for _, name := range s.Required {
+		if _, ok := field.Fields[name]; !ok {
+			if field.Fields == nil {
+				field.Fields = map[string]FieldSchema{}
+			}
+			field.Fields[name] = FieldSchema{Required: true}
+		}
+	}
+
+	if s.Items != nil {
+		items, err := s.Items.fieldSchema(path + ".*")
+		if err != nil {
+			return FieldSchema{}, err
+		}
+		field.Items = &items
+	}
+
+	return field, nil
+}
+
+// fieldType returns the field type of a JSON type of the schema.
+func (s *jsonSchema) fieldType(name string) (DocumentFieldType, error) {
+	switch name {
+	case "string":
+		switch {
+		case s.Format == "date-time":
+			return DocumentFieldTypeDateTime, nil
+		case s.Format == "decimal":
+			return DocumentFieldTypeDecimal, nil
+		case s.ContentEncoding == "base64":
+			return DocumentFieldTypeBinary, nil
+		}
+		return DocumentFieldTypeString, nil
+	case "object":
+		if s.Format == "geopoint" {
+			return DocumentFieldTypeGeoPoint, nil
+		}
+		return DocumentFieldTypeObject, nil
+	case "number", "integer":
+		return DocumentFieldTypeNumber, nil
+	case "boolean":
+		return DocumentFieldTypeBool, nil
+	case "array":
+		return DocumentFieldTypeArray, nil
+	case "null":
+		return DocumentFieldTypeNull, nil
+	default:
+		return "", fmt.Errorf("%w: unknown type %q", ErrInvalidJSONSchema, name)
+	}
+}
+
+// JSONSchema returns the schema as a JSON Schema, see ParseJSONSchema. A nil
+// schema allows any object. Types sharing a JSON type, such as string and
+// datetime, cannot be exported together.
+func (schema *Schema) JSONSchema() ([]byte, error) {
+	root := &jsonSchema{Type: jsonTypes{"object"}}
+	if schema != nil {
+		var err error
+		if root, err = newJSONSchema("", FieldSchema{Types: []DocumentFieldType{DocumentFieldTypeObject}, Fields: schema.Fields}); err != nil {
+			return nil, err
+		}
+	}
+	root.Schema = JSONSchemaDialect
+
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// JSONSchema returns the schema of the collection as a JSON Schema, see
+// Schema.JSONSchema.
+func (s *Collection) JSONSchema() ([]byte, error) {
+	data, err := s.cfg.Schema.JSONSchema()
+	if err != nil {
+		return nil, s.docError("", "", err)
+	}
+
+	return data, nil
+}
+
+func newJSONSchema(path string, field FieldSchema) (*jsonSchema, error) {
+	s := &jsonSchema{
+		Enum:      field.Enum,
+		Minimum:   field.Minimum,
+		Maximum:   field.Maximum,
+		Pattern:   field.Pattern,
+		MinLength: field.MinLength,
+		MaxLength: field.MaxLength,
+	}
+	for _, t := range field.Types {
+		name := string(t)
+		switch t {
+		case DocumentFieldTypeNumber:
+			if field.Integer {
+				name = "integer"
+			}
+		case DocumentFieldTypeBool:
+			name = "boolean"
+		case DocumentFieldTypeDateTime:
+			name, s.Format = "string", "date-time"
+		case DocumentFieldTypeDecimal:
+			name, s.Format = "string", "decimal"
+		case DocumentFieldTypeBinary:
+			name, s.ContentEncoding = "string", "base64"
+		case DocumentFieldTypeGeoPoint:
+			name, s.Format = "object", "geopoint"
+		}
+		if slices.Contains(s.Type, name) {
+			return nil, fmt.Errorf("%w: types %v of field %q share the JSON type %s", ErrInvalidJSONSchema, field.Types, path, name)
+		}
+		s.Type = append(s.Type, name)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(field.Fields)) {
+		child, err := newJSONSchema(joinPath(path, name), field.Fields[name])
+		if err != nil {
+			return nil, err
+		}
+		if s.Properties == nil {
+			s.Properties = map[string]*jsonSchema{}
+		}
+		s.Properties[name] = child
+		if field.Fields[name].Required {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	if field.Items != nil {
+		items, err := newJSONSchema(path+".*", *field.Items)
+		if err != nil {
+			return nil, err
+		}
+		s.Items = items
+	}
+
+	return s, nil
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
@@ -0,0 +1,132 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const profileJSONSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "Profile",
+  "type": "object",
+  "required": ["id", "name"],
+  "properties": {
+    "id": {"type": "string", "minLength": 1},
+    "name": {"type": ["string", "null"], "maxLength": 50, "pattern": "^\\S"},
+    "age": {"type": "integer", "minimum": 0, "maximum": 150},
+    "role": {"enum": ["admin", "user"], "description": "Access level"},
+    "birthday": {"type": "string", "format": "date-time"},
+    "address": {
+      "type": "object",
+      "required": ["city"],
+      "properties": {"city": {"type": "string"}, "location": {"type": "object", "format": "geopoint"}}
+    },
+    "tags": {"type": "array", "items": {"type": "string", "minLength": 1}}
+  }
+}`
+
+func TestParseJSONSchema(t *testing.T) {
+	t.Run("Should read a schema of documents", func(t *testing.T) {
+		schema, err := ParseJSONSchema([]byte(profileJSONSchema))
+		assert.Nil(t, err)
+
+		minLength, maxLength, minimum, maximum := 1, 50, 0.0, 150.0
+		assert.Equal(t, &Schema{Fields: map[string]FieldSchema{
+			"id":       {Types: []DocumentFieldType{DocumentFieldTypeString}, Required: true, MinLength: &minLength},
+			"name":     {Types: []DocumentFieldType{DocumentFieldTypeString, DocumentFieldTypeNull}, Required: true, MaxLength: &maxLength, Pattern: `^\S`},
+			"age":      {Types: []DocumentFieldType{DocumentFieldTypeNumber}, Integer: true, Minimum: &minimum, Maximum: &maximum},
+			"role":     {Enum: []interface{}{"admin", "user"}},
+			"birthday": {Types: []DocumentFieldType{DocumentFieldTypeDateTime}},
+			"address": {Types: []DocumentFieldType{DocumentFieldTypeObject}, Fields: map[string]FieldSchema{
+				"city":     {Types: []DocumentFieldType{DocumentFieldTypeString}, Required: true},
+				"location": {Types: []DocumentFieldType{DocumentFieldTypeGeoPoint}},
+			}},
+			"tags": {Types: []DocumentFieldType{DocumentFieldTypeArray}, Items: &FieldSchema{Types: []DocumentFieldType{DocumentFieldTypeString}, MinLength: &minLength}},
+		}}, schema)
+	})
+
+	t.Run("Should validate documents with the schema", func(t *testing.T) {
+		schema, err := ParseJSONSchema([]byte(profileJSONSchema))
+		assert.Nil(t, err)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", Schema: schema})
+
+		doc := userDocument("1", "John")
+		doc.Fields["age"] = newField(30)
+		doc.Fields["tags"] = newField([]interface{}{"go"})
+		_, err = collection.Put(doc)
+		assert.Nil(t, err)
+
+		doc = userDocument("2", "Jane")
+		doc.Fields["role"] = newField("guest")
+		doc.Fields["tags"] = newField([]interface{}{""})
+		_, err = collection.Put(doc)
+		assert.ErrorIs(t, err, &Error{Field: "role"})
+		assert.ErrorIs(t, err, &Error{Field: "tags.0"})
+	})
+
+	t.Run("Should reject unsupported schemas", func(t *testing.T) {
+		for name, data := range map[string]string{
+			"keyword":    `{"type": "object", "additionalProperties": false}`,
+			"nested":     `{"properties": {"tags": {"type": "array", "minItems": 1}}}`,
+			"dialect":    `{"$schema": "http://json-schema.org/draft-07/schema#"}`,
+			"type":       `{"properties": {"id": {"type": "uuid"}}}`,
+			"root":       `{"type": "array"}`,
+			"pattern":    `{"properties": {"id": {"pattern": "(?<=x)"}}}`,
+			"boolean":    `{"properties": {"id": true}}`,
+			"not a JSON": `{`,
+		} {
+			_, err := ParseJSONSchema([]byte(data))
+			assert.ErrorIs(t, err, ErrInvalidJSONSchema, name)
+			assert.Equal(t, CodeInvalidArgument, ErrorCodeOf(err), name)
+		}
+	})
+}
+
+func TestSchema_JSONSchema(t *testing.T) {
+	t.Run("Should export a schema that reads back the same", func(t *testing.T) {
+		schema, err := ParseJSONSchema([]byte(profileJSONSchema))
+		assert.Nil(t, err)
+
+		data, err := schema.JSONSchema()
+		assert.Nil(t, err)
+		assert.Contains(t, string(data), `"$schema": "https://json-schema.org/draft/2020-12/schema"`)
+		assert.Contains(t, string(data), `"required": [
+    "id",
+    "name"
+  ]`)
+		read, err := ParseJSONSchema(data)
+		assert.Nil(t, err)
+		assert.Equal(t, schema, read)
+	})
+
+	t.Run("Should export the schema of a collection", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", Schema: &Schema{Fields: map[string]FieldSchema{
+			"paid": {Types: []DocumentFieldType{DocumentFieldTypeDecimal, DocumentFieldTypeBool}},
+			"file": {Types: []DocumentFieldType{DocumentFieldTypeBinary}},
+		}}})
+
+		data, err := collection.JSONSchema()
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"type": "object",
+			"properties": {
+				"paid": {"type": ["string", "boolean"], "format": "decimal"},
+				"file": {"type": "string", "contentEncoding": "base64"}
+			}
+		}`, string(data))
+
+		data, err = NewCollection(&CollectionConfig{PrimaryKey: "id"}).JSONSchema()
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object"}`, string(data))
+	})
+
+	t.Run("Should not export types sharing a JSON type", func(t *testing.T) {
+		schema := &Schema{Fields: map[string]FieldSchema{
+			"at": {Types: []DocumentFieldType{DocumentFieldTypeString, DocumentFieldTypeDateTime}},
+		}}
+
+		_, err := schema.JSONSchema()
+		assert.ErrorIs(t, err, ErrInvalidJSONSchema)
+	})
+}
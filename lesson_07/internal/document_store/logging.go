@@ -0,0 +1,223 @@
+package documentstore
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Log records of the store carry ComponentKey set to one of the components,
+// so that handlers can filter them, e.g. by level per component.
+const (
+	ComponentKey = "component"
+	// ComponentStore logs operations on the collections of a store.
+	ComponentStore = "store"
+	// ComponentCollection logs operations on documents.
+	ComponentCollection = "collection"
+	// ComponentPersistence logs dumps, loads and store files.
+	ComponentPersistence = "persistence"
+	// ComponentSlowLog logs slow operations, see SetSlowLog.
+	ComponentSlowLog = "slowlog"
+)
+
+var (
+	storeLogger       = newComponentLogger(ComponentStore)
+	collectionLogger  = newComponentLogger(ComponentCollection)
+	persistenceLogger = newComponentLogger(ComponentPersistence)
+	slowLogger        = newComponentLogger(ComponentSlowLog)
+)
+
+type componentLogger struct {
+	base   *slog.Logger
+	logger *slog.Logger
+}
+
+// newComponentLogger returns a function returning the default logger with
+// the component attribute. The logger is rebuilt when the default changes.
+func newComponentLogger(component string) func() *slog.Logger {
+	var cached atomic.Pointer[componentLogger]
+	return func() *slog.Logger {
+		base := slog.Default()
+		if c := cached.Load(); c != nil && c.base == base {
+			return c.logger
+		}
+		c := &componentLogger{base: base, logger: base.With(ComponentKey, component)}
+		cached.Store(c)
+		return c.logger
+	}
+}
+
+// RedactedValue replaces values hidden by RedactFields.
+const RedactedValue = "[REDACTED]"
+
+// LogRedactor returns what to log in place of the value of a document field
+// at a dotted path. It is called for objects and arrays before their fields
+// and elements; elements of arrays are at their index, e.g. "cards.0.number".
+type LogRedactor func(path string, value interface{}) interface{}
+
+var logRedactor atomic.Pointer[LogRedactor]
+
+// SetLogRedactor makes documents and updates logged by the store pass their
+// values through r. A nil r logs values as they are.
+func SetLogRedactor(r LogRedactor) {
+	if r == nil {
+		logRedactor.Store(nil)
+		return
+	}
+	logRedactor.Store(&r)
+}
+
+func currentLogRedactor() LogRedactor {
+	if r := logRedactor.Load(); r != nil {
+		return *r
+	}
+
+	return nil
+}
+
+// RedactFields returns a LogRedactor hiding the values at paths, including
+// everything nested in them, e.g. "card.number" or "cards.0.number"; an
+// array is hidden as a whole by its own path.
+func RedactFields(paths ...string) LogRedactor {
+	return func(path string, value interface{}) interface{} {
+		for _, p := range paths {
+			if path == p || strings.HasPrefix(path, p+".") {
+				return RedactedValue
+			}
+		}
+		return value
+	}
+}
+
+// LogValue logs the fields of the document as a group, redacted by the
+// LogRedactor set with SetLogRedactor.
+func (d Document) LogValue() slog.Value {
+	return slog.GroupValue(redactedAttrs("", documentValues(d), currentLogRedactor())...)
+}
+
+// LogValue logs the update like a document, see Document.LogValue.
+func (u Update) LogValue() slog.Value {
+	redact := currentLogRedactor()
+	inc := make(map[string]interface{}, len(u.Inc))
+	for path, n := range u.Inc {
+		inc[path] = n
+	}
+
+	var attrs []slog.Attr
+	if len(u.Set) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "set", Value: slog.GroupValue(redactedAttrs("", u.Set, redact)...)})
+	}
+	if len(u.Unset) > 0 {
+		attrs = append(attrs, slog.Any("unset", u.Unset))
+	}
+	if len(inc) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "inc", Value: slog.GroupValue(redactedAttrs("", inc, redact)...)})
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+func redactedAttrs(prefix string, values map[string]interface{}, redact LogRedactor) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(values))
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		value := values[name]
+		if redact != nil {
+			value = redact(path, value)
+		}
+		switch container := normalizeContainer(value).(type) {
+		case map[string]interface{}:
+			attrs = append(attrs, slog.Attr{Key: name, Value: slog.GroupValue(redactedAttrs(path, container, redact)...)})
+		case []interface{}:
+			attrs = append(attrs, slog.Any(name, redactedItems(path, container, redact)))
+		default:
+			attrs = append(attrs, slog.Any(name, value))
+		}
+	}
+
+	return attrs
+}
+
+// redactedItems redacts the elements of an array at path, which are at their
+// index, e.g. "cards.0.number". items is modified.
+func redactedItems(path string, items []interface{}, redact LogRedactor) []interface{} {
+	for i, item := range items {
+		items[i] = redactedValue(path+"."+strconv.Itoa(i), item, redact)
+	}
+
+	return items
+}
+
+func redactedValue(path string, value interface{}, redact LogRedactor) interface{} {
+	if redact != nil {
+		value = redact(path, value)
+	}
+	switch container := normalizeContainer(value).(type) {
+	case map[string]interface{}:
+		for name, item := range container {
+			container[name] = redactedValue(path+"."+name, item, redact)
+		}
+		return container
+	case []interface{}:
+		return redactedItems(path, container, redact)
+	default:
+		return value
+	}
+}
+
+type logAttrsKey struct{}
+
+// WithLogAttrs returns a context whose log records carry attrs, e.g. a
+// request ID. Attributes of ctx are kept. The records of the store are
+// annotated once the default handler is wrapped by NewContextHandler.
+func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	current := contextLogAttrs(ctx)
+	merged := make([]slog.Attr, 0, len(current)+len(attrs))
+	merged = append(append(merged, current...), attrs...)
+
+	return context.WithValue(ctx, logAttrsKey{}, merged)
+}
+
+func contextLogAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+
+	return attrs
+}
+
+// ContextHandler adds the attributes set by WithLogAttrs to records logged
+// with a context.
+type ContextHandler struct {
+	slog.Handler
+}
+
+func NewContextHandler(h slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: h}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := contextLogAttrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return NewContextHandler(h.Handler.WithAttrs(attrs))
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return NewContextHandler(h.Handler.WithGroup(name))
+}
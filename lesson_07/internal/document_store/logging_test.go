@@ -0,0 +1,95 @@
+package documentstore
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func useLogRedactor(t *testing.T, r LogRedactor) {
+	SetLogRedactor(r)
+	t.Cleanup(func() { SetLogRedactor(nil) })
+}
+
+func TestContextHandler(t *testing.T) {
+	t.Run("Should log attributes of the context", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(NewContextHandler(slog.NewTextHandler(&buf, nil)))
+
+		ctx := WithLogAttrs(context.Background(), slog.String("request", "r1"))
+		ctx = WithLogAttrs(ctx, slog.Int("attempt", 2))
+		logger.With("component", "store").InfoContext(ctx, "Get document")
+		logger.Info("No context")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 2)
+		assert.Contains(t, lines[0], "component=store request=r1 attempt=2")
+		assert.NotContains(t, lines[1], "request=")
+	})
+}
+
+func TestDocument_LogValue(t *testing.T) {
+	doc := userDocument("1", "John")
+	doc.Fields["card"] = DocumentField{Type: DocumentFieldTypeObject, Value: map[string]interface{}{
+		"number": "4111111111111111",
+		"holder": "John",
+	}}
+
+	log := func(args ...any) string {
+		var buf bytes.Buffer
+		slog.New(slog.NewTextHandler(&buf, nil)).Info("Put document", args...)
+		return buf.String()
+	}
+
+	t.Run("Should log document fields", func(t *testing.T) {
+		assert.Contains(t, log("doc", doc), "doc.card.holder=John doc.card.number=4111111111111111 doc.id=1 doc.name=John")
+	})
+
+	t.Run("Should redact document fields", func(t *testing.T) {
+		useLogRedactor(t, RedactFields("card.number", "name"))
+
+		line := log("doc", doc)
+		assert.Contains(t, line, "doc.card.holder=John doc.card.number=[REDACTED] doc.id=1 doc.name=[REDACTED]")
+		assert.NotContains(t, line, "4111")
+	})
+
+	t.Run("Should redact fields of typed maps and arrays", func(t *testing.T) {
+		useLogRedactor(t, RedactFields("card.number", "cards.0.number"))
+		typed := userDocument("1", "John")
+		typed.Fields["card"] = newField(map[string]string{"number": "4111111111111111", "holder": "John"})
+		typed.Fields["cards"] = newField([]map[string]string{{"number": "4222222222222222"}})
+
+		line := log("doc", typed)
+		assert.Contains(t, line, "doc.card.holder=John doc.card.number=[REDACTED] doc.cards=[map[number:[REDACTED]]]")
+		assert.NotContains(t, line, "4111")
+		assert.NotContains(t, line, "4222")
+	})
+
+	t.Run("Should redact updates", func(t *testing.T) {
+		useLogRedactor(t, RedactFields("card"))
+
+		line := log("update", Update{
+			Set:   map[string]interface{}{"card.number": "4111111111111111", "name": "Johnny"},
+			Unset: []string{"age"},
+			Inc:   map[string]float64{"visits": 1},
+		})
+		assert.Contains(t, line, "update.set.card.number=[REDACTED] update.set.name=Johnny update.unset=[age] update.inc.visits=1")
+		assert.NotContains(t, line, "4111")
+	})
+
+	t.Run("Should log with the component of the store", func(t *testing.T) {
+		var buf bytes.Buffer
+		defaultLogger := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+		useLogRedactor(t, RedactFields("card"))
+
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		_, err := collection.Put(doc)
+		assert.Nil(t, err)
+		assert.Contains(t, buf.String(), `msg="Put document" component=collection doc.card=[REDACTED] doc.id=1`)
+	})
+}
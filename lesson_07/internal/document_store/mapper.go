@@ -0,0 +1,133 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var ErrInvalidInputType = errors.New("invalid input type")
+var ErrInvalidOutputType = errors.New("invalid output type")
+var ErrUnmarshalDocument = errors.New("unmarshal error")
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decimalType = reflect.TypeOf(Decimal(""))
+)
+
+// MarshalDocument converts a struct to a document. Fields with a `document`
+// tag become document fields named by the tag. time.Time maps to datetime,
+// []byte to binary and nil pointers to null.
+func MarshalDocument(input any) (*Document, error) {
+	v := reflect.ValueOf(input)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if k := v.Kind(); k != reflect.Struct {
+		return nil, fmt.Errorf("%w. Passed: %v", ErrInvalidInputType, k)
+	}
+
+	t := v.Type()
+	documentFields := map[string]DocumentField{}
+	for i := 0; i < t.NumField(); i++ {
+		if docKey := t.Field(i).Tag.Get("document"); docKey != "" {
+			value := v.Field(i)
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					documentFields[docKey] = DocumentField{Type: DocumentFieldTypeNull}
+					continue
+				}
+				value = value.Elem()
+			}
+			documentFields[docKey] = newField(value.Interface())
+		}
+	}
+
+	return &Document{Fields: documentFields}, nil
+}
+
+// UnmarshalDocument fills tagged fields of the struct output points to.
+// Numbers convert between numeric types, so values read back from a dump
+// as float64 fit int fields. Null and missing fields leave zero values.
+func UnmarshalDocument(doc *Document, output any) error {
+	v := reflect.ValueOf(output)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("%w. Passed: %v", ErrInvalidOutputType, v.Kind())
+	}
+	v = v.Elem()
+
+	if k := v.Kind(); k != reflect.Struct {
+		return fmt.Errorf("%w. Passed: %v", ErrInvalidOutputType, k)
+	}
+
+	t := v.Type()
+	var errs []error
+	for i := 0; i < t.NumField(); i++ {
+		docKey := t.Field(i).Tag.Get("document")
+		if docKey == "" {
+			continue
+		}
+		field := v.Field(i)
+		if !field.CanSet() {
+			errs = append(errs, fmt.Errorf("%w: field %v not settable", ErrUnmarshalDocument, t.Field(i).Name))
+			continue
+		}
+
+		value := doc.GetField(docKey)
+		if value == nil {
+			field.SetZero()
+			continue
+		}
+		if err := setStructField(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%w: doc %s key and output %s: %w", ErrUnmarshalDocument, docKey, t.Field(i).Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func setStructField(field reflect.Value, value interface{}) error {
+	if field.Kind() == reflect.Ptr {
+		target := reflect.New(field.Type().Elem())
+		if err := setStructField(target.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Type().AssignableTo(field.Type()):
+		field.Set(v)
+	case field.Type() == timeType:
+		// Datetimes decoded from JSON without the field type are strings.
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("cannot use %T as time", value)
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(parsed))
+	case field.Type() == decimalType && isNumberKind(v.Kind()):
+		field.Set(reflect.ValueOf(Decimal(fmt.Sprint(value))))
+	case isNumberKind(field.Kind()):
+		n, ok := toNumber(value)
+		if !ok {
+			return fmt.Errorf("cannot use %T as %v", value, field.Type())
+		}
+		field.Set(reflect.ValueOf(n).Convert(field.Type()))
+	default:
+		return fmt.Errorf("cannot use %T as %v", value, field.Type())
+	}
+
+	return nil
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return GetType(k) == DocumentFieldTypeNumber
+}
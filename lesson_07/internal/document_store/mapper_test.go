@@ -0,0 +1,61 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type account struct {
+	ID        string     `document:"id"`
+	Age       int        `document:"age"`
+	CreatedAt time.Time  `document:"createdAt"`
+	DeletedAt *time.Time `document:"deletedAt"`
+	Avatar    []byte     `document:"avatar"`
+	Balance   Decimal    `document:"balance"`
+	Internal  string
+}
+
+func TestMapper(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	t.Run("Should marshal typed fields", func(t *testing.T) {
+		doc, err := MarshalDocument(&account{ID: "1", Age: 30, CreatedAt: createdAt, Avatar: []byte{1}, Balance: "9.99", Internal: "x"})
+		assert.Nil(t, err)
+		assert.Equal(t, Document{Fields: map[string]DocumentField{
+			"id":        {Type: DocumentFieldTypeString, Value: "1"},
+			"age":       {Type: DocumentFieldTypeNumber, Value: 30},
+			"createdAt": {Type: DocumentFieldTypeDateTime, Value: createdAt},
+			"deletedAt": {Type: DocumentFieldTypeNull},
+			"avatar":    {Type: DocumentFieldTypeBinary, Value: []byte{1}},
+			"balance":   {Type: DocumentFieldTypeDecimal, Value: Decimal("9.99")},
+		}}, *doc)
+		assert.Nil(t, validateDocument(*doc, nil))
+	})
+
+	t.Run("Should unmarshal after JSON round trip", func(t *testing.T) {
+		deletedAt := createdAt.Add(time.Hour)
+		input := account{ID: "1", Age: 30, CreatedAt: createdAt, DeletedAt: &deletedAt, Avatar: []byte{1, 2}, Balance: "9.99"}
+		doc, _ := MarshalDocument(input)
+		data, _ := json.Marshal(doc)
+		decoded := Document{}
+		assert.Nil(t, json.Unmarshal(data, &decoded))
+
+		output := account{}
+		assert.Nil(t, UnmarshalDocument(&decoded, &output))
+		assert.Equal(t, input, output)
+	})
+
+	t.Run("Should fail on mismatched types", func(t *testing.T) {
+		doc := Document{Fields: map[string]DocumentField{"createdAt": {Type: DocumentFieldTypeBool, Value: true}}}
+		err := UnmarshalDocument(&doc, &account{})
+		assert.ErrorIs(t, err, ErrUnmarshalDocument)
+	})
+
+	t.Run("Should reject non-struct values", func(t *testing.T) {
+		_, err := MarshalDocument(42)
+		assert.ErrorIs(t, err, ErrInvalidInputType)
+		assert.ErrorIs(t, UnmarshalDocument(&Document{}, account{}), ErrInvalidOutputType)
+	})
+}
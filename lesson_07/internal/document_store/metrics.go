@@ -0,0 +1,137 @@
+package documentstore
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Operation names what a metric was measured for.
+type Operation string
+
+const (
+	OpPut          Operation = "put"
+	OpReplace      Operation = "replace"
+	OpUpdate       Operation = "update"
+	OpGet          Operation = "get"
+	OpDelete       Operation = "delete"
+	OpList         Operation = "list"
+	OpBulkWrite    Operation = "bulk_write"
+	OpAggregate    Operation = "aggregate"
+	OpSearch       Operation = "search"
+	OpWithinRadius Operation = "within_radius"
+	OpWithinBox    Operation = "within_box"
+	OpNearest      Operation = "nearest"
+
+	OpDump Operation = "dump"
+	OpLoad Operation = "load"
+)
+
+// Metrics receives measurements of the store, see SetMetrics.
+// Implementations must be safe for concurrent use and fast: they are called
+// on every operation.
+type Metrics interface {
+	// ObserveOperation records an operation on a collection. The collection
+	// is empty for collections outside of a store; err is nil on success.
+	ObserveOperation(collection string, op Operation, duration time.Duration, err error)
+	// ObservePersistence records a dump or a load of size bytes.
+	ObservePersistence(op Operation, duration time.Duration, size int, err error)
+}
+
+var metrics atomic.Pointer[Metrics]
+
+// SetMetrics makes every store and collection report to m. A nil m stops
+// reporting.
+func SetMetrics(m Metrics) {
+	if m == nil {
+		metrics.Store(nil)
+		return
+	}
+	metrics.Store(&m)
+}
+
+func currentMetrics() Metrics {
+	if m := metrics.Load(); m != nil {
+		return *m
+	}
+
+	return nil
+}
+
+// memoryUsage approximates the memory held by the documents of an in-memory
+// collection; ok is false for collections kept on disk. The size is tracked
+// as documents are written, see newDocumentMemoryTable.
+func (s *Collection) memoryUsage() (size int, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.documents.(*memoryTable[Document])
+	if !ok || !t.sized {
+		return 0, false
+	}
+
+	return t.data.size, true
+}
+
+// newDocumentMemoryTable returns a memory table of documents tracking their
+// size for memoryUsage.
+func newDocumentMemoryTable(items map[string]Document) *memoryTable[Document] {
+	t := newMemoryTable(items)
+	t.sized = true
+	for key, doc := range t.data.items {
+		t.data.size += itemSize(key, doc)
+	}
+
+	return t
+}
+
+// itemSize approximates the memory held by an item of a sized memory table.
+func itemSize[V any](key string, value V) int {
+	switch value := any(value).(type) {
+	case Document:
+		return len(key) + documentSize(value)
+	default:
+		return len(key)
+	}
+}
+
+// documentSize approximates the memory held by a document, counting string
+// and map headers but not allocator overhead.
+func documentSize(doc Document) int {
+	size := 48
+	for name, field := range doc.Fields {
+		size += 16 + len(name) + 16 + len(field.Type) + valueSize(field.Value)
+	}
+	if doc.ExpiresAt != nil {
+		size += 24
+	}
+
+	return size
+}
+
+func valueSize(value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		return 16 + len(v)
+	case Decimal:
+		return 16 + len(v)
+	case []byte:
+		return 24 + len(v)
+	case time.Time:
+		return 24
+	case map[string]interface{}:
+		size := 48
+		for key, item := range v {
+			size += 16 + len(key) + valueSize(item)
+		}
+		return size
+	case []interface{}:
+		size := 24
+		for _, item := range v {
+			size += valueSize(item)
+		}
+		return size
+	default:
+		// Interface header of numbers, bools, geo points and nil.
+		return 16
+	}
+}
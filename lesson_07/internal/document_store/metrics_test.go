@@ -0,0 +1,138 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type observation struct {
+	collection string
+	op         Operation
+	size       int
+	code       ErrorCode
+}
+
+type recordingMetrics struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (m *recordingMetrics) ObserveOperation(collection string, op Operation, _ time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observations = append(m.observations, observation{collection: collection, op: op, code: ErrorCodeOf(err)})
+}
+
+func (m *recordingMetrics) ObservePersistence(op Operation, _ time.Duration, size int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observations = append(m.observations, observation{op: op, size: size, code: ErrorCodeOf(err)})
+}
+
+func useMetrics(t *testing.T, m Metrics) {
+	SetMetrics(m)
+	t.Cleanup(func() { SetMetrics(nil) })
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("Should observe operations per collection", func(t *testing.T) {
+		store, users := newUsersStore(t)
+		metrics := &recordingMetrics{}
+		useMetrics(t, metrics)
+
+		users.Put(userDocument("3", "Ann"))
+		users.Put(userDocument("3", "Ann"))
+		users.Get("1")
+		users.Update("1", Update{Set: map[string]interface{}{"name": "Johnny"}})
+		users.Delete("9")
+		users.List()
+		store.Aggregate("users", Limit(1))
+
+		assert.Equal(t, []observation{
+			{collection: "users", op: OpPut},
+			{collection: "users", op: OpPut, code: CodeAlreadyExists},
+			{collection: "users", op: OpGet},
+			{collection: "users", op: OpUpdate},
+			{collection: "users", op: OpDelete, code: CodeNotFound},
+			{collection: "users", op: OpList},
+			{collection: "users", op: OpAggregate},
+		}, metrics.observations)
+	})
+
+	t.Run("Should observe dumps and loads", func(t *testing.T) {
+		store, _ := newUsersStore(t)
+		metrics := &recordingMetrics{}
+		useMetrics(t, metrics)
+
+		dump, err := store.Dump()
+		assert.Nil(t, err)
+		_, err = NewStoreFromDump(dump)
+		assert.Nil(t, err)
+		_, err = NewStoreFromDump([]byte("{"))
+		assert.NotNil(t, err)
+
+		assert.Equal(t, []observation{
+			{op: OpDump, size: len(dump)},
+			{op: OpLoad, size: len(dump)},
+			{op: OpLoad, size: 1, code: CodeInternal},
+		}, metrics.observations)
+	})
+
+	t.Run("Should not observe without metrics", func(t *testing.T) {
+		_, users := newUsersStore(t)
+		metrics := &recordingMetrics{}
+		useMetrics(t, metrics)
+		SetMetrics(nil)
+
+		users.Get("1")
+		assert.Empty(t, metrics.observations)
+	})
+}
+
+func TestDocumentSize(t *testing.T) {
+	t.Run("Should grow with values", func(t *testing.T) {
+		small := documentSize(userDocument("1", "Jo"))
+		large := documentSize(userDocument("1", "Jonathan"))
+		assert.Equal(t, 6, large-small)
+
+		nested := userDocument("1", "Jo")
+		nested.Fields["address"] = DocumentField{Type: DocumentFieldTypeObject, Value: map[string]interface{}{"city": "Kyiv"}}
+		assert.Greater(t, documentSize(nested), small+len("address")+len("Kyiv"))
+	})
+}
+
+func TestCollection_MemoryUsage(t *testing.T) {
+	t.Run("Should track the size of written documents", func(t *testing.T) {
+		store := NewStore()
+		users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		expected := func() int {
+			size := 0
+			for _, doc := range users.List() {
+				size += len(doc.GetField("id").(string)) + documentSize(doc)
+			}
+			return size
+		}
+
+		users.Put(userDocument("1", "John"))
+		users.Put(userDocument("2", "Jane"))
+		snapshot, err := store.Snapshot()
+		assert.Nil(t, err)
+		defer snapshot.Close()
+		users.Put(userDocument("1", "Jonathan"))
+		assert.Nil(t, users.Delete("2"))
+
+		size, ok := users.memoryUsage()
+		assert.True(t, ok)
+		assert.Equal(t, expected(), size)
+
+		dump, err := store.Dump()
+		assert.Nil(t, err)
+		restored, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+		restoredUsers, _ := restored.GetCollection("users")
+		size, _ = restoredUsers.memoryUsage()
+		assert.Equal(t, expected(), size)
+	})
+}
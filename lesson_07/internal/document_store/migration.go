@@ -0,0 +1,535 @@
+package documentstore
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidMigration = errors.New("invalid migration")
+var ErrMigrationFailed = errors.New("migration failed")
+
+// MigrationsCollection keeps the migration version of every migrated
+// collection, keyed by collection name. Store.Migrate creates it.
+const MigrationsCollection = "_migrations"
+
+const defaultMigrationBatchSize = 100
+
+// MigrationStep changes a document during a migration and returns it. Steps
+// must be idempotent: after a crash the batch being migrated is migrated
+// again, so a step may see documents it has already changed. The top-level
+// fields may be modified in place, nested objects and arrays are shared with
+// the stored document and must be copied first.
+type MigrationStep func(doc Document) (Document, error)
+
+// Migration brings the documents of a collection from the previous version
+// to Version, see Store.RegisterMigrations.
+type Migration struct {
+	// Version is positive and unique per collection; migrations are applied
+	// in version order.
+	Version     int
+	Description string
+	// Steps are applied to every document in order.
+	Steps []MigrationStep
+}
+
+// MigrateOptions tune Store.Migrate.
+type MigrateOptions struct {
+	// BatchSize is the number of documents migrated and persisted at once,
+	// 100 by default. Progress is saved after every batch.
+	BatchSize int
+	// DryRun reports what a run would do without writing anything.
+	DryRun bool
+}
+
+// MigrationReport is the outcome of Store.Migrate.
+type MigrationReport struct {
+	Collection string
+	// From is the version of the collection before the run and To the
+	// version reached, or that a dry run would reach.
+	From   int
+	To     int
+	DryRun bool
+	// Migrations describes every pending migration in version order.
+	Migrations []MigrationResult
+}
+
+// MigrationResult describes a migration of a MigrationReport.
+type MigrationResult struct {
+	Version     int
+	Description string
+	// Scanned counts the documents read by this run and Changed those
+	// written, or that a dry run would write.
+	Scanned int
+	Changed int
+	// Failures are documents the migration failed on, in primary key order.
+	Failures []MigrationFailure
+}
+
+// MigrationFailure is a document a migration failed on.
+type MigrationFailure struct {
+	ID  string
+	Err error
+}
+
+// apply returns doc with the steps of m applied and whether it changed.
+func (m Migration) apply(doc Document, primaryKey string) (Document, bool, error) {
+	migrated := doc
+	for _, step := range m.Steps {
+		migrated.Fields = maps.Clone(migrated.Fields)
+		var err error
+		if migrated, err = step(migrated); err != nil {
+			return doc, false, err
+		}
+	}
+	if !reflect.DeepEqual(migrated.Fields[primaryKey], doc.Fields[primaryKey]) {
+		return doc, false, newError("", "", primaryKey, fmt.Errorf("%w: primary key cannot be changed", ErrMigrationFailed))
+	}
+
+	return migrated, !reflect.DeepEqual(migrated, doc), nil
+}
+
+// RenameField moves the value at dotted path from to path to. Documents
+// without from are left as is; documents having both fail.
+func RenameField(from string, to string) MigrationStep {
+	return func(doc Document) (Document, error) {
+		value, ok := lookupPath(doc, from)
+		if !ok {
+			return doc, nil
+		}
+		if _, exists := lookupPath(doc, to); exists {
+			return doc, newError("", "", to, fmt.Errorf("%w: cannot rename %q, the field exists", ErrMigrationFailed, from))
+		}
+		if doc, ok = setPath(doc, to, value); !ok {
+			return doc, newError("", "", to, fmt.Errorf("%w: cannot set path", ErrMigrationFailed))
+		}
+		doc, _ = unsetPath(doc, from)
+
+		return doc, nil
+	}
+}
+
+// ChangeFieldType converts the value at dotted path to type t. Numbers,
+// decimals, strings and bools convert to one another when the value allows
+// it, strings in RFC 3339 format convert to datetimes and any value converts
+// to an array holding it. Missing and null values are left as is.
+func ChangeFieldType(path string, t DocumentFieldType) MigrationStep {
+	return func(doc Document) (Document, error) {
+		value, ok := lookupPath(doc, path)
+		if !ok || value == nil || GetValueType(value) == t {
+			return doc, nil
+		}
+		converted, err := convertValue(value, t)
+		if err != nil {
+			return doc, newError("", "", path, err)
+		}
+		if doc, ok = setPath(doc, path, converted); !ok {
+			return doc, newError("", "", path, fmt.Errorf("%w: cannot set path", ErrMigrationFailed))
+		}
+
+		return doc, nil
+	}
+}
+
+// BackfillField sets the value at dotted path in documents that do not have
+// it, creating missing objects on the way.
+func BackfillField(path string, value interface{}) MigrationStep {
+	return func(doc Document) (Document, error) {
+		if _, ok := lookupPath(doc, path); ok {
+			return doc, nil
+		}
+		doc, ok := setPath(doc, path, value)
+		if !ok {
+			return doc, newError("", "", path, fmt.Errorf("%w: cannot set path", ErrMigrationFailed))
+		}
+
+		return doc, nil
+	}
+}
+
+func convertValue(value interface{}, t DocumentFieldType) (interface{}, error) {
+	number, isNumber := toNumber(value)
+	switch t {
+	case DocumentFieldTypeString:
+		switch v := value.(type) {
+		case Decimal:
+			return string(v), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		}
+		if isNumber {
+			return strconv.FormatFloat(number, 'f', -1, 64), nil
+		}
+	case DocumentFieldTypeNumber:
+		switch v := value.(type) {
+		case string:
+			if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return n, nil
+			}
+		case bool:
+			if v {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		}
+		if isNumber {
+			return number, nil
+		}
+	case DocumentFieldTypeDecimal:
+		if s, ok := value.(string); ok {
+			if d, err := ParseDecimal(strings.TrimSpace(s)); err == nil {
+				return d, nil
+			}
+		} else if isNumber {
+			return Decimal(strconv.FormatFloat(number, 'f', -1, 64)), nil
+		}
+	case DocumentFieldTypeBool:
+		if s, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				return b, nil
+			}
+		} else if isNumber {
+			return number != 0, nil
+		}
+	case DocumentFieldTypeDateTime:
+		if s, ok := value.(string); ok {
+			if at, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s)); err == nil {
+				return at, nil
+			}
+		}
+	case DocumentFieldTypeArray:
+		return []interface{}{value}, nil
+	}
+
+	return nil, fmt.Errorf("%w: cannot convert %s %v to %s", ErrMigrationFailed, GetValueType(value), value, t)
+}
+
+// RegisterMigrations adds migrations of a collection, which may not exist
+// yet. Migrations are not persisted: they are registered again whenever the
+// store is opened, and Migrate skips those already applied.
+func (s *Store) RegisterMigrations(collection string, migrations ...Migration) error {
+	storeLogger().Debug("Register migrations", "collection", collection, "migrations", len(migrations))
+	s.migrationMu.Lock()
+	defer s.migrationMu.Unlock()
+
+	if collection == MigrationsCollection {
+		return newError(collection, "", "", fmt.Errorf("%w: the collection keeps migration versions", ErrInvalidMigration))
+	}
+
+	registered := slices.Clone(s.migrations[collection])
+	for _, m := range migrations {
+		if m.Version <= 0 {
+			return newError(collection, "", "", fmt.Errorf("%w: version %d is not positive", ErrInvalidMigration, m.Version))
+		}
+		if slices.ContainsFunc(registered, func(r Migration) bool { return r.Version == m.Version }) {
+			return newError(collection, "", "", fmt.Errorf("%w: version %d is already registered", ErrInvalidMigration, m.Version))
+		}
+		if len(m.Steps) == 0 || slices.ContainsFunc(m.Steps, func(step MigrationStep) bool { return step == nil }) {
+			return newError(collection, "", "", fmt.Errorf("%w: version %d has no steps or a nil step", ErrInvalidMigration, m.Version))
+		}
+		registered = append(registered, m)
+	}
+	slices.SortFunc(registered, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+
+	if s.migrations == nil {
+		s.migrations = map[string][]Migration{}
+	}
+	s.migrations[collection] = registered
+
+	return nil
+}
+
+// MigrationVersion returns the version of the last migration applied to a
+// collection, 0 if there was none.
+func (s *Store) MigrationVersion(collection string) (int, error) {
+	state, err := s.migrationState(context.Background(), collection)
+	if err != nil {
+		return 0, newError(collection, "", "", err)
+	}
+
+	return state.version, nil
+}
+
+// Migrate applies the registered migrations of a collection newer than its
+// version, one after the other. A migration passes over the documents in
+// primary key order, batch by batch: every document is read again, migrated
+// and validated under the collection lock, so concurrent writes are not
+// lost, and only changed documents are written. The version,
+// and the last key migrated after every batch, are kept in
+// MigrationsCollection, so a run stopped by a failure, by ctx or by a crash
+// resumes where it stopped. Documents written meanwhile behind the pass are
+// not migrated; writers should write the new shape from the start.
+//
+// A run stops at the first document that fails and returns the report along
+// with the error. A dry run writes nothing: it applies the pending migrations
+// in turn to copies of the documents and reports every failure, except
+// reference violations, which are not checked.
+func (s *Store) Migrate(collection string, opts MigrateOptions) (*MigrationReport, error) {
+	return s.MigrateContext(context.Background(), collection, opts)
+}
+
+// MigrateContext is Migrate that stops when ctx is done. Batches persisted
+// before are kept.
+func (s *Store) MigrateContext(ctx context.Context, collection string, opts MigrateOptions) (*MigrationReport, error) {
+	storeLogger().DebugContext(ctx, "Migrate collection", "collection", collection, "opts", opts)
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultMigrationBatchSize
+	}
+	s.migrationMu.Lock()
+	defer s.migrationMu.Unlock()
+
+	target, ok := s.GetCollection(collection)
+	if !ok || collection == MigrationsCollection {
+		return nil, newError(collection, "", "", ErrCollectionNotFound)
+	}
+	state, err := s.migrationState(ctx, collection)
+	if err != nil {
+		return nil, newError(collection, "", "", err)
+	}
+
+	report := &MigrationReport{Collection: collection, From: state.version, To: state.version, DryRun: opts.DryRun}
+	var pending []Migration
+	for _, m := range s.migrations[collection] {
+		if m.Version > state.version {
+			pending = append(pending, m)
+			report.Migrations = append(report.Migrations, MigrationResult{Version: m.Version, Description: m.Description})
+		}
+	}
+	if len(pending) == 0 {
+		return report, nil
+	}
+
+	if opts.DryRun {
+		if err := target.dryRunMigrations(ctx, pending, report, opts.BatchSize); err != nil {
+			return report, target.docError("", "", err)
+		}
+		return report, nil
+	}
+
+	if err := target.writable(); err != nil {
+		return report, err
+	}
+	for i, m := range pending {
+		if err := s.runMigration(ctx, target, m, &state, &report.Migrations[i], opts.BatchSize); err != nil {
+			storeLogger().ErrorContext(ctx, "Migration failed", "collection", collection, "version", m.Version, "err", err)
+			return report, fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+		report.To = m.Version
+	}
+
+	return report, nil
+}
+
+// runMigration applies m to the documents of target, resuming the pass
+// recorded in state.
+func (s *Store) runMigration(ctx context.Context, target *Collection, m Migration, state *migrationState, result *MigrationResult, batchSize int) error {
+	from := ""
+	if state.migrating == m.Version && state.cursor != "" {
+		from = state.cursor + "\x00"
+	}
+	state.migrating = m.Version
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		docs, last, more, err := target.scanBatch(from, batchSize)
+		if err != nil {
+			return target.docError("", "", err)
+		}
+		result.Scanned += len(docs)
+
+		var failure error
+		batchErr := target.batch(func() {
+			for _, doc := range docs {
+				if failure = ctx.Err(); failure != nil {
+					return
+				}
+				id, _ := doc.GetField(target.cfg.PrimaryKey).(string)
+				changed, err := target.migrateDocument(ctx, id, m)
+				if err != nil {
+					result.Failures = append(result.Failures, MigrationFailure{ID: id, Err: err})
+					failure = err
+					return
+				}
+				if changed {
+					result.Changed++
+				}
+			}
+		})
+		if err := errors.Join(failure, batchErr); err != nil {
+			return err
+		}
+
+		if !more {
+			break
+		}
+		// Progress is saved even when ctx is done, as the batch is written.
+		state.cursor = last
+		if err := s.saveMigrationState(context.WithoutCancel(ctx), target.name, state); err != nil {
+			return err
+		}
+		from = last + "\x00"
+	}
+
+	state.version, state.migrating, state.cursor = m.Version, 0, ""
+	return s.saveMigrationState(ctx, target.name, state)
+}
+
+// migrateDocument applies m to the document with key id and writes it if it
+// changed. Documents deleted meanwhile are skipped. Like ReplaceContext it
+// checks references before locking the collection.
+func (s *Collection) migrateDocument(ctx context.Context, id string, m Migration) (bool, error) {
+	release := s.lockReferences()
+	defer release()
+	if s.store != nil && len(s.cfg.References) > 0 {
+		s.mu.RLock()
+		current, err := s.get(id)
+		s.mu.RUnlock()
+		if errors.Is(err, ErrDocumentNotFound) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		migrated, changed, err := m.apply(*current, s.cfg.PrimaryKey)
+		if err != nil {
+			return false, s.docError(id, "", err)
+		}
+		if changed {
+			if err := s.checkReferences(migrated); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.get(id)
+	if errors.Is(err, ErrDocumentNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	migrated, changed, err := m.apply(*current, s.cfg.PrimaryKey)
+	if err != nil {
+		return false, s.docError(id, "", err)
+	}
+	if !changed {
+		return false, nil
+	}
+	if _, err := s.write(ctx, id, migrated); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// dryRunMigrations applies pending to copies of the documents of the
+// collection. A document failing a migration is left out of the next ones.
+func (s *Collection) dryRunMigrations(ctx context.Context, pending []Migration, report *MigrationReport, batchSize int) error {
+	pk := s.cfg.PrimaryKey
+	failed := make([]bool, len(pending))
+	for doc, err := range s.Scan(ctx, ScanOptions{BatchSize: batchSize}) {
+		if err != nil {
+			return err
+		}
+		id, _ := doc.GetField(pk).(string)
+		for i, m := range pending {
+			result := &report.Migrations[i]
+			result.Scanned++
+			migrated, changed, err := m.apply(doc, pk)
+			if err == nil && changed {
+				err = validateDocument(migrated, s.cfg.Schema)
+			}
+			if err != nil {
+				result.Failures = append(result.Failures, MigrationFailure{ID: id, Err: s.docError(id, "", err)})
+				failed[i] = true
+				break
+			}
+			if changed {
+				result.Changed++
+			}
+			doc = migrated
+		}
+	}
+
+	for i, m := range pending {
+		if failed[i] {
+			break
+		}
+		report.To = m.Version
+	}
+
+	return nil
+}
+
+// migrationState is the document of a collection in MigrationsCollection.
+type migrationState struct {
+	version int
+	// migrating is the version being applied and cursor the last key it
+	// migrated.
+	migrating int
+	cursor    string
+	stored    bool
+}
+
+func (s *Store) migrationState(ctx context.Context, collection string) (migrationState, error) {
+	migrations, ok := s.GetCollection(MigrationsCollection)
+	if !ok {
+		return migrationState{}, nil
+	}
+	doc, err := migrations.GetContext(ctx, collection)
+	if errors.Is(err, ErrDocumentNotFound) {
+		return migrationState{}, nil
+	} else if err != nil {
+		return migrationState{}, err
+	}
+
+	version, _ := toNumber(doc.GetField("version"))
+	migrating, _ := toNumber(doc.GetField("migrating"))
+	cursor, _ := doc.GetField("cursor").(string)
+
+	return migrationState{version: int(version), migrating: int(migrating), cursor: cursor, stored: true}, nil
+}
+
+func (s *Store) saveMigrationState(ctx context.Context, collection string, state *migrationState) error {
+	migrations, ok := s.GetCollection(MigrationsCollection)
+	if !ok {
+		var err error
+		if migrations, err = s.CreateCollection(MigrationsCollection, &CollectionConfig{PrimaryKey: "collection"}); err != nil {
+			return err
+		}
+	}
+
+	doc := Document{Fields: map[string]DocumentField{
+		"collection": newField(collection),
+		"version":    newField(state.version),
+	}}
+	if state.migrating > 0 {
+		doc.Fields["migrating"] = newField(state.migrating)
+		doc.Fields["cursor"] = newField(state.cursor)
+	}
+
+	var err error
+	if state.stored {
+		_, err = migrations.ReplaceContext(ctx, doc)
+	} else {
+		_, err = migrations.PutContext(ctx, doc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save migration progress: %w", err)
+	}
+	state.stored = true
+
+	return nil
+}
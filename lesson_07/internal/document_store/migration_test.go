@@ -0,0 +1,315 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newMigrationUsers(t *testing.T, store *Store, n int) *Collection {
+	users, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	assert.Nil(t, err)
+	for i := 1; i <= n; i++ {
+		doc := userDocument(strconv.Itoa(i), "User "+strconv.Itoa(i))
+		doc.Fields["age"] = newField(strconv.Itoa(20 + i))
+		_, err := users.Put(doc)
+		assert.Nil(t, err)
+	}
+
+	return users
+}
+
+func TestStore_RegisterMigrations(t *testing.T) {
+	step := BackfillField("active", true)
+	tests := map[string]Migration{
+		"Should reject a version that is not positive": {Version: 0, Steps: []MigrationStep{step}},
+		"Should reject a registered version":           {Version: 1, Steps: []MigrationStep{step}},
+		"Should reject a migration without steps":      {Version: 2},
+		"Should reject a nil step":                     {Version: 2, Steps: []MigrationStep{nil}},
+	}
+
+	for name, migration := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := NewStore()
+			assert.Nil(t, store.RegisterMigrations("users", Migration{Version: 1, Steps: []MigrationStep{step}}))
+
+			err := store.RegisterMigrations("users", migration)
+			assert.ErrorIs(t, err, ErrInvalidMigration)
+			assert.ErrorIs(t, err, &Error{Code: CodeInvalidArgument, Collection: "users"})
+			assert.Len(t, store.migrations["users"], 1)
+		})
+	}
+
+	t.Run("Should reject migrations of the migrations collection", func(t *testing.T) {
+		store := NewStore()
+
+		err := store.RegisterMigrations(MigrationsCollection, Migration{Version: 1, Steps: []MigrationStep{step}})
+		assert.ErrorIs(t, err, ErrInvalidMigration)
+	})
+}
+
+func TestStore_Migrate(t *testing.T) {
+	t.Run("Should apply pending migrations in version order", func(t *testing.T) {
+		store := NewStore()
+		users := newMigrationUsers(t, store, 5)
+		assert.Nil(t, store.RegisterMigrations("users",
+			Migration{Version: 2, Description: "backfill status", Steps: []MigrationStep{
+				BackfillField("profile.status", "active"),
+			}},
+			Migration{Version: 1, Description: "split profile", Steps: []MigrationStep{
+				RenameField("name", "profile.name"),
+				ChangeFieldType("age", DocumentFieldTypeNumber),
+			}},
+		))
+
+		report, err := store.Migrate("users", MigrateOptions{BatchSize: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, &MigrationReport{
+			Collection: "users",
+			From:       0,
+			To:         2,
+			Migrations: []MigrationResult{
+				{Version: 1, Description: "split profile", Scanned: 5, Changed: 5},
+				{Version: 2, Description: "backfill status", Scanned: 5, Changed: 5},
+			},
+		}, report)
+
+		doc, _ := users.Get("3")
+		assert.Equal(t, map[string]interface{}{"name": "User 3", "status": "active"}, doc.GetField("profile"))
+		assert.Equal(t, 23.0, doc.GetField("age"))
+		assert.Nil(t, validateDocument(*doc, nil))
+		version, err := store.MigrationVersion("users")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, version)
+	})
+
+	t.Run("Should skip applied migrations", func(t *testing.T) {
+		store := NewStore()
+		users := newMigrationUsers(t, store, 3)
+		assert.Nil(t, store.RegisterMigrations("users", Migration{Version: 1, Steps: []MigrationStep{BackfillField("active", true)}}))
+		_, err := store.Migrate("users", MigrateOptions{})
+		assert.Nil(t, err)
+
+		report, err := store.Migrate("users", MigrateOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, &MigrationReport{Collection: "users", From: 1, To: 1}, report)
+
+		users.Put(userDocument("4", "New"))
+		assert.Nil(t, store.RegisterMigrations("users", Migration{Version: 2, Steps: []MigrationStep{BackfillField("active", false)}}))
+		report, err = store.Migrate("users", MigrateOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, []MigrationResult{{Version: 2, Scanned: 4, Changed: 1}}, report.Migrations)
+		doc, _ := users.Get("4")
+		assert.Equal(t, false, doc.GetField("active"))
+		doc, _ = users.Get("1")
+		assert.Equal(t, true, doc.GetField("active"))
+	})
+
+	t.Run("Should validate migrated documents against the schema", func(t *testing.T) {
+		store := NewStore()
+		users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Schema: &Schema{Fields: map[string]FieldSchema{
+			"name": {Types: []DocumentFieldType{DocumentFieldTypeString}},
+		}}})
+		users.Put(userDocument("1", "John"))
+		assert.Nil(t, store.RegisterMigrations("users", Migration{Version: 1, Steps: []MigrationStep{ChangeFieldType("name", DocumentFieldTypeArray)}}))
+
+		report, err := store.Migrate("users", MigrateOptions{})
+		assert.ErrorIs(t, err, ErrValidationFailed)
+		assert.ErrorIs(t, err, &Error{Collection: "users", ID: "1"})
+		assert.Equal(t, 0, report.To)
+		assert.Len(t, report.Migrations[0].Failures, 1)
+		doc, _ := users.Get("1")
+		assert.Equal(t, "John", doc.GetField("name"))
+	})
+
+	t.Run("Should reject changes of the primary key", func(t *testing.T) {
+		store := NewStore()
+		newMigrationUsers(t, store, 1)
+		assert.Nil(t, store.RegisterMigrations("users", Migration{Version: 1, Steps: []MigrationStep{RenameField("id", "key")}}))
+
+		_, err := store.Migrate("users", MigrateOptions{})
+		assert.ErrorIs(t, err, ErrMigrationFailed)
+		assert.ErrorIs(t, err, &Error{Code: CodeFailedPrecondition, ID: "1", Field: "id"})
+	})
+
+	t.Run("Should fail for a missing collection", func(t *testing.T) {
+		_, err := NewStore().Migrate("users", MigrateOptions{})
+		assert.ErrorIs(t, err, ErrCollectionNotFound)
+	})
+
+	t.Run("Should resume after a crash", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "store.db")
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		newMigrationUsers(t, store, 5)
+
+		calls := map[string]int{}
+		crash := true
+		migration := Migration{Version: 1, Steps: []MigrationStep{
+			func(doc Document) (Document, error) {
+				id := doc.GetField("id").(string)
+				calls[id]++
+				if id == "4" && crash {
+					return doc, errors.New("crash")
+				}
+				return doc, nil
+			},
+			BackfillField("active", true),
+		}}
+		assert.Nil(t, store.RegisterMigrations("users", migration))
+
+		report, err := store.Migrate("users", MigrateOptions{BatchSize: 2})
+		assert.ErrorContains(t, err, "crash")
+		assert.Equal(t, []MigrationResult{{Version: 1, Scanned: 4, Changed: 3, Failures: report.Migrations[0].Failures}}, report.Migrations)
+		assert.Equal(t, "4", report.Migrations[0].Failures[0].ID)
+		assert.Nil(t, store.Close())
+
+		store, err = OpenStore(filename)
+		assert.Nil(t, err)
+		defer store.Close()
+		version, _ := store.MigrationVersion("users")
+		assert.Equal(t, 0, version)
+		crash = false
+		assert.Nil(t, store.RegisterMigrations("users", migration))
+
+		report, err = store.Migrate("users", MigrateOptions{BatchSize: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, []MigrationResult{{Version: 1, Scanned: 3, Changed: 2}}, report.Migrations)
+		assert.Equal(t, map[string]int{"1": 1, "2": 1, "3": 2, "4": 2, "5": 1}, calls)
+		users, _ := store.GetCollection("users")
+		for _, doc := range users.List() {
+			assert.Equal(t, true, doc.GetField("active"))
+		}
+		version, _ = store.MigrationVersion("users")
+		assert.Equal(t, 1, version)
+	})
+
+	t.Run("Should keep progress when ctx is done", func(t *testing.T) {
+		store := NewStore()
+		newMigrationUsers(t, store, 5)
+		ctx, cancel := context.WithCancel(context.Background())
+		assert.Nil(t, store.RegisterMigrations("users", Migration{Version: 1, Steps: []MigrationStep{
+			func(doc Document) (Document, error) {
+				if doc.GetField("id") == "2" {
+					cancel()
+				}
+				return doc, nil
+			},
+			BackfillField("active", true),
+		}}))
+
+		_, err := store.MigrateContext(ctx, "users", MigrateOptions{BatchSize: 2})
+		assert.ErrorIs(t, err, context.Canceled)
+		state, _ := store.migrationState(context.Background(), "users")
+		assert.Equal(t, migrationState{migrating: 1, cursor: "2", stored: true}, state)
+
+		report, err := store.Migrate("users", MigrateOptions{BatchSize: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, []MigrationResult{{Version: 1, Scanned: 3, Changed: 3}}, report.Migrations)
+	})
+
+	t.Run("Should report a dry run without writing", func(t *testing.T) {
+		store := NewStore()
+		users := newMigrationUsers(t, store, 4)
+		users.Replace(Document{Fields: map[string]DocumentField{
+			"id":   newField("3"),
+			"name": newField("User 3"),
+			"age":  newField("unknown"),
+		}})
+		assert.Nil(t, store.RegisterMigrations("users",
+			Migration{Version: 1, Steps: []MigrationStep{ChangeFieldType("age", DocumentFieldTypeNumber)}},
+			Migration{Version: 2, Steps: []MigrationStep{RenameField("age", "years")}},
+		))
+
+		report, err := store.Migrate("users", MigrateOptions{DryRun: true, BatchSize: 3})
+		assert.Nil(t, err)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 0, report.To)
+		assert.Equal(t, MigrationResult{Version: 1, Scanned: 4, Changed: 3, Failures: report.Migrations[0].Failures}, report.Migrations[0])
+		assert.Equal(t, MigrationResult{Version: 2, Scanned: 3, Changed: 3}, report.Migrations[1])
+		assert.Len(t, report.Migrations[0].Failures, 1)
+		assert.Equal(t, "3", report.Migrations[0].Failures[0].ID)
+		assert.ErrorIs(t, report.Migrations[0].Failures[0].Err, &Error{Collection: "users", ID: "3", Field: "age", Code: CodeFailedPrecondition})
+
+		doc, _ := users.Get("1")
+		assert.Equal(t, "21", doc.GetField("age"))
+		_, exists := store.GetCollection(MigrationsCollection)
+		assert.False(t, exists)
+	})
+}
+
+func TestMigrationSteps(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	conversions := []struct {
+		value    interface{}
+		t        DocumentFieldType
+		expected interface{}
+	}{
+		{"42", DocumentFieldTypeNumber, 42.0},
+		{true, DocumentFieldTypeNumber, 1.0},
+		{Decimal("1.50"), DocumentFieldTypeNumber, 1.5},
+		{1.5, DocumentFieldTypeString, "1.5"},
+		{false, DocumentFieldTypeString, "false"},
+		{at, DocumentFieldTypeString, "2024-01-01T12:00:00Z"},
+		{"12.50", DocumentFieldTypeDecimal, Decimal("12.50")},
+		{2.5, DocumentFieldTypeDecimal, Decimal("2.5")},
+		{"true", DocumentFieldTypeBool, true},
+		{0, DocumentFieldTypeBool, false},
+		{"2024-01-01T12:00:00Z", DocumentFieldTypeDateTime, at},
+		{"tag", DocumentFieldTypeArray, []interface{}{"tag"}},
+	}
+	for _, c := range conversions {
+		t.Run("Should convert "+string(GetValueType(c.value))+" to "+string(c.t), func(t *testing.T) {
+			doc, err := ChangeFieldType("value", c.t)(Document{Fields: map[string]DocumentField{"value": newField(c.value)}})
+			assert.Nil(t, err)
+			assert.Equal(t, newField(c.expected), doc.Fields["value"])
+		})
+	}
+
+	t.Run("Should fail to convert invalid values", func(t *testing.T) {
+		_, err := ChangeFieldType("address.zip", DocumentFieldTypeNumber)(Document{Fields: map[string]DocumentField{
+			"address": newField(map[string]interface{}{"zip": "n/a"}),
+		}})
+		assert.ErrorIs(t, err, ErrMigrationFailed)
+		assert.ErrorIs(t, err, &Error{Field: "address.zip"})
+	})
+
+	t.Run("Should leave missing and null values", func(t *testing.T) {
+		doc := Document{Fields: map[string]DocumentField{"value": newField(nil)}}
+		migrated, err := ChangeFieldType("value", DocumentFieldTypeNumber)(doc)
+		assert.Nil(t, err)
+		assert.Equal(t, doc, migrated)
+		migrated, err = RenameField("missing", "other")(doc)
+		assert.Nil(t, err)
+		assert.Equal(t, doc, migrated)
+	})
+
+	t.Run("Should rename nested fields", func(t *testing.T) {
+		doc := Document{Fields: map[string]DocumentField{
+			"address": newField(map[string]interface{}{"zip": "01001", "city": "Kyiv"}),
+		}}
+
+		migrated, err := RenameField("address.zip", "address.postalCode")(doc)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"postalCode": "01001", "city": "Kyiv"}, migrated.GetField("address"))
+		assert.Equal(t, map[string]interface{}{"zip": "01001", "city": "Kyiv"}, doc.GetField("address"))
+
+		_, err = RenameField("address.zip", "address.city")(doc)
+		assert.ErrorIs(t, err, ErrMigrationFailed)
+	})
+
+	t.Run("Should backfill missing fields only", func(t *testing.T) {
+		doc := Document{Fields: map[string]DocumentField{"plan": newField("pro")}}
+
+		migrated, err := BackfillField("plan", "free")(doc)
+		assert.Nil(t, err)
+		assert.Equal(t, "pro", migrated.GetField("plan"))
+		migrated, err = BackfillField("limits.seats", 1)(doc)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"seats": 1}, migrated.GetField("limits"))
+	})
+}
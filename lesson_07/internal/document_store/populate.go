@@ -0,0 +1,151 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrInvalidPopulate = errors.New("invalid populate")
+
+// Populate names a reference field to replace by the referenced document.
+// Nested populates apply to the referenced document, e.g. the author of the
+// book of a review:
+//
+//	Populate{Field: "book", Nested: []Populate{{Field: "author"}}}
+type Populate struct {
+	Field  string
+	Nested []Populate
+}
+
+// DanglingReference replaces a populated field whose referenced document
+// does not exist.
+type DanglingReference struct {
+	Collection string `json:"$dangling"`
+	ID         string `json:"id"`
+}
+
+// ReadOption changes the documents returned by Collection.Get and
+// Collection.List.
+type ReadOption func(o *readOptions)
+
+type readOptions struct {
+	populate   []Populate
+	projection *Projection
+}
+
+// WithPopulate embeds referenced documents, see Populate. It requires a
+// collection of a Store; the reference fields are taken from
+// CollectionConfig.References.
+func WithPopulate(fields ...Populate) ReadOption {
+	return func(o *readOptions) {
+		o.populate = append(o.populate, fields...)
+	}
+}
+
+func newReadOptions(opts []ReadOption) readOptions {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// read applies read options to a document. Must be called without s.mu
+// held, populating reads other collections.
+func (s *Collection) read(ctx context.Context, doc Document, o readOptions) (Document, error) {
+	doc, err := s.populate(ctx, doc, o.populate)
+	if err != nil || o.projection == nil {
+		return doc, err
+	}
+	if err := o.projection.validate(s.cfg.PrimaryKey); err != nil {
+		return doc, s.docError("", "", err)
+	}
+
+	return o.projection.apply(doc, s.cfg.PrimaryKey), nil
+}
+
+func (s *Collection) populate(ctx context.Context, doc Document, fields []Populate) (Document, error) {
+	if len(fields) == 0 {
+		return doc, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return doc, err
+	}
+	if s.store == nil {
+		return doc, s.docError("", "", fmt.Errorf("%w: collection is not in a store", ErrInvalidPopulate))
+	}
+
+	for _, field := range fields {
+		i := slices.IndexFunc(s.cfg.References, func(ref ReferenceConfig) bool { return ref.Field == field.Field })
+		if i < 0 {
+			return doc, s.docError("", field.Field, fmt.Errorf("%w: not a reference", ErrInvalidPopulate))
+		}
+		ref := s.cfg.References[i]
+
+		value, ok := lookupPath(doc, field.Field)
+		id, isString := value.(string)
+		if !ok || !isString {
+			continue
+		}
+
+		var populated interface{} = DanglingReference{Collection: ref.Collection, ID: id}
+		if target, exists := s.store.GetCollection(ref.Collection); exists {
+			referenced, err := target.GetContext(ctx, id)
+			switch {
+			case err == nil:
+				nested, err := target.populate(ctx, *referenced, field.Nested)
+				if err != nil {
+					return doc, err
+				}
+				populated = documentValues(nested)
+			case !errors.Is(err, ErrDocumentNotFound):
+				return doc, err
+			}
+		}
+
+		if doc, ok = setPath(doc, field.Field, populated); !ok {
+			return doc, s.docError("", field.Field, fmt.Errorf("%w: cannot set populated document", ErrInvalidPopulate))
+		}
+	}
+
+	return doc, nil
+}
+
+type populateStage struct {
+	fields []Populate
+}
+
+// PopulateStage embeds referenced documents into the documents of an
+// aggregation, see Populate. References are resolved with the configuration
+// of the aggregated collection, so the stage must come before stages
+// reshaping documents, such as Group or Project.
+func PopulateStage(fields ...Populate) Stage {
+	return populateStage{fields: fields}
+}
+
+func (s populateStage) apply(p *pipeline, docs []Document) ([]Document, error) {
+	populated := make([]Document, len(docs))
+	for i, doc := range docs {
+		var err error
+		if populated[i], err = p.source.populate(p.ctx, doc, s.fields); err != nil {
+			return nil, err
+		}
+	}
+
+	return populated, nil
+}
+
+// listWith applies read options to listed documents.
+func (s *Collection) listWith(ctx context.Context, docs []Document, o readOptions) ([]Document, error) {
+	for i, doc := range docs {
+		var err error
+		if docs[i], err = s.read(ctx, doc, o); err != nil {
+			return nil, err
+		}
+	}
+
+	return docs, nil
+}
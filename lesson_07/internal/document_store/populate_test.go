@@ -0,0 +1,133 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func reviewDocument(id string, book string) Document {
+	doc := userDocument(id, "Review "+id)
+	doc.Fields["book"] = DocumentField{Type: DocumentFieldTypeString, Value: book}
+	return doc
+}
+
+func newReviewsStore(t *testing.T) *Store {
+	store := newLibraryStore(t, ReferenceSetNull)
+	reviews, _ := store.CreateCollection("reviews", &CollectionConfig{
+		PrimaryKey: "id",
+		References: []ReferenceConfig{{Field: "book", Collection: "books", OnDelete: ReferenceCascade}},
+	})
+	_, err := reviews.Put(reviewDocument("r1", "b1"))
+	assert.Nil(t, err)
+	_, err = reviews.Put(reviewDocument("r2", "b2"))
+	assert.Nil(t, err)
+
+	return store
+}
+
+func TestCollection_Populate(t *testing.T) {
+	t.Run("Should embed referenced documents", func(t *testing.T) {
+		store := newLibraryStore(t, "")
+		books, _ := store.GetCollection("books")
+
+		book, err := books.Get("b1", WithPopulate(Populate{Field: "author"}))
+		assert.Nil(t, err)
+		assert.Equal(t, DocumentField{
+			Type:  DocumentFieldTypeObject,
+			Value: map[string]interface{}{"id": "a1", "name": "Taras"},
+		}, book.Fields["author"])
+		assert.Equal(t, "Book b1", book.GetField("title"))
+
+		stored, _ := books.Get("b1")
+		assert.Equal(t, "a1", stored.GetField("author"))
+	})
+
+	t.Run("Should embed nested references", func(t *testing.T) {
+		store := newReviewsStore(t)
+		reviews, _ := store.GetCollection("reviews")
+
+		review, err := reviews.Get("r1", WithPopulate(Populate{Field: "book", Nested: []Populate{{Field: "author"}}}))
+		assert.Nil(t, err)
+		book := review.GetField("book").(map[string]interface{})
+		assert.Equal(t, "Book b1", book["title"])
+		assert.Equal(t, map[string]interface{}{"id": "a1", "name": "Taras"}, book["author"])
+
+		review, err = reviews.Get("r1", WithPopulate(Populate{Field: "book"}))
+		assert.Nil(t, err)
+		assert.Equal(t, "a1", review.GetField("book").(map[string]interface{})["author"])
+	})
+
+	t.Run("Should mark dangling references", func(t *testing.T) {
+		store := NewStore()
+		books, _ := store.CreateCollection("books", &CollectionConfig{PrimaryKey: "id"})
+		books.Put(bookDocument("b1", "a1"))
+		books.cfg.References = []ReferenceConfig{{Field: "author", Collection: "authors"}}
+
+		book, err := books.Get("b1", WithPopulate(Populate{Field: "author"}))
+		assert.Nil(t, err)
+		assert.Equal(t, DanglingReference{Collection: "authors", ID: "a1"}, book.GetField("author"))
+
+		store.CreateCollection("authors", &CollectionConfig{PrimaryKey: "id"})
+		book, err = books.Get("b1", WithPopulate(Populate{Field: "author"}))
+		assert.Nil(t, err)
+		assert.Equal(t, DanglingReference{Collection: "authors", ID: "a1"}, book.GetField("author"))
+	})
+
+	t.Run("Should skip missing and null references", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceSetNull)
+		authors, _ := store.GetCollection("authors")
+		books, _ := store.GetCollection("books")
+		authors.Delete("a1")
+		books.Put(bookDocument("b3", nil))
+
+		book, err := books.Get("b1", WithPopulate(Populate{Field: "author"}))
+		assert.Nil(t, err)
+		assert.Nil(t, book.GetField("author"))
+		book, err = books.Get("b3", WithPopulate(Populate{Field: "author"}))
+		assert.Nil(t, err)
+		assert.NotContains(t, book.Fields, "author")
+	})
+
+	t.Run("Should populate listed documents", func(t *testing.T) {
+		store := newLibraryStore(t, "")
+		books, _ := store.GetCollection("books")
+
+		names := map[string]interface{}{}
+		for _, book := range books.List(WithPopulate(Populate{Field: "author"})) {
+			names[book.GetField("id").(string)] = book.GetField("author").(map[string]interface{})["name"]
+		}
+		assert.Equal(t, map[string]interface{}{"b1": "Taras", "b2": "Lesya"}, names)
+	})
+
+	t.Run("Should populate aggregation results", func(t *testing.T) {
+		store := newReviewsStore(t)
+
+		docs, err := store.Aggregate("reviews",
+			Match(Eq("id", "r2")),
+			PopulateStage(Populate{Field: "book", Nested: []Populate{{Field: "author"}}}),
+			Project(map[string]string{"author": "book.author.name"}),
+		)
+		assert.Nil(t, err)
+		assert.Len(t, docs, 1)
+		assert.Equal(t, "Lesya", docs[0].GetField("author"))
+
+		reviews, _ := store.GetCollection("reviews")
+		docs, err = reviews.Aggregate(PopulateStage(Populate{Field: "book"}))
+		assert.Nil(t, err)
+		assert.Len(t, docs, 2)
+	})
+
+	t.Run("Should reject fields that are not references", func(t *testing.T) {
+		store := newLibraryStore(t, "")
+		books, _ := store.GetCollection("books")
+
+		_, err := books.Get("b1", WithPopulate(Populate{Field: "title"}))
+		assert.ErrorIs(t, err, ErrInvalidPopulate)
+		assert.Nil(t, books.List(WithPopulate(Populate{Field: "title"})))
+
+		standalone := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		standalone.Put(bookDocument("b1", "a1"))
+		_, err = standalone.Get("b1", WithPopulate(Populate{Field: "author"}))
+		assert.ErrorIs(t, err, ErrInvalidPopulate)
+	})
+}
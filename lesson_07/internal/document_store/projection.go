@@ -0,0 +1,257 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidProjection = errors.New("invalid projection")
+
+// Projection selects the parts of documents to return, see WithProjection.
+// Paths are dotted; a path leading through an array applies to the objects
+// it holds, e.g. "items.price" keeps the price of every item.
+type Projection struct {
+	// Include keeps only the values at these paths, and the primary key
+	// unless it is excluded. Elements of arrays that are not objects are
+	// left out of included arrays.
+	Include []string
+	// Exclude removes the values at these paths. Along with Include, only
+	// the primary key may be excluded.
+	Exclude []string
+	// Slice keeps part of the arrays at these paths. It applies to what
+	// Include and Exclude keep.
+	Slice map[string]ArraySlice
+}
+
+// ArraySlice selects Limit elements of an array starting at Skip; a
+// negative Skip counts from the end and Limit 0 keeps the elements up to the
+// end. E.g. {Limit: 5} keeps the first five elements and {Skip: -5} the last
+// five.
+type ArraySlice struct {
+	Skip  int
+	Limit int
+}
+
+// WithProjection returns only the parts of documents selected by p. It
+// applies after WithPopulate, so paths may lead into populated documents.
+func WithProjection(p Projection) ReadOption {
+	return func(o *readOptions) {
+		o.projection = &p
+	}
+}
+
+func (p Projection) validate(primaryKey string) error {
+	for _, path := range slices.Concat(p.Include, p.Exclude, slices.Collect(maps.Keys(p.Slice))) {
+		if path == "" || slices.Contains(strings.Split(path, "."), "") {
+			return fmt.Errorf("%w: invalid path %q", ErrInvalidProjection, path)
+		}
+	}
+	if len(p.Include) > 0 && slices.ContainsFunc(p.Exclude, func(path string) bool { return path != primaryKey }) {
+		return fmt.Errorf("%w: only the primary key may be excluded along with included paths", ErrInvalidProjection)
+	}
+	for path, s := range p.Slice {
+		if s.Limit < 0 {
+			return fmt.Errorf("%w: negative limit of slice %q", ErrInvalidProjection, path)
+		}
+	}
+
+	return nil
+}
+
+// apply returns doc with the projection applied. The stored values are not
+// modified: changed containers are copies.
+func (p Projection) apply(doc Document, primaryKey string) Document {
+	var fields map[string]DocumentField
+	if len(p.Include) > 0 {
+		tree := pathTree{}
+		for _, path := range p.Include {
+			tree.add(strings.Split(path, "."))
+		}
+		if !slices.Contains(p.Exclude, primaryKey) {
+			tree.add([]string{primaryKey})
+		}
+
+		fields = make(map[string]DocumentField, len(tree))
+		for name, subtree := range tree {
+			field, ok := doc.Fields[name]
+			if !ok {
+				continue
+			}
+			if subtree == nil {
+				fields[name] = field
+			} else if value, ok := includeValue(field.Value, subtree); ok {
+				fields[name] = newField(value)
+			}
+		}
+	} else {
+		fields = maps.Clone(doc.Fields)
+	}
+
+	for _, path := range p.Exclude {
+		name, rest, nested := strings.Cut(path, ".")
+		if !nested {
+			delete(fields, name)
+		} else if field, ok := fields[name]; ok {
+			fields[name] = newField(excludeValue(field.Value, strings.Split(rest, ".")))
+		}
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(p.Slice)) {
+		name, rest, nested := strings.Cut(path, ".")
+		field, ok := fields[name]
+		if !ok {
+			continue
+		}
+		var segments []string
+		if nested {
+			segments = strings.Split(rest, ".")
+		}
+		fields[name] = newField(sliceValue(field.Value, segments, p.Slice[path]))
+	}
+
+	doc.Fields = fields
+	return doc
+}
+
+// pathTree holds included paths by segment; a nil subtree includes the
+// whole value.
+type pathTree map[string]pathTree
+
+func (t pathTree) add(path []string) {
+	subtree, exists := t[path[0]]
+	switch {
+	case len(path) == 1:
+		t[path[0]] = nil
+	case !exists:
+		subtree = pathTree{}
+		t[path[0]] = subtree
+		subtree.add(path[1:])
+	case subtree != nil:
+		subtree.add(path[1:])
+	}
+}
+
+// includeValue returns the parts of value in tree. ok is false for values
+// that hold no fields.
+func includeValue(value interface{}, tree pathTree) (_ interface{}, ok bool) {
+	switch container := normalizeContainer(value).(type) {
+	case map[string]interface{}:
+		included := make(map[string]interface{}, len(tree))
+		for name, subtree := range tree {
+			item, ok := container[name]
+			if !ok {
+				continue
+			}
+			if subtree == nil {
+				included[name] = item
+			} else if item, ok = includeValue(item, subtree); ok {
+				included[name] = item
+			}
+		}
+		return included, true
+	case []interface{}:
+		included := make([]interface{}, 0, len(container))
+		for _, item := range container {
+			if item, ok := includeValue(item, tree); ok {
+				included = append(included, item)
+			}
+		}
+		return included, true
+	default:
+		return nil, false
+	}
+}
+
+// excludeValue returns value without the values at path.
+func excludeValue(value interface{}, path []string) interface{} {
+	switch container := normalizeContainer(value).(type) {
+	case map[string]interface{}:
+		item, ok := container[path[0]]
+		if !ok {
+			return value
+		}
+		if len(path) == 1 {
+			delete(container, path[0])
+		} else {
+			container[path[0]] = excludeValue(item, path[1:])
+		}
+		return container
+	case []interface{}:
+		for i, item := range container {
+			container[i] = excludeValue(item, path)
+		}
+		return container
+	default:
+		return value
+	}
+}
+
+// sliceValue returns value with s applied to the arrays at path.
+func sliceValue(value interface{}, path []string, s ArraySlice) interface{} {
+	container := normalizeContainer(value)
+	items, isArray := container.([]interface{})
+	if len(path) == 0 {
+		if !isArray {
+			return value
+		}
+		return s.apply(items)
+	}
+
+	switch container := container.(type) {
+	case map[string]interface{}:
+		item, ok := container[path[0]]
+		if !ok {
+			return value
+		}
+		container[path[0]] = sliceValue(item, path[1:], s)
+		return container
+	case []interface{}:
+		for i, item := range container {
+			container[i] = sliceValue(item, path, s)
+		}
+		return container
+	default:
+		return value
+	}
+}
+
+func (s ArraySlice) apply(items []interface{}) []interface{} {
+	start := s.Skip
+	if start < 0 {
+		start = max(len(items)+start, 0)
+	}
+	start = min(start, len(items))
+	end := len(items)
+	if s.Limit > 0 {
+		end = min(start+s.Limit, end)
+	}
+
+	return items[start:end]
+}
+
+type projectionStage struct {
+	projection Projection
+}
+
+// ProjectionStage selects parts of the documents of an aggregation, see
+// Projection. The primary key is the one of the aggregated collection.
+func ProjectionStage(p Projection) Stage {
+	return projectionStage{projection: p}
+}
+
+func (s projectionStage) apply(p *pipeline, docs []Document) ([]Document, error) {
+	primaryKey := p.source.cfg.PrimaryKey
+	if err := s.projection.validate(primaryKey); err != nil {
+		return nil, p.source.docError("", "", err)
+	}
+
+	projected := make([]Document, len(docs))
+	for i, doc := range docs {
+		projected[i] = s.projection.apply(doc, primaryKey)
+	}
+
+	return projected, nil
+}
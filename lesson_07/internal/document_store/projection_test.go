@@ -0,0 +1,153 @@
+package documentstore
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func newProjectionOrders(t *testing.T) *Collection {
+	collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+	for _, doc := range []Document{orderDocument("1", "john", 30, "apple", "pear"), orderDocument("2", "jane", 10, "plum")} {
+		doc.Fields["shipping"] = newField(map[string]interface{}{
+			"city":    "Kyiv",
+			"zip":     "01001",
+			"contact": map[string]interface{}{"name": "John", "phone": "555"},
+		})
+		doc.Fields["lines"] = newField([]interface{}{
+			map[string]interface{}{"sku": "a", "qty": 1, "price": 10},
+			map[string]interface{}{"sku": "b", "qty": 2, "price": 5},
+			"gift",
+		})
+		doc.Fields["events"] = newField([]interface{}{1, 2, 3, 4, 5})
+		_, err := collection.Put(doc)
+		assert.Nil(t, err)
+	}
+
+	return collection
+}
+
+func TestCollection_Projection(t *testing.T) {
+	t.Run("Should include paths and the primary key", func(t *testing.T) {
+		collection := newProjectionOrders(t)
+
+		doc, err := collection.Get("1", WithProjection(Projection{Include: []string{"customer", "shipping.contact.name", "shipping.city", "lines.sku"}}))
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"id":       "1",
+			"customer": "john",
+			"shipping": map[string]interface{}{"city": "Kyiv", "contact": map[string]interface{}{"name": "John"}},
+			"lines":    []interface{}{map[string]interface{}{"sku": "a"}, map[string]interface{}{"sku": "b"}},
+		}, documentValues(*doc))
+		assert.Nil(t, validateDocument(*doc, nil))
+
+		stored, _ := collection.Get("1")
+		assert.Len(t, stored.GetField("shipping"), 3)
+	})
+
+	t.Run("Should include whole values over their paths", func(t *testing.T) {
+		collection := newProjectionOrders(t)
+
+		doc, err := collection.Get("1", WithProjection(Projection{Include: []string{"shipping.city", "shipping", "missing.path"}, Exclude: []string{"id"}}))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"shipping"}, slices.Sorted(maps.Keys(doc.Fields)))
+		assert.Len(t, doc.GetField("shipping"), 3)
+	})
+
+	t.Run("Should exclude paths", func(t *testing.T) {
+		collection := newProjectionOrders(t)
+
+		docs, err := collection.ListContext(context.Background(), WithProjection(Projection{Exclude: []string{"items", "shipping.contact.phone", "lines.price", "missing"}}))
+		assert.Nil(t, err)
+		assert.Len(t, docs, 2)
+		assert.Equal(t, []string{"customer", "events", "id", "lines", "shipping", "total"}, slices.Sorted(maps.Keys(docs[0].Fields)))
+		assert.Equal(t, map[string]interface{}{"name": "John"}, docs[0].GetField("shipping").(map[string]interface{})["contact"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"sku": "a", "qty": 1},
+			map[string]interface{}{"sku": "b", "qty": 2},
+			"gift",
+		}, docs[0].GetField("lines"))
+
+		stored, _ := collection.Get("1")
+		assert.Len(t, stored.GetField("lines").([]interface{})[0], 3)
+	})
+
+	t.Run("Should slice arrays", func(t *testing.T) {
+		collection := newProjectionOrders(t)
+
+		tests := map[string]struct {
+			slice    ArraySlice
+			expected []interface{}
+		}{
+			"first":         {ArraySlice{Limit: 2}, []interface{}{1, 2}},
+			"last":          {ArraySlice{Skip: -2}, []interface{}{4, 5}},
+			"middle":        {ArraySlice{Skip: 1, Limit: 3}, []interface{}{2, 3, 4}},
+			"from the end":  {ArraySlice{Skip: -4, Limit: 2}, []interface{}{2, 3}},
+			"past the end":  {ArraySlice{Skip: 7}, []interface{}{}},
+			"more than all": {ArraySlice{Skip: -9, Limit: 9}, []interface{}{1, 2, 3, 4, 5}},
+		}
+		for name, test := range tests {
+			doc, err := collection.Get("1", WithProjection(Projection{Slice: map[string]ArraySlice{"events": test.slice}}))
+			assert.Nil(t, err, name)
+			assert.Equal(t, test.expected, doc.GetField("events"), name)
+		}
+
+		doc, err := collection.Get("1", WithProjection(Projection{
+			Include: []string{"items", "shipping"},
+			Slice:   map[string]ArraySlice{"items": {Skip: -1}, "events": {Limit: 1}, "shipping.city": {Limit: 1}},
+		}))
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"pear"}, doc.GetField("items"))
+		assert.NotContains(t, doc.Fields, "events")
+		assert.Equal(t, "Kyiv", doc.GetField("shipping").(map[string]interface{})["city"])
+	})
+
+	t.Run("Should project populated documents", func(t *testing.T) {
+		store := newReviewsStore(t)
+		reviews, _ := store.GetCollection("reviews")
+
+		doc, err := reviews.Get("r1", WithPopulate(Populate{Field: "book"}), WithProjection(Projection{Include: []string{"book.title"}}))
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"id": "r1", "book": map[string]interface{}{"title": "Book b1"}}, documentValues(*doc))
+	})
+
+	t.Run("Should reject invalid projections", func(t *testing.T) {
+		collection := newProjectionOrders(t)
+
+		tests := map[string]Projection{
+			"empty path":          {Include: []string{"shipping..city"}},
+			"include and exclude": {Include: []string{"customer"}, Exclude: []string{"total"}},
+			"negative limit":      {Slice: map[string]ArraySlice{"events": {Limit: -1}}},
+		}
+		for name, projection := range tests {
+			_, err := collection.Get("1", WithProjection(projection))
+			assert.ErrorIs(t, err, ErrInvalidProjection, name)
+			assert.ErrorIs(t, err, &Error{Code: CodeInvalidArgument}, name)
+			_, err = collection.ListContext(context.Background(), WithProjection(projection))
+			assert.ErrorIs(t, err, ErrInvalidProjection, name)
+		}
+	})
+}
+
+func TestCollection_Aggregate_Projection(t *testing.T) {
+	t.Run("Should project aggregated documents", func(t *testing.T) {
+		collection := newProjectionOrders(t)
+
+		docs, err := collection.Aggregate(
+			Match(Gt("total", 20)),
+			ProjectionStage(Projection{Include: []string{"lines.qty"}, Slice: map[string]ArraySlice{"lines": {Limit: 1}}}),
+		)
+		assert.Nil(t, err)
+		assert.Len(t, docs, 1)
+		assert.Equal(t, map[string]interface{}{"id": "1", "lines": []interface{}{map[string]interface{}{"qty": 1}}}, documentValues(docs[0]))
+	})
+
+	t.Run("Should reject invalid projections", func(t *testing.T) {
+		collection := newProjectionOrders(t)
+
+		_, err := collection.Aggregate(ProjectionStage(Projection{Include: []string{""}}))
+		assert.ErrorIs(t, err, ErrInvalidProjection)
+	})
+}
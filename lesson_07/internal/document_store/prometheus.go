@@ -0,0 +1,282 @@
+package documentstore
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histograms
+// of PrometheusMetrics. Operations of an in-memory collection take
+// microseconds, so the buckets start low.
+var DefaultBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// PrometheusMetrics is a Metrics keeping measurements in memory and serving
+// them over HTTP in the Prometheus text format:
+//
+//	metrics := NewPrometheusMetrics(store)
+//	SetMetrics(metrics)
+//	http.Handle("/metrics", metrics)
+//
+// Gauges of the documents and memory per collection are read from the store
+// on every scrape; memory of in-memory collections is approximated as
+// documents are written.
+type PrometheusMetrics struct {
+	store   *Store
+	buckets []float64
+
+	mu          sync.Mutex
+	operations  map[operationKey]*operationMetrics
+	persistence map[Operation]*persistenceMetrics
+}
+
+type operationKey struct {
+	collection string
+	op         Operation
+}
+
+type operationMetrics struct {
+	codes   map[ErrorCode]uint64
+	latency histogram
+}
+
+type persistenceMetrics struct {
+	codes    map[ErrorCode]uint64
+	latency  histogram
+	bytes    uint64
+	lastSize int
+}
+
+type histogram struct {
+	// counts are per bucket, not cumulative.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(buckets []float64, seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets))
+	}
+	if i, _ := slices.BinarySearch(buckets, seconds); i < len(buckets) {
+		h.counts[i]++
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// NewPrometheusMetrics returns metrics reporting gauges of store, which may
+// be nil, with DefaultBuckets.
+func NewPrometheusMetrics(store *Store) *PrometheusMetrics {
+	return &PrometheusMetrics{
+		store:       store,
+		buckets:     DefaultBuckets,
+		operations:  map[operationKey]*operationMetrics{},
+		persistence: map[Operation]*persistenceMetrics{},
+	}
+}
+
+func (m *PrometheusMetrics) ObserveOperation(collection string, op Operation, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := operationKey{collection: collection, op: op}
+	metrics, ok := m.operations[key]
+	if !ok {
+		metrics = &operationMetrics{codes: map[ErrorCode]uint64{}}
+		m.operations[key] = metrics
+	}
+	metrics.codes[resultCode(err)]++
+	metrics.latency.observe(m.buckets, duration.Seconds())
+}
+
+func (m *PrometheusMetrics) ObservePersistence(op Operation, duration time.Duration, size int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics, ok := m.persistence[op]
+	if !ok {
+		metrics = &persistenceMetrics{codes: map[ErrorCode]uint64{}}
+		m.persistence[op] = metrics
+	}
+	metrics.codes[resultCode(err)]++
+	metrics.latency.observe(m.buckets, duration.Seconds())
+	if err == nil {
+		metrics.bytes += uint64(size)
+		metrics.lastSize = size
+	}
+}
+
+// resultCode labels successful operations with "ok".
+func resultCode(err error) ErrorCode {
+	if err == nil {
+		return "ok"
+	}
+
+	return ErrorCodeOf(err)
+}
+
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := m.WriteTo(w); err != nil {
+		collectionLogger().Error("Failed to write metrics", "err", err)
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	out := bufio.NewWriter(counter)
+	m.writeGauges(out)
+
+	m.mu.Lock()
+	m.writeOperations(out)
+	m.writePersistence(out)
+	m.mu.Unlock()
+
+	err := out.Flush()
+	return counter.n, err
+}
+
+func (m *PrometheusMetrics) writeGauges(out *bufio.Writer) {
+	if m.store == nil {
+		return
+	}
+
+	m.store.mu.RLock()
+	collections := maps.Clone(m.store.Collections)
+	m.store.mu.RUnlock()
+	names := slices.Sorted(maps.Keys(collections))
+
+	writeHeader(out, "documentstore_documents", "gauge", "Documents per collection, including expired ones not yet removed.")
+	for _, name := range names {
+		collection := collections[name]
+		collection.mu.RLock()
+		n := collection.documents.len()
+		collection.mu.RUnlock()
+		writeSample(out, "documentstore_documents", labels("collection", name), float64(n))
+	}
+
+	writeHeader(out, "documentstore_memory_bytes", "gauge", "Approximate memory held by the documents of in-memory collections.")
+	for _, name := range names {
+		if size, ok := collections[name].memoryUsage(); ok {
+			writeSample(out, "documentstore_memory_bytes", labels("collection", name), float64(size))
+		}
+	}
+}
+
+func (m *PrometheusMetrics) writeOperations(out *bufio.Writer) {
+	keys := slices.SortedFunc(maps.Keys(m.operations), func(a, b operationKey) int {
+		return cmp.Or(strings.Compare(a.collection, b.collection), strings.Compare(string(a.op), string(b.op)))
+	})
+
+	writeHeader(out, "documentstore_operations_total", "counter", "Operations on collections by result code.")
+	for _, key := range keys {
+		codes := m.operations[key].codes
+		for _, code := range slices.Sorted(maps.Keys(codes)) {
+			writeSample(out, "documentstore_operations_total", labels("collection", key.collection, "operation", string(key.op), "code", string(code)), float64(codes[code]))
+		}
+	}
+
+	writeHeader(out, "documentstore_operation_duration_seconds", "histogram", "Latency of operations on collections.")
+	for _, key := range keys {
+		m.writeHistogram(out, "documentstore_operation_duration_seconds", labels("collection", key.collection, "operation", string(key.op)), &m.operations[key].latency)
+	}
+}
+
+func (m *PrometheusMetrics) writePersistence(out *bufio.Writer) {
+	ops := slices.Sorted(maps.Keys(m.persistence))
+
+	writeHeader(out, "documentstore_persistence_total", "counter", "Dumps and loads by result code.")
+	for _, op := range ops {
+		codes := m.persistence[op].codes
+		for _, code := range slices.Sorted(maps.Keys(codes)) {
+			writeSample(out, "documentstore_persistence_total", labels("operation", string(op), "code", string(code)), float64(codes[code]))
+		}
+	}
+
+	writeHeader(out, "documentstore_persistence_duration_seconds", "histogram", "Duration of dumps and loads.")
+	for _, op := range ops {
+		m.writeHistogram(out, "documentstore_persistence_duration_seconds", labels("operation", string(op)), &m.persistence[op].latency)
+	}
+
+	writeHeader(out, "documentstore_persistence_bytes_total", "counter", "Bytes of successful dumps and loads.")
+	for _, op := range ops {
+		writeSample(out, "documentstore_persistence_bytes_total", labels("operation", string(op)), float64(m.persistence[op].bytes))
+	}
+
+	writeHeader(out, "documentstore_persistence_last_bytes", "gauge", "Size of the last successful dump or load.")
+	for _, op := range ops {
+		writeSample(out, "documentstore_persistence_last_bytes", labels("operation", string(op)), float64(m.persistence[op].lastSize))
+	}
+}
+
+func (m *PrometheusMetrics) writeHistogram(out *bufio.Writer, name string, labels string, h *histogram) {
+	var cumulative uint64
+	for i, bound := range m.buckets {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		writeSample(out, name+"_bucket", withLabel(labels, "le", formatFloat(bound)), float64(cumulative))
+	}
+	writeSample(out, name+"_bucket", withLabel(labels, "le", "+Inf"), float64(h.count))
+	writeSample(out, name+"_sum", labels, h.sum)
+	writeSample(out, name+"_count", labels, float64(h.count))
+}
+
+func writeHeader(out *bufio.Writer, name string, kind string, help string) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSample(out *bufio.Writer, name string, labels string, value float64) {
+	fmt.Fprintf(out, "%s{%s} %s\n", name, labels, formatFloat(value))
+}
+
+// labels formats name-value pairs as the labels of a sample.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+
+	return b.String()
+}
+
+func withLabel(current string, name string, value string) string {
+	if current == "" {
+		return labels(name, value)
+	}
+
+	return current + "," + labels(name, value)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,87 @@
+package documentstore
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	t.Run("Should serve operation metrics", func(t *testing.T) {
+		metrics := NewPrometheusMetrics(nil)
+		metrics.ObserveOperation("users", OpGet, 20*time.Microsecond, nil)
+		metrics.ObserveOperation("users", OpGet, 2*time.Millisecond, ErrDocumentNotFound)
+		metrics.ObserveOperation(`a"b`, OpPut, time.Millisecond, nil)
+		metrics.ObservePersistence(OpDump, 30*time.Millisecond, 120, nil)
+		metrics.ObservePersistence(OpDump, 10*time.Millisecond, 80, nil)
+		metrics.ObservePersistence(OpLoad, time.Millisecond, 5, errors.New("bad dump"))
+
+		recorder := httptest.NewRecorder()
+		metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		body, _ := io.ReadAll(recorder.Body)
+		output := string(body)
+
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+		assert.Contains(t, output, "# TYPE documentstore_operations_total counter\n")
+		assert.Contains(t, output, `documentstore_operations_total{collection="users",operation="get",code="ok"} 1`)
+		assert.Contains(t, output, `documentstore_operations_total{collection="users",operation="get",code="not_found"} 1`)
+		assert.Contains(t, output, `documentstore_operations_total{collection="a\"b",operation="put",code="ok"} 1`)
+		assert.Contains(t, output, `documentstore_operation_duration_seconds_bucket{collection="users",operation="get",le="1e-05"} 0`)
+		assert.Contains(t, output, `documentstore_operation_duration_seconds_bucket{collection="users",operation="get",le="5e-05"} 1`)
+		assert.Contains(t, output, `documentstore_operation_duration_seconds_bucket{collection="users",operation="get",le="0.005"} 2`)
+		assert.Contains(t, output, `documentstore_operation_duration_seconds_bucket{collection="users",operation="get",le="+Inf"} 2`)
+		assert.Contains(t, output, `documentstore_operation_duration_seconds_count{collection="users",operation="get"} 2`)
+		assert.Contains(t, output, `documentstore_persistence_total{operation="load",code="internal"} 1`)
+		assert.Contains(t, output, `documentstore_persistence_bytes_total{operation="dump"} 200`)
+		assert.Contains(t, output, `documentstore_persistence_last_bytes{operation="dump"} 80`)
+		assert.Contains(t, output, `documentstore_persistence_duration_seconds_sum{operation="dump"} 0.04`)
+	})
+
+	t.Run("Should serve gauges of the store", func(t *testing.T) {
+		store, _ := newUsersStore(t)
+		files, err := OpenStore(filepath.Join(t.TempDir(), "store.db"))
+		assert.Nil(t, err)
+		t.Cleanup(func() { files.Close() })
+		events, _ := files.CreateCollection("events", &CollectionConfig{PrimaryKey: "id"})
+		events.Put(userDocument("1", "Started"))
+
+		for _, s := range []*Store{store, files} {
+			metrics := NewPrometheusMetrics(s)
+			recorder := httptest.NewRecorder()
+			metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+			body, _ := io.ReadAll(recorder.Body)
+			output := string(body)
+
+			if s == store {
+				assert.Contains(t, output, `documentstore_documents{collection="users"} 2`)
+				users, _ := store.GetCollection("users")
+				size, ok := users.memoryUsage()
+				assert.True(t, ok)
+				assert.Contains(t, output, `documentstore_memory_bytes{collection="users"} `+formatFloat(float64(size)))
+			} else {
+				assert.Contains(t, output, `documentstore_documents{collection="events"} 1`)
+				assert.NotContains(t, output, `documentstore_memory_bytes{`)
+			}
+		}
+	})
+
+	t.Run("Should collect metrics of a store", func(t *testing.T) {
+		store, users := newUsersStore(t)
+		metrics := NewPrometheusMetrics(store)
+		useMetrics(t, metrics)
+
+		users.Get("1")
+		_, err := store.Dump()
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		body, _ := io.ReadAll(recorder.Body)
+		assert.Contains(t, string(body), `documentstore_operations_total{collection="users",operation="get",code="ok"} 1`)
+		assert.Contains(t, string(body), `documentstore_persistence_total{operation="dump",code="ok"} 1`)
+	})
+}
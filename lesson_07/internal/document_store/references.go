@@ -0,0 +1,338 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrReferenceViolation = errors.New("reference violation")
+
+// ReferenceAction is what happens to referencing documents when the
+// referenced document or collection is deleted.
+type ReferenceAction string
+
+const (
+	// ReferenceRestrict rejects the delete. It is the default.
+	ReferenceRestrict ReferenceAction = "restrict"
+	// ReferenceCascade deletes referencing documents as well.
+	ReferenceCascade ReferenceAction = "cascade"
+	// ReferenceSetNull sets the reference field to null.
+	ReferenceSetNull ReferenceAction = "setNull"
+)
+
+// ReferenceConfig declares that Field holds the primary key of a document
+// in Collection of the same store. A missing or null field is not a
+// reference. References are enforced for collections of a Store only.
+type ReferenceConfig struct {
+	Field      string          `json:"field"`
+	Collection string          `json:"collection"`
+	OnDelete   ReferenceAction `json:"onDelete,omitempty"`
+}
+
+func validateReferences(references []ReferenceConfig) error {
+	for _, ref := range references {
+		if ref.Field == "" || ref.Collection == "" {
+			return fmt.Errorf("%w: reference requires field and collection", ErrInvalidCollectionConfig)
+		}
+		switch ref.OnDelete {
+		case "", ReferenceRestrict, ReferenceCascade, ReferenceSetNull:
+		default:
+			return fmt.Errorf("%w: unknown delete action %q", ErrInvalidCollectionConfig, ref.OnDelete)
+		}
+	}
+
+	return nil
+}
+
+// lockReferences serializes writes across the collections of a store when
+// any of them declares references, so that a check of a referenced
+// document and the write depending on it are not interleaved with deletes.
+// Collection locks are never nested under it; see deletePlan.
+func (s *Collection) lockReferences() func() {
+	if s.store == nil || !s.store.hasReferences() {
+		return func() {}
+	}
+
+	s.store.refMu.Lock()
+	return s.store.refMu.Unlock
+}
+
+func (s *Store) hasReferences() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, collection := range s.Collections {
+		if len(collection.cfg.References) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkReferences verifies that documents referenced by doc exist. Must be
+// called under lockReferences and without s.mu held.
+func (s *Collection) checkReferences(doc Document) error {
+	if s.store == nil {
+		return nil
+	}
+
+	docID, _ := doc.GetField(s.cfg.PrimaryKey).(string)
+	for _, ref := range s.cfg.References {
+		value, ok := lookupPath(doc, ref.Field)
+		if !ok || value == nil {
+			continue
+		}
+
+		id, ok := value.(string)
+		if !ok {
+			return s.docError(docID, ref.Field, fmt.Errorf("%w: must hold a primary key of %q, got %T", ErrReferenceViolation, ref.Collection, value))
+		}
+
+		target, ok := s.store.GetCollection(ref.Collection)
+		if !ok {
+			return s.docError(docID, ref.Field, fmt.Errorf("%w: references missing collection %q", ErrReferenceViolation, ref.Collection))
+		}
+		if _, err := target.Get(id); errors.Is(err, ErrDocumentNotFound) {
+			return s.docError(docID, ref.Field, fmt.Errorf("%w: references missing document '%s' of %q", ErrReferenceViolation, id, ref.Collection))
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type documentRef struct {
+	collection string
+	id         string
+}
+
+type referenceNull struct {
+	documentRef
+	field string
+}
+
+// deletePlan collects the effects of a delete on referencing documents
+// before anything is changed, so that a restricted reference anywhere in a
+// cascade rejects the whole delete.
+type deletePlan struct {
+	collections map[string]*Collection
+	deletes     []documentRef
+	deleted     map[documentRef]bool
+	nulls       []referenceNull
+	restricted  []referenceNull
+	// referrers holds the reference indexes of collections, by collection
+	// and field, built on first use.
+	referrers map[string]map[string]*referrerIndex
+}
+
+// referrerIndex holds the keys of the documents with a reference field, by
+// its value.
+type referrerIndex struct {
+	byValue map[string][]string
+	all     []string
+}
+
+func newDeletePlan(collections map[string]*Collection) *deletePlan {
+	return &deletePlan{
+		collections: collections,
+		deleted:     map[documentRef]bool{},
+		referrers:   map[string]map[string]*referrerIndex{},
+	}
+}
+
+// planDocumentDelete plans the delete of a document and everything it
+// cascades to.
+func (s *Store) planDocumentDelete(collection string, id string) (*deletePlan, error) {
+	s.mu.RLock()
+	collections := make(map[string]*Collection, len(s.Collections))
+	for name, c := range s.Collections {
+		collections[name] = c
+	}
+	s.mu.RUnlock()
+
+	plan := newDeletePlan(collections)
+	root := documentRef{collection: collection, id: id}
+	plan.deleted[root] = true
+	plan.cascade([]documentRef{root})
+
+	return plan, plan.check()
+}
+
+// planCollectionDelete plans the delete of a whole collection: every
+// document of other collections referencing it is affected. Must be called
+// with s.mu held.
+func (s *Store) planCollectionDelete(name string) (*deletePlan, error) {
+	collections := make(map[string]*Collection, len(s.Collections))
+	for other, c := range s.Collections {
+		if other != name {
+			collections[other] = c
+		}
+	}
+
+	plan := newDeletePlan(collections)
+	plan.cascade(plan.applyActions(name, func(index *referrerIndex) []string { return index.all }))
+
+	return plan, plan.check()
+}
+
+func (p *deletePlan) cascade(queue []documentRef) {
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+		queue = append(queue, p.applyActions(ref.collection, func(index *referrerIndex) []string { return index.byValue[ref.id] })...)
+	}
+}
+
+// applyActions applies delete actions to the documents matching references
+// to the collection, and returns newly cascaded deletes.
+func (p *deletePlan) applyActions(collection string, match func(index *referrerIndex) []string) []documentRef {
+	var cascaded []documentRef
+	for name, c := range p.collections {
+		for _, ref := range c.cfg.References {
+			if ref.Collection != collection {
+				continue
+			}
+
+			for _, id := range match(p.referrerIndex(name, ref.Field)) {
+				target := referenceNull{documentRef: documentRef{collection: name, id: id}, field: ref.Field}
+				switch ref.OnDelete {
+				case ReferenceCascade:
+					if !p.deleted[target.documentRef] {
+						p.deleted[target.documentRef] = true
+						p.deletes = append(p.deletes, target.documentRef)
+						cascaded = append(cascaded, target.documentRef)
+					}
+				case ReferenceSetNull:
+					p.nulls = append(p.nulls, target)
+				default:
+					p.restricted = append(p.restricted, target)
+				}
+			}
+		}
+	}
+
+	return cascaded
+}
+
+// referrerIndex returns the index of a reference field of a collection. The
+// documents of a collection are read once per plan, for all its references.
+func (p *deletePlan) referrerIndex(collection string, field string) *referrerIndex {
+	if indexes, ok := p.referrers[collection]; ok {
+		return indexes[field]
+	}
+
+	c := p.collections[collection]
+	indexes := make(map[string]*referrerIndex, len(c.cfg.References))
+	for _, ref := range c.cfg.References {
+		indexes[ref.Field] = &referrerIndex{byValue: map[string][]string{}}
+	}
+	for _, doc := range c.List() {
+		id, _ := doc.GetField(c.cfg.PrimaryKey).(string)
+		for field, index := range indexes {
+			value, ok := lookupPath(doc, field)
+			if !ok || value == nil {
+				continue
+			}
+			index.all = append(index.all, id)
+			// References hold primary keys, other values match no document.
+			if key, ok := value.(string); ok {
+				index.byValue[key] = append(index.byValue[key], id)
+			}
+		}
+	}
+	p.referrers[collection] = indexes
+
+	return indexes[field]
+}
+
+// check fails when a restricted reference survives the delete, or when a
+// planned write to a referencing document would be rejected, e.g. a null
+// not allowed by the schema. Writes are serialized by lockReferences, so the
+// documents checked do not change before apply.
+func (p *deletePlan) check() error {
+	for _, ref := range p.restricted {
+		if !p.deleted[ref.documentRef] {
+			return fmt.Errorf("%w: document '%s' of %q references it through %s", ErrReferenceViolation, ref.id, ref.collection, ref.field)
+		}
+	}
+	for _, ref := range p.deletes {
+		if err := p.collections[ref.collection].writable(); err != nil {
+			return err
+		}
+	}
+	for _, ref := range p.nulls {
+		if p.deleted[ref.documentRef] {
+			continue
+		}
+		c := p.collections[ref.collection]
+		if err := c.writable(); err != nil {
+			return err
+		}
+		c.mu.RLock()
+		_, err := c.nulled(ref.id, ref.field)
+		c.mu.RUnlock()
+		if err != nil && !errors.Is(err, ErrDocumentNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply performs planned cascades and set-nulls. Documents removed
+// meanwhile, e.g. expired ones, are skipped.
+func (p *deletePlan) apply(ctx context.Context) error {
+	var errs []error
+	for _, ref := range p.deletes {
+		if err := p.collections[ref.collection].deleteDocument(ctx, ref.id); err != nil && !errors.Is(err, ErrDocumentNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	for _, ref := range p.nulls {
+		if p.deleted[ref.documentRef] {
+			continue
+		}
+		if err := p.collections[ref.collection].setNull(ctx, ref.id, ref.field); err != nil && !errors.Is(err, ErrDocumentNotFound) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// setNull sets a field of a stored document to null.
+func (s *Collection) setNull(ctx context.Context, id string, field string) error {
+	collectionLogger().DebugContext(ctx, "Set reference to null", "key", id, "field", field)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated, err := s.nulled(id, field)
+	if err != nil {
+		return err
+	}
+	_, err = s.write(ctx, id, updated)
+
+	return err
+}
+
+// nulled returns a stored document with field set to null, validated like a
+// write. Must be called with s.mu held.
+func (s *Collection) nulled(id string, field string) (Document, error) {
+	doc, err := s.get(id)
+	if err != nil {
+		return Document{}, err
+	}
+
+	updated, ok := setPath(*doc, field, nil)
+	if !ok {
+		return Document{}, s.docError(id, field, fmt.Errorf("%w: cannot set to null", ErrReferenceViolation))
+	}
+	if err := validateDocument(updated, s.cfg.Schema); err != nil {
+		return Document{}, s.docError(id, "", err)
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,309 @@
+package documentstore
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func bookDocument(id string, author interface{}) Document {
+	doc := Document{
+		Fields: map[string]DocumentField{
+			"id":    {Type: DocumentFieldTypeString, Value: id},
+			"title": {Type: DocumentFieldTypeString, Value: "Book " + id},
+		},
+	}
+	if author != nil {
+		doc.Fields["author"] = DocumentField{Type: DocumentFieldTypeString, Value: author}
+	}
+	return doc
+}
+
+func newLibraryStore(t *testing.T, onDelete ReferenceAction) *Store {
+	store := NewStore()
+	authors, _ := store.CreateCollection("authors", &CollectionConfig{PrimaryKey: "id"})
+	books, err := store.CreateCollection("books", &CollectionConfig{
+		PrimaryKey: "id",
+		References: []ReferenceConfig{{Field: "author", Collection: "authors", OnDelete: onDelete}},
+	})
+	assert.Nil(t, err)
+
+	authors.Put(userDocument("a1", "Taras"))
+	authors.Put(userDocument("a2", "Lesya"))
+	_, err = books.Put(bookDocument("b1", "a1"))
+	assert.Nil(t, err)
+	_, err = books.Put(bookDocument("b2", "a2"))
+	assert.Nil(t, err)
+
+	return store
+}
+
+func TestCollection_References(t *testing.T) {
+	t.Run("Should reject documents referencing missing documents", func(t *testing.T) {
+		store := newLibraryStore(t, "")
+		books, _ := store.GetCollection("books")
+
+		_, err := books.Put(bookDocument("b3", "a3"))
+		assert.ErrorIs(t, err, ErrReferenceViolation)
+		_, err = books.Replace(bookDocument("b1", "a3"))
+		assert.ErrorIs(t, err, ErrReferenceViolation)
+
+		_, err = books.Put(bookDocument("b3", nil))
+		assert.Nil(t, err)
+		doc := bookDocument("b4", nil)
+		doc.Fields["author"] = DocumentField{Type: DocumentFieldTypeNumber, Value: 1}
+		_, err = books.Put(doc)
+		assert.ErrorIs(t, err, ErrReferenceViolation)
+	})
+
+	t.Run("Should reject references to missing collections", func(t *testing.T) {
+		store := NewStore()
+		books, _ := store.CreateCollection("books", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "author", Collection: "authors"}},
+		})
+
+		_, err := books.Put(bookDocument("b1", "a1"))
+		assert.ErrorIs(t, err, ErrReferenceViolation)
+	})
+
+	t.Run("Should reject invalid reference configs", func(t *testing.T) {
+		store := NewStore()
+		_, err := store.CreateCollection("books", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "author", Collection: "authors", OnDelete: "ignore"}},
+		})
+		assert.ErrorIs(t, err, ErrInvalidCollectionConfig)
+
+		_, err = store.CreateCollection("books", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "author"}},
+		})
+		assert.ErrorIs(t, err, ErrInvalidCollectionConfig)
+	})
+
+	t.Run("Should restrict deletes of referenced documents", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceRestrict)
+		authors, _ := store.GetCollection("authors")
+		books, _ := store.GetCollection("books")
+
+		assert.Error(t, authors.Delete("a1"))
+		_, err := authors.Get("a1")
+		assert.Nil(t, err)
+
+		assert.Nil(t, books.Delete("b1"))
+		assert.Nil(t, authors.Delete("a1"))
+	})
+
+	t.Run("Should cascade deletes", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceCascade)
+		authors, _ := store.GetCollection("authors")
+		books, _ := store.GetCollection("books")
+
+		assert.Nil(t, authors.Delete("a1"))
+		_, err := books.Get("b1")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+		_, err = books.Get("b2")
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should cascade through several collections", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceCascade)
+		reviews, _ := store.CreateCollection("reviews", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "book", Collection: "books", OnDelete: ReferenceCascade}},
+		})
+		review := userDocument("r1", "Great")
+		review.Fields["book"] = DocumentField{Type: DocumentFieldTypeString, Value: "b1"}
+		_, err := reviews.Put(review)
+		assert.Nil(t, err)
+
+		authors, _ := store.GetCollection("authors")
+		assert.Nil(t, authors.Delete("a1"))
+		assert.Empty(t, reviews.List())
+	})
+
+	t.Run("Should reject cascades reaching restricted references", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceCascade)
+		reviews, _ := store.CreateCollection("reviews", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "book", Collection: "books"}},
+		})
+		review := userDocument("r1", "Great")
+		review.Fields["book"] = DocumentField{Type: DocumentFieldTypeString, Value: "b1"}
+		reviews.Put(review)
+
+		authors, _ := store.GetCollection("authors")
+		books, _ := store.GetCollection("books")
+		assert.Error(t, authors.Delete("a1"))
+		_, err := authors.Get("a1")
+		assert.Nil(t, err)
+		_, err = books.Get("b1")
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should set references to null", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceSetNull)
+		authors, _ := store.GetCollection("authors")
+		books, _ := store.GetCollection("books")
+
+		assert.Nil(t, authors.Delete("a1"))
+		book, err := books.Get("b1")
+		assert.Nil(t, err)
+		assert.Equal(t, DocumentField{Type: DocumentFieldTypeNull}, book.Fields["author"])
+		assert.Equal(t, "Book b1", book.GetField("title"))
+	})
+
+	t.Run("Should not delete when references cannot be set to null", func(t *testing.T) {
+		store := NewStore()
+		authors, _ := store.CreateCollection("authors", &CollectionConfig{PrimaryKey: "id"})
+		books, err := store.CreateCollection("books", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "author", Collection: "authors", OnDelete: ReferenceSetNull}},
+			Schema: &Schema{Fields: map[string]FieldSchema{
+				"author": {Types: []DocumentFieldType{DocumentFieldTypeString}},
+			}},
+		})
+		assert.Nil(t, err)
+		authors.Put(userDocument("a1", "Taras"))
+		books.Put(bookDocument("b1", "a1"))
+
+		err = authors.Delete("a1")
+		assert.ErrorIs(t, err, ErrValidationFailed)
+		assert.ErrorIs(t, err, &Error{Collection: "books", ID: "b1"})
+		assert.ErrorIs(t, store.DeleteCollection("authors"), ErrValidationFailed)
+
+		_, err = authors.Get("a1")
+		assert.Nil(t, err)
+		book, _ := books.Get("b1")
+		assert.Equal(t, "a1", book.GetField("author"))
+	})
+
+	t.Run("Should apply delete actions when a collection is deleted", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceRestrict)
+		assert.Error(t, store.DeleteCollection("authors"))
+		_, ok := store.GetCollection("authors")
+		assert.True(t, ok)
+
+		store = newLibraryStore(t, ReferenceCascade)
+		assert.Nil(t, store.DeleteCollection("authors"))
+		books, _ := store.GetCollection("books")
+		assert.Empty(t, books.List())
+
+		store = newLibraryStore(t, ReferenceSetNull)
+		assert.Nil(t, store.DeleteCollection("authors"))
+		books, _ = store.GetCollection("books")
+		assert.Len(t, books.List(), 2)
+		for _, book := range books.List() {
+			assert.Nil(t, book.GetField("author"))
+		}
+	})
+
+	t.Run("Should keep a collection when referencing documents fail to update", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceCascade)
+		books, _ := store.GetCollection("books")
+		documents := &failingTable[Document]{table: books.documents, err: errors.New("disk is full")}
+		books.documents = documents
+
+		assert.ErrorIs(t, store.DeleteCollection("authors"), documents.err)
+		_, ok := store.GetCollection("authors")
+		assert.True(t, ok)
+
+		documents.err = nil
+		assert.Nil(t, store.DeleteCollection("authors"))
+		_, ok = store.GetCollection("authors")
+		assert.False(t, ok)
+		assert.Empty(t, books.List())
+	})
+
+	t.Run("Should allow self references", func(t *testing.T) {
+		store := NewStore()
+		employees, _ := store.CreateCollection("employees", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "manager", Collection: "employees", OnDelete: ReferenceCascade}},
+		})
+		employees.Put(userDocument("1", "Boss"))
+		report := userDocument("2", "Report")
+		report.Fields["manager"] = DocumentField{Type: DocumentFieldTypeString, Value: "1"}
+		_, err := employees.Put(report)
+		assert.Nil(t, err)
+
+		assert.Nil(t, employees.Delete("1"))
+		assert.Empty(t, employees.List())
+		assert.Nil(t, store.DeleteCollection("employees"))
+	})
+
+	t.Run("Should read referencing collections once per delete", func(t *testing.T) {
+		store := NewStore()
+		employees, _ := store.CreateCollection("employees", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "manager", Collection: "employees", OnDelete: ReferenceCascade}},
+		})
+		employees.Put(userDocument("0", "Boss"))
+		for i := 1; i < 20; i++ {
+			report := userDocument(strconv.Itoa(i), "Report")
+			report.Fields["manager"] = newField(strconv.Itoa(i - 1))
+			_, err := employees.Put(report)
+			assert.Nil(t, err)
+		}
+		metrics := &recordingMetrics{}
+		useMetrics(t, metrics)
+
+		assert.Nil(t, employees.Delete("0"))
+		assert.Empty(t, employees.List())
+		lists := 0
+		for _, o := range metrics.observations {
+			if o.op == OpList {
+				lists++
+			}
+		}
+		assert.Equal(t, 2, lists)
+	})
+
+	t.Run("Should not enforce references outside of a store", func(t *testing.T) {
+		books := NewCollection(&CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "author", Collection: "authors"}},
+		})
+
+		_, err := books.Put(bookDocument("b1", "a1"))
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should keep references in dumps", func(t *testing.T) {
+		store := newLibraryStore(t, ReferenceCascade)
+		dump, err := store.Dump()
+		assert.Nil(t, err)
+
+		restored, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+		assert.Equal(t, store, restored)
+
+		authors, _ := restored.GetCollection("authors")
+		books, _ := restored.GetCollection("books")
+		assert.Nil(t, authors.Delete("a1"))
+		assert.Len(t, books.List(), 1)
+	})
+
+	t.Run("Should enforce references in persistent stores", func(t *testing.T) {
+		filename := t.TempDir() + "/store.db"
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		store.CreateCollection("authors", &CollectionConfig{PrimaryKey: "id"})
+		books, _ := store.CreateCollection("books", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "author", Collection: "authors"}},
+		})
+		_, err = books.Put(bookDocument("b1", "a1"))
+		assert.ErrorIs(t, err, ErrReferenceViolation)
+		assert.Nil(t, store.Close())
+
+		store, err = OpenStore(filename)
+		assert.Nil(t, err)
+		defer store.Close()
+		books, _ = store.GetCollection("books")
+		_, err = books.Put(bookDocument("b1", "a1"))
+		assert.ErrorIs(t, err, ErrReferenceViolation)
+	})
+}
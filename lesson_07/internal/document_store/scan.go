@@ -0,0 +1,85 @@
+package documentstore
+
+import (
+	"context"
+	"iter"
+)
+
+const defaultScanBatchSize = 100
+
+// ScanOptions tune Collection.Scan.
+type ScanOptions struct {
+	// From is the primary key to start at, inclusive.
+	From string
+	// BatchSize is the number of documents read at once, 100 by default.
+	BatchSize int
+}
+
+// Scan returns an iterator over documents in primary key order. Unlike List
+// it does not copy the whole collection: documents are read in batches
+// under the collection lock, which is released while the caller handles
+// them, so a slow consumer does not block writers. Every document is
+// yielded at most once; documents written during the scan are seen if their
+// keys were not passed yet. When ctx is done the iterator yields ctx.Err()
+// and stops, as it does on a storage error.
+func (s *Collection) Scan(ctx context.Context, opts ScanOptions) iter.Seq2[Document, error] {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultScanBatchSize
+	}
+
+	return func(yield func(Document, error) bool) {
+		collectionLogger().Debug("Scan documents", "opts", opts)
+		from := opts.From
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(Document{}, err)
+				return
+			}
+
+			docs, last, more, err := s.scanBatch(from, batchSize)
+			if err != nil {
+				yield(Document{}, err)
+				return
+			}
+			for _, doc := range docs {
+				if err := ctx.Err(); err != nil {
+					yield(Document{}, err)
+					return
+				}
+				if !yield(doc, nil) {
+					return
+				}
+			}
+			if !more {
+				return
+			}
+			from = last + "\x00"
+		}
+	}
+}
+
+// scanBatch reads up to n visible documents starting at key from. It
+// returns the last key read and whether there may be more.
+func (s *Collection) scanBatch(from string, n int) ([]Document, string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := make([]Document, 0, n)
+	var last string
+	more := false
+	at := now()
+	err := s.documents.scan(from, func(key string, doc Document) bool {
+		if len(docs) == n {
+			more = true
+			return false
+		}
+		last = key
+		if !doc.expired(at) {
+			docs = append(docs, doc)
+		}
+		return true
+	})
+
+	return docs, last, more, err
+}
@@ -0,0 +1,158 @@
+package documentstore
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func scanIDs(t *testing.T, collection *Collection, ctx context.Context, opts ScanOptions) []string {
+	var ids []string
+	for doc, err := range collection.Scan(ctx, opts) {
+		assert.Nil(t, err)
+		ids = append(ids, doc.GetField("id").(string))
+	}
+	return ids
+}
+
+func TestCollection_Scan(t *testing.T) {
+	collections := map[string]func(t *testing.T) *Collection{
+		"memory": func(t *testing.T) *Collection {
+			return NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		},
+		"file": func(t *testing.T) *Collection {
+			store, err := OpenStore(filepath.Join(t.TempDir(), "store.db"))
+			assert.Nil(t, err)
+			t.Cleanup(func() { store.Close() })
+			collection, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+			return collection
+		},
+		"lsm": func(t *testing.T) *Collection {
+			collection, err := OpenCollection(&CollectionConfig{
+				PrimaryKey: "id",
+				Engine:     CollectionEngineLSM,
+				LSM:        &LSMConfig{Dir: t.TempDir()},
+			})
+			assert.Nil(t, err)
+			t.Cleanup(func() { collection.Close() })
+			return collection
+		},
+	}
+
+	for name, newCollection := range collections {
+		t.Run(name+": should stream documents in key order", func(t *testing.T) {
+			collection := newCollection(t)
+			for _, id := range []string{"c", "a", "d", "b", "e"} {
+				collection.Put(userDocument(id, "User"))
+			}
+
+			assert.Equal(t, []string{"a", "b", "c", "d", "e"}, scanIDs(t, collection, context.Background(), ScanOptions{BatchSize: 2}))
+			assert.Equal(t, []string{"c", "d", "e"}, scanIDs(t, collection, context.Background(), ScanOptions{From: "c"}))
+			assert.Equal(t, []string{"c", "d", "e"}, scanIDs(t, collection, context.Background(), ScanOptions{From: "bb", BatchSize: 1}))
+			assert.Empty(t, scanIDs(t, collection, context.Background(), ScanOptions{From: "f"}))
+		})
+
+		t.Run(name+": should stop early", func(t *testing.T) {
+			collection := newCollection(t)
+			for i := range 10 {
+				collection.Put(userDocument(fmt.Sprintf("%02d", i), "User"))
+			}
+
+			var ids []string
+			for doc := range collection.Scan(context.Background(), ScanOptions{BatchSize: 3}) {
+				ids = append(ids, doc.GetField("id").(string))
+				if len(ids) == 4 {
+					break
+				}
+			}
+			assert.Equal(t, []string{"00", "01", "02", "03"}, ids)
+		})
+	}
+
+	t.Run("Should stop when the context is done", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		for i := range 10 {
+			collection.Put(userDocument(fmt.Sprintf("%02d", i), "User"))
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var ids []string
+		var scanErr error
+		for doc, err := range collection.Scan(ctx, ScanOptions{BatchSize: 4}) {
+			if err != nil {
+				scanErr = err
+				continue
+			}
+			ids = append(ids, doc.GetField("id").(string))
+			if len(ids) == 2 {
+				cancel()
+			}
+		}
+		assert.ErrorIs(t, scanErr, context.Canceled)
+		assert.Equal(t, []string{"00", "01"}, ids)
+	})
+
+	t.Run("Should skip expired documents", func(t *testing.T) {
+		start, _ := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		collection.Put(userDocument("a", "User"))
+		collection.Put(expiringDocument("b", start.Add(-time.Minute)))
+		collection.Put(expiringDocument("c", start.Add(-time.Minute)))
+		collection.Put(userDocument("d", "User"))
+
+		assert.Equal(t, []string{"a", "d"}, scanIDs(t, collection, context.Background(), ScanOptions{BatchSize: 1}))
+	})
+
+	t.Run("Should see writes to keys not passed yet", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		for _, id := range []string{"a", "c", "e"} {
+			collection.Put(userDocument(id, "User"))
+		}
+
+		var ids []string
+		for doc := range collection.Scan(context.Background(), ScanOptions{BatchSize: 1}) {
+			id := doc.GetField("id").(string)
+			ids = append(ids, id)
+			if id == "a" {
+				collection.Put(userDocument("0", "Passed"))
+				collection.Put(userDocument("b", "Ahead"))
+				collection.Delete("c")
+			}
+		}
+		assert.Equal(t, []string{"a", "b", "e"}, ids)
+	})
+
+	t.Run("Should be safe under concurrent writes", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		for i := range 200 {
+			collection.Put(userDocument(fmt.Sprintf("%03d", i), "User"))
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				id := fmt.Sprintf("%03d", i)
+				collection.Delete(id)
+				collection.Put(userDocument(id, "Rewritten"))
+			}
+		}()
+
+		for range 5 {
+			var last string
+			for doc, err := range collection.Scan(context.Background(), ScanOptions{BatchSize: 7}) {
+				assert.Nil(t, err)
+				id := doc.GetField("id").(string)
+				assert.Greater(t, id, last)
+				last = id
+			}
+		}
+		wg.Wait()
+	})
+}
@@ -0,0 +1,204 @@
+package documentstore
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// Schema describes the fields documents of a collection must have, see
+// CollectionConfig.Schema. Fields it does not describe are allowed.
+type Schema struct {
+	Fields map[string]FieldSchema `json:"fields"`
+}
+
+// FieldSchema describes a field, a field of an object or an element of an
+// array.
+type FieldSchema struct {
+	// Types are the types the field may have; any type when empty. A field
+	// that may be null lists DocumentFieldTypeNull.
+	Types    []DocumentFieldType `json:"types,omitempty"`
+	Required bool                `json:"required,omitempty"`
+	// Fields describes the fields of object values.
+	Fields map[string]FieldSchema `json:"fields,omitempty"`
+	// Items describes the elements of array values.
+	Items *FieldSchema `json:"items,omitempty"`
+
+	// Enum lists the values the field may have.
+	Enum []interface{} `json:"enum,omitempty"`
+	// Minimum, Maximum and Integer constrain numbers and decimals.
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+	Integer bool     `json:"integer,omitempty"`
+	// Pattern is a regular expression strings must match, in the syntax of
+	// the regexp package; it is not anchored.
+	Pattern string `json:"pattern,omitempty"`
+	// MinLength and MaxLength constrain the length of strings in characters.
+	MinLength *int `json:"minLength,omitempty"`
+	MaxLength *int `json:"maxLength,omitempty"`
+}
+
+var fieldTypes = []DocumentFieldType{
+	DocumentFieldTypeString,
+	DocumentFieldTypeNumber,
+	DocumentFieldTypeBool,
+	DocumentFieldTypeArray,
+	DocumentFieldTypeObject,
+	DocumentFieldTypeGeoPoint,
+	DocumentFieldTypeDateTime,
+	DocumentFieldTypeNull,
+	DocumentFieldTypeBinary,
+	DocumentFieldTypeDecimal,
+}
+
+func validateSchema(schema *Schema) error {
+	if schema == nil {
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(schema.Fields)) {
+		if err := validateFieldSchema(name, schema.Fields[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateFieldSchema(path string, field FieldSchema) error {
+	for _, t := range field.Types {
+		if !slices.Contains(fieldTypes, t) {
+			return fmt.Errorf("%w: unknown type %q of field %q", ErrInvalidCollectionConfig, t, path)
+		}
+	}
+	if field.Pattern != "" {
+		if _, err := compilePattern(field.Pattern); err != nil {
+			return fmt.Errorf("%w: invalid pattern of field %q: %w", ErrInvalidCollectionConfig, path, err)
+		}
+	}
+	if (field.MinLength != nil && *field.MinLength < 0) || (field.MaxLength != nil && *field.MaxLength < 0) {
+		return fmt.Errorf("%w: negative length of field %q", ErrInvalidCollectionConfig, path)
+	}
+	for _, name := range slices.Sorted(maps.Keys(field.Fields)) {
+		if err := validateFieldSchema(path+"."+name, field.Fields[name]); err != nil {
+			return err
+		}
+	}
+	if field.Items != nil {
+		return validateFieldSchema(path+".*", *field.Items)
+	}
+
+	return nil
+}
+
+// validate returns an error per field of doc not matching the schema.
+func (schema *Schema) validate(doc Document) []error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(schema.Fields)) {
+		field := schema.Fields[name]
+		value, ok := doc.Fields[name]
+		if !ok {
+			if field.Required {
+				errs = append(errs, newError("", "", name, fmt.Errorf("%w: required field is missing", ErrValidationFailed)))
+			}
+			continue
+		}
+		errs = append(errs, field.validate(name, value.Type, value.Value)...)
+	}
+
+	return errs
+}
+
+func (field FieldSchema) validate(path string, t DocumentFieldType, value interface{}) []error {
+	if len(field.Types) > 0 && !slices.Contains(field.Types, t) {
+		return []error{newError("", "", path, fmt.Errorf("%w: type %s is not allowed by the schema, expected one of %v", ErrValidationFailed, t, field.Types))}
+	}
+
+	errs := field.validateValue(path, t, value)
+	switch container := normalizeContainer(value).(type) {
+	case map[string]interface{}:
+		for _, name := range slices.Sorted(maps.Keys(field.Fields)) {
+			child := field.Fields[name]
+			item, ok := container[name]
+			if !ok {
+				if child.Required {
+					errs = append(errs, newError("", "", path+"."+name, fmt.Errorf("%w: required field is missing", ErrValidationFailed)))
+				}
+				continue
+			}
+			errs = append(errs, child.validate(path+"."+name, GetValueType(item), item)...)
+		}
+	case []interface{}:
+		if field.Items == nil {
+			break
+		}
+		for i, item := range container {
+			errs = append(errs, field.Items.validate(path+"."+strconv.Itoa(i), GetValueType(item), item)...)
+		}
+	}
+
+	return errs
+}
+
+// validateValue checks the constraints of the field other than its type and
+// nested fields.
+func (field FieldSchema) validateValue(path string, t DocumentFieldType, value interface{}) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, newError("", "", path, fmt.Errorf("%w: "+format, append([]interface{}{ErrValidationFailed}, args...)...)))
+	}
+
+	if len(field.Enum) > 0 && !slices.ContainsFunc(field.Enum, func(v interface{}) bool { return valuesEqual(value, v) }) {
+		fail("value %v is not one of %v", value, field.Enum)
+	}
+
+	switch t {
+	case DocumentFieldTypeNumber, DocumentFieldTypeDecimal:
+		if field.Minimum != nil && compareValues(value, *field.Minimum) < 0 {
+			fail("%v is less than the minimum %v", value, *field.Minimum)
+		}
+		if field.Maximum != nil && compareValues(value, *field.Maximum) > 0 {
+			fail("%v is greater than the maximum %v", value, *field.Maximum)
+		}
+		if r, ok := toRat(value); field.Integer && (!ok || !r.IsInt()) {
+			fail("%v is not an integer", value)
+		}
+	case DocumentFieldTypeString:
+		s, _ := value.(string)
+		length := utf8.RuneCountInString(s)
+		if field.MinLength != nil && length < *field.MinLength {
+			fail("length %d is less than the minimum %d", length, *field.MinLength)
+		}
+		if field.MaxLength != nil && length > *field.MaxLength {
+			fail("length %d is greater than the maximum %d", length, *field.MaxLength)
+		}
+		if field.Pattern != "" {
+			if re, err := compilePattern(field.Pattern); err != nil || !re.MatchString(s) {
+				fail("%q does not match the pattern %q", s, field.Pattern)
+			}
+		}
+	}
+
+	return errs
+}
+
+// patterns caches compiled patterns of schemas by their source.
+var patterns sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+
+	return re, nil
+}
@@ -0,0 +1,121 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func profileSchema() *Schema {
+	return &Schema{Fields: map[string]FieldSchema{
+		"id":   {Types: []DocumentFieldType{DocumentFieldTypeString}, Required: true},
+		"name": {Types: []DocumentFieldType{DocumentFieldTypeString, DocumentFieldTypeNull}, Required: true},
+		"address": {Types: []DocumentFieldType{DocumentFieldTypeObject}, Fields: map[string]FieldSchema{
+			"city": {Types: []DocumentFieldType{DocumentFieldTypeString}, Required: true},
+		}},
+		"scores": {Items: &FieldSchema{Types: []DocumentFieldType{DocumentFieldTypeNumber}}},
+	}}
+}
+
+func TestCollection_Schema(t *testing.T) {
+	t.Run("Should write documents matching the schema", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", Schema: profileSchema()})
+
+		doc := userDocument("1", "John")
+		doc.Fields["address"] = newField(map[string]interface{}{"city": "Kyiv", "zip": 1001})
+		doc.Fields["scores"] = newField([]interface{}{1, 2.5})
+		doc.Fields["extra"] = newField(true)
+		_, err := collection.Put(doc)
+		assert.Nil(t, err)
+
+		doc = userDocument("2", "")
+		doc.Fields["name"] = newField(nil)
+		_, err = collection.Put(doc)
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should reject documents not matching the schema", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", Schema: profileSchema()})
+
+		doc := userDocument("1", "John")
+		delete(doc.Fields, "name")
+		doc.Fields["address"] = newField(map[string]interface{}{"zip": 1001})
+		doc.Fields["scores"] = newField([]interface{}{1, "two"})
+		_, err := collection.Put(doc)
+		assert.ErrorIs(t, err, ErrValidationFailed)
+		assert.ErrorIs(t, err, &Error{Field: "name"})
+		assert.ErrorIs(t, err, &Error{Field: "address.city"})
+		assert.ErrorIs(t, err, &Error{Field: "scores.1"})
+		assert.NotErrorIs(t, err, &Error{Field: "scores.0"})
+
+		doc = userDocument("1", "John")
+		doc.Fields["address"] = newField("Kyiv")
+		_, err = collection.Put(doc)
+		assert.ErrorIs(t, err, &Error{Field: "address", Code: CodeInvalidArgument})
+	})
+
+	t.Run("Should enforce constraints of values", func(t *testing.T) {
+		minimum, maximum, minLength, maxLength := 0.0, 150.0, 2, 5
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", Schema: &Schema{Fields: map[string]FieldSchema{
+			"age":    {Minimum: &minimum, Maximum: &maximum, Integer: true},
+			"name":   {MinLength: &minLength, MaxLength: &maxLength, Pattern: "^[A-ZА-Я]"},
+			"role":   {Enum: []interface{}{"admin", "user", float64(1)}},
+			"prices": {Items: &FieldSchema{Minimum: &minimum}},
+		}}})
+
+		doc := userDocument("1", "Юлія")
+		doc.Fields["age"] = newField(42)
+		doc.Fields["role"] = newField(1)
+		doc.Fields["prices"] = newField([]interface{}{Decimal("0.50"), 3})
+		_, err := collection.Put(doc)
+		assert.Nil(t, err)
+
+		for field, value := range map[string]interface{}{
+			"age":    42.5,
+			"name":   "john",
+			"role":   "guest",
+			"prices": []interface{}{Decimal("-0.01")},
+		} {
+			doc := userDocument(field, "John")
+			doc.Fields[field] = newField(value)
+			_, err := collection.Put(doc)
+			assert.ErrorIs(t, err, ErrValidationFailed, field)
+		}
+
+		doc = userDocument("2", "Johnny")
+		doc.Fields["age"] = newField(151)
+		_, err = collection.Put(doc)
+		assert.ErrorContains(t, err, "field name: validation failed: length 6 is greater than the maximum 5")
+		assert.ErrorContains(t, err, "field age: validation failed: 151 is greater than the maximum 150")
+	})
+
+	t.Run("Should reject invalid constraints", func(t *testing.T) {
+		store := NewStore()
+		_, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Schema: &Schema{Fields: map[string]FieldSchema{
+			"name": {Pattern: "(unclosed"},
+		}}})
+		assert.ErrorIs(t, err, ErrInvalidCollectionConfig)
+	})
+
+	t.Run("Should reject unknown types", func(t *testing.T) {
+		store := NewStore()
+		_, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Schema: &Schema{Fields: map[string]FieldSchema{
+			"tags": {Items: &FieldSchema{Types: []DocumentFieldType{"text"}}},
+		}}})
+		assert.ErrorIs(t, err, ErrInvalidCollectionConfig)
+		assert.ErrorContains(t, err, `"tags.*"`)
+	})
+
+	t.Run("Should keep the schema in dumps", func(t *testing.T) {
+		store := NewStore()
+		_, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Schema: profileSchema()})
+		assert.Nil(t, err)
+		dump, err := store.Dump()
+		assert.Nil(t, err)
+
+		loaded, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+		users, _ := loaded.GetCollection("users")
+		_, err = users.Put(Document{Fields: map[string]DocumentField{"id": newField("1")}})
+		assert.ErrorIs(t, err, &Error{Field: "name"})
+	})
+}
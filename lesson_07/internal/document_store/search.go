@@ -0,0 +1,368 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var ErrNoTextIndex = errors.New("collection has no text index")
+
+// TextIndexConfig enables full-text search over string fields, or arrays of
+// strings, of a collection. The index is kept in memory and rebuilt when the
+// collection is opened or loaded from a dump.
+type TextIndexConfig struct {
+	Fields []string `json:"fields"`
+	// StopWords replaces the default English and Ukrainian stop words.
+	StopWords []string `json:"stopWords,omitempty"`
+	// DisableStopWords indexes every word.
+	DisableStopWords bool `json:"disableStopWords,omitempty"`
+}
+
+// SearchOptions tune Collection.Search.
+type SearchOptions struct {
+	// Prefix matches every indexed word starting with a query word.
+	Prefix bool
+	// MatchAll requires every query word to match; by default any does.
+	MatchAll bool
+	// Limit caps the number of results; zero means no limit.
+	Limit int
+}
+
+// SearchResult is a document found by Collection.Search with its BM25 score.
+type SearchResult struct {
+	Document Document
+	Score    float64
+}
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+var defaultStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "by", "for", "from", "in", "is", "it",
+	"of", "on", "or", "that", "the", "to", "was", "with",
+	"а", "але", "в", "від", "до", "з", "за", "і", "й", "на", "не", "по", "про", "та", "у", "це", "що", "як",
+}
+
+// textIndex is an inverted index of the words of a collection.
+type textIndex struct {
+	fields    []string
+	stopWords map[string]bool
+	// postings maps a word to frequencies in documents keyed by ID.
+	postings map[string]map[string]int
+	// sorted are the keys of postings in order, for prefix matching.
+	sorted      []string
+	lengths     map[string]int
+	totalLength int
+}
+
+func newTextIndex(cfg *TextIndexConfig) *textIndex {
+	stopWords := cfg.StopWords
+	if len(stopWords) == 0 {
+		stopWords = defaultStopWords
+	}
+	if cfg.DisableStopWords {
+		stopWords = nil
+	}
+
+	index := &textIndex{
+		fields:    cfg.Fields,
+		stopWords: map[string]bool{},
+		postings:  map[string]map[string]int{},
+		lengths:   map[string]int{},
+	}
+	for _, word := range stopWords {
+		for _, token := range tokenize(word) {
+			index.stopWords[token] = true
+		}
+	}
+
+	return index
+}
+
+func (idx *textIndex) add(id string, doc Document) {
+	words := idx.documentWords(doc)
+	if len(words) == 0 {
+		return
+	}
+
+	for _, word := range words {
+		docs, ok := idx.postings[word]
+		if !ok {
+			docs = map[string]int{}
+			idx.postings[word] = docs
+			i, _ := slices.BinarySearch(idx.sorted, word)
+			idx.sorted = slices.Insert(idx.sorted, i, word)
+		}
+		docs[id]++
+	}
+	idx.lengths[id] = len(words)
+	idx.totalLength += len(words)
+}
+
+func (idx *textIndex) remove(id string, doc Document) {
+	if _, ok := idx.lengths[id]; !ok {
+		return
+	}
+
+	for _, word := range idx.documentWords(doc) {
+		docs := idx.postings[word]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, word)
+			if i, found := slices.BinarySearch(idx.sorted, word); found {
+				idx.sorted = slices.Delete(idx.sorted, i, i+1)
+			}
+		}
+	}
+	idx.totalLength -= idx.lengths[id]
+	delete(idx.lengths, id)
+}
+
+func (idx *textIndex) documentWords(doc Document) []string {
+	var words []string
+	for _, field := range idx.fields {
+		value, ok := lookupPath(doc, field)
+		if !ok {
+			continue
+		}
+		var texts []interface{}
+		if values, isArray := normalizeContainer(value).([]interface{}); isArray {
+			texts = values
+		} else {
+			texts = []interface{}{value}
+		}
+		for _, text := range texts {
+			if s, isString := text.(string); isString {
+				words = append(words, idx.words(s)...)
+			}
+		}
+	}
+
+	return words
+}
+
+// words returns the indexed words of a text.
+func (idx *textIndex) words(text string) []string {
+	tokens := tokenize(text)
+	words := tokens[:0]
+	for _, token := range tokens {
+		if !idx.stopWords[token] {
+			words = append(words, token)
+		}
+	}
+
+	return words
+}
+
+// matches returns the indexed words a query word matches.
+func (idx *textIndex) matches(word string, prefix bool) []string {
+	if !prefix {
+		if _, ok := idx.postings[word]; ok {
+			return []string{word}
+		}
+		return nil
+	}
+
+	i, _ := slices.BinarySearch(idx.sorted, word)
+	end := i
+	for end < len(idx.sorted) && strings.HasPrefix(idx.sorted[end], word) {
+		end++
+	}
+
+	return idx.sorted[i:end]
+}
+
+// scores ranks documents with BM25. A query word expanded to several words
+// by prefix counts with its best matching word.
+func (idx *textIndex) scores(query []string, opts SearchOptions) map[string]float64 {
+	n := float64(len(idx.lengths))
+	if n == 0 {
+		return nil
+	}
+	avgLength := float64(idx.totalLength) / n
+
+	scores := map[string]float64{}
+	matched := map[string]int{}
+	for _, queryWord := range query {
+		best := map[string]float64{}
+		for _, word := range idx.matches(queryWord, opts.Prefix) {
+			docs := idx.postings[word]
+			idf := math.Log(1 + (n-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+			for id, freq := range docs {
+				tf := float64(freq)
+				norm := bm25K1 * (1 - bm25B + bm25B*float64(idx.lengths[id])/avgLength)
+				best[id] = max(best[id], idf*tf*(bm25K1+1)/(tf+norm))
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+			matched[id]++
+		}
+	}
+
+	if opts.MatchAll {
+		for id := range scores {
+			if matched[id] < len(query) {
+				delete(scores, id)
+			}
+		}
+	}
+
+	return scores
+}
+
+// tokenize splits text into lowercase words of letters and digits.
+// Apostrophes inside words are dropped, so "п'ять" and "пʼять" are the same
+// word.
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+
+	for _, r := range normalizeText(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		case isApostrophe(r) && len(word) > 0:
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’' || r == 'ʼ' || r == '`'
+}
+
+// composed holds letters that have a meaning of their own and must not lose
+// their combining mark.
+var composed = map[[2]rune]bool{
+	{'и', '\u0306'}: true, // й
+	{'у', '\u0306'}: true, // ў
+	{'і', '\u0308'}: true, // ї
+}
+
+// folded maps letters without a decomposition to the form they are indexed
+// with.
+var folded = map[rune]rune{
+	'ø': 'o',
+	'ł': 'l',
+	'đ': 'd',
+	'ħ': 'h',
+}
+
+// normalizeText lowercases text and drops combining marks, except for the
+// Cyrillic letters in composed, so that precomposed and decomposed forms of
+// a letter are the same. Compatibility forms are replaced as well, e.g. "ﬁ"
+// with "fi".
+func normalizeText(text string) []rune {
+	runes := []rune(norm.NFKD.String(strings.ToLower(text)))
+	normalized := make([]rune, 0, len(runes))
+	for _, r := range runes {
+		if unicode.Is(unicode.Mn, r) {
+			if n := len(normalized); n > 0 && composed[[2]rune{normalized[n-1], r}] {
+				normalized = append(normalized, r)
+			}
+			continue
+		}
+		if f, ok := folded[r]; ok {
+			r = f
+		}
+		normalized = append(normalized, r)
+	}
+
+	return []rune(norm.NFC.String(string(normalized)))
+}
+
+// buildTextIndex indexes every stored document. Must be called with s.mu
+// held, or before the collection is shared.
+func (s *Collection) buildTextIndex() error {
+	if s.cfg.TextIndex == nil {
+		s.textIndex = nil
+		return nil
+	}
+
+	s.textIndex = newTextIndex(s.cfg.TextIndex)
+	return s.documents.scan("", func(id string, doc Document) bool {
+		s.textIndex.add(id, doc)
+		return true
+	})
+}
+
+// Search finds documents whose indexed fields contain words of text, ranked
+// by relevance. Stop words in text are ignored. Collections of a Snapshot
+// have no index and return ErrNoTextIndex.
+func (s *Collection) Search(text string, opts SearchOptions) (_ []SearchResult, err error) {
+	collectionLogger().Debug("Search documents", "text", text, "opts", opts)
+	ctx, o := s.startOperation(context.Background(), OpSearch)
+	defer o.end(&err)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.textIndex == nil {
+		return nil, s.docError("", "", ErrNoTextIndex)
+	}
+
+	query := slices.Compact(slices.Sorted(slices.Values(s.textIndex.words(text))))
+	if len(query) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	span := startIndexSpan(ctx, "text")
+	scores := s.textIndex.scores(query, opts)
+	span.End()
+	o.scanned(len(scores))
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		doc, err := s.get(id)
+		if errors.Is(err, ErrDocumentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{Document: *doc, Score: score})
+	}
+
+	pk := s.cfg.PrimaryKey
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Document.GetField(pk).(string), b.Document.GetField(pk).(string))
+	})
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	o.returned(len(results))
+
+	return results, nil
+}
+
+func validateTextIndexConfig(cfg *TextIndexConfig) error {
+	if cfg != nil && len(cfg.Fields) == 0 {
+		return fmt.Errorf("%w: text index requires fields", ErrInvalidCollectionConfig)
+	}
+
+	return nil
+}
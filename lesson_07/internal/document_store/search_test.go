@@ -0,0 +1,164 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func articleDocument(id string, title string, tags ...interface{}) Document {
+	return Document{
+		Fields: map[string]DocumentField{
+			"id":    {Type: DocumentFieldTypeString, Value: id},
+			"title": {Type: DocumentFieldTypeString, Value: title},
+			"tags":  {Type: DocumentFieldTypeArray, Value: tags},
+		},
+	}
+}
+
+func searchIDs(results []SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Document.GetField("id").(string)
+	}
+
+	return ids
+}
+
+func TestTokenize(t *testing.T) {
+	t.Run("Should lowercase and split words", func(t *testing.T) {
+		assert.Equal(t, []string{"hello", "world", "42"}, tokenize("Hello, WORLD! 42"))
+	})
+
+	t.Run("Should normalize Cyrillic text", func(t *testing.T) {
+		assert.Equal(t, []string{"київ", "пять", "еж", "йод"}, tokenize("Київ п’ять Ёж йод"))
+	})
+
+	t.Run("Should fold accented letters", func(t *testing.T) {
+		assert.Equal(t, tokenize("cafe"), tokenize("Café"))
+		assert.Equal(t, tokenize("cafe"), tokenize("café"))
+	})
+
+	t.Run("Should match precomposed and decomposed letters", func(t *testing.T) {
+		tests := map[string]string{
+			"Świat":      "S\u0301wiat",
+			"őszi":       "o\u030bszi",
+			"Łódź":       "Ło\u0301dz\u0301",
+			"STRAẞE":     "straße",
+			"Ǆem":        "dz\u030cem",
+			"ﬁlm":        "film",
+			"Київ й ўсё": "Киі\u0308в и\u0306 у\u0306се\u0308",
+			"Ţară şi ță": "T\u0327ara\u0306 s\u0327i t\u0326a\u0306",
+		}
+		for precomposed, decomposed := range tests {
+			assert.Equal(t, tokenize(precomposed), tokenize(decomposed), precomposed)
+		}
+		assert.Equal(t, []string{"swiat", "lodz", "київ", "й", "ўсе", "ro", "dakovo"}, tokenize("Świat Łódź Київ й ўсё Rø Đakovo"))
+	})
+}
+
+func TestCollection_Search(t *testing.T) {
+	newArticles := func() *Collection {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", TextIndex: &TextIndexConfig{Fields: []string{"title", "tags"}}})
+		collection.Put(articleDocument("1", "The quick brown fox", "animals"))
+		collection.Put(articleDocument("2", "A quick guide to Go", "programming", "go"))
+		collection.Put(articleDocument("3", "Київ — столиця України", "міста"))
+		collection.Put(articleDocument("4", "Fox, fox and more fox", "animals"))
+		return collection
+	}
+
+	t.Run("Should rank by relevance", func(t *testing.T) {
+		results, err := newArticles().Search("fox", SearchOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"4", "1"}, searchIDs(results))
+		assert.Greater(t, results[0].Score, results[1].Score)
+	})
+
+	t.Run("Should match any or all words", func(t *testing.T) {
+		collection := newArticles()
+
+		results, _ := collection.Search("quick fox", SearchOptions{})
+		assert.ElementsMatch(t, []string{"1", "2", "4"}, searchIDs(results))
+		assert.Equal(t, "1", searchIDs(results)[0])
+
+		results, _ = collection.Search("quick fox", SearchOptions{MatchAll: true})
+		assert.Equal(t, []string{"1"}, searchIDs(results))
+	})
+
+	t.Run("Should ignore stop words and case", func(t *testing.T) {
+		results, _ := newArticles().Search("THE and", SearchOptions{})
+		assert.Empty(t, results)
+
+		results, _ = newArticles().Search("КИЇВ", SearchOptions{})
+		assert.Equal(t, []string{"3"}, searchIDs(results))
+	})
+
+	t.Run("Should match prefixes", func(t *testing.T) {
+		collection := newArticles()
+
+		results, _ := collection.Search("прог", SearchOptions{})
+		assert.Empty(t, results)
+
+		results, _ = collection.Search("prog", SearchOptions{Prefix: true})
+		assert.Equal(t, []string{"2"}, searchIDs(results))
+
+		results, _ = collection.Search("укр", SearchOptions{Prefix: true, Limit: 1})
+		assert.Equal(t, []string{"3"}, searchIDs(results))
+	})
+
+	t.Run("Should follow writes", func(t *testing.T) {
+		collection := newArticles()
+		collection.Replace(articleDocument("1", "Slow turtle"))
+		collection.Delete("4")
+
+		results, _ := collection.Search("fox", SearchOptions{})
+		assert.Empty(t, results)
+		results, _ = collection.Search("turtle", SearchOptions{})
+		assert.Equal(t, []string{"1"}, searchIDs(results))
+		assert.NotContains(t, collection.textIndex.sorted, "fox")
+	})
+
+	t.Run("Should fail without index", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		_, err := collection.Search("fox", SearchOptions{})
+		assert.ErrorIs(t, err, ErrNoTextIndex)
+
+		_, err = OpenCollection(&CollectionConfig{PrimaryKey: "id", TextIndex: &TextIndexConfig{}})
+		assert.ErrorIs(t, err, ErrInvalidCollectionConfig)
+	})
+}
+
+func TestStore_Search(t *testing.T) {
+	cfg := &CollectionConfig{PrimaryKey: "id", TextIndex: &TextIndexConfig{Fields: []string{"title"}, DisableStopWords: true}}
+
+	t.Run("Should rebuild index from dump", func(t *testing.T) {
+		store := NewStore()
+		articles, _ := store.CreateCollection("articles", cfg)
+		articles.Put(articleDocument("1", "The fox"))
+
+		dump, _ := store.Dump()
+		restored, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+
+		restoredArticles, _ := restored.GetCollection("articles")
+		results, err := restoredArticles.Search("the", SearchOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1"}, searchIDs(results))
+	})
+
+	t.Run("Should rebuild index from database file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "store.db")
+		store, _ := OpenStore(filename)
+		articles, _ := store.CreateCollection("articles", cfg)
+		articles.Put(articleDocument("1", "The fox"))
+		assert.Nil(t, store.Close())
+
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		articles, _ = store.GetCollection("articles")
+		results, _ := articles.Search("fox", SearchOptions{})
+		assert.Equal(t, []string{"1"}, searchIDs(results))
+	})
+}
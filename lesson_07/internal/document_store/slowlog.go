@@ -0,0 +1,65 @@
+package documentstore
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// SlowLogConfig configures the log of slow operations on collections, see
+// SetSlowLog.
+type SlowLogConfig struct {
+	// Threshold is the duration from which an operation is slow.
+	Threshold time.Duration
+	// SampleRate is the fraction of slow operations logged, from 0 to 1, to
+	// keep the log small under load. Zero logs every slow operation.
+	SampleRate float64
+}
+
+var slowLog atomic.Pointer[SlowLogConfig]
+
+// sample returns a number in [0, 1) to sample slow operations with; tests
+// replace it.
+var sample = rand.Float64
+
+// SetSlowLog makes every collection log its operations taking at least
+// cfg.Threshold as warnings of ComponentSlowLog. Records hold the operation,
+// the collection and the duration, and, for queries, the filter shape and
+// the numbers of documents scanned and returned. A nil cfg stops logging.
+func SetSlowLog(cfg *SlowLogConfig) {
+	if cfg == nil {
+		slowLog.Store(nil)
+		return
+	}
+	c := *cfg
+	slowLog.Store(&c)
+}
+
+func (o *operation) logSlow(duration time.Duration, err error) {
+	cfg := slowLog.Load()
+	if cfg == nil || o.collection == nil || duration < cfg.Threshold {
+		return
+	}
+	if cfg.SampleRate > 0 && sample() >= cfg.SampleRate {
+		return
+	}
+
+	attrs := []any{
+		"operation", o.op,
+		"collection", o.collection.name,
+		"duration", duration,
+	}
+	if o.filter != "" {
+		attrs = append(attrs, "filter", o.filter)
+	}
+	if o.scannedDocs >= 0 {
+		attrs = append(attrs, "scanned", o.scannedDocs)
+	}
+	if o.returnedDocs >= 0 {
+		attrs = append(attrs, "returned", o.returnedDocs)
+	}
+	if err != nil {
+		attrs = append(attrs, "code", ErrorCodeOf(err))
+	}
+	slowLogger().WarnContext(o.ctx, "Slow operation", attrs...)
+}
@@ -0,0 +1,77 @@
+package documentstore
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func useSlowLog(t *testing.T, cfg *SlowLogConfig) *bytes.Buffer {
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	SetSlowLog(cfg)
+	t.Cleanup(func() {
+		SetSlowLog(nil)
+		slog.SetDefault(defaultLogger)
+	})
+
+	return &buf
+}
+
+func TestSetSlowLog(t *testing.T) {
+	t.Run("Should log slow queries with their filter and counts", func(t *testing.T) {
+		_, users := newUsersStore(t)
+		buf := useSlowLog(t, &SlowLogConfig{})
+
+		_, err := users.Aggregate(Match(Eq("name", "Jane")))
+		assert.Nil(t, err)
+		assert.Regexp(t, `level=WARN msg="Slow operation" component=slowlog operation=aggregate collection=users duration=\S+ filter="name eq \?" scanned=2 returned=1\n`, buf.String())
+	})
+
+	t.Run("Should log failed operations with their code", func(t *testing.T) {
+		_, users := newUsersStore(t)
+		buf := useSlowLog(t, &SlowLogConfig{})
+
+		_, err := users.Get("missing")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+		assert.Regexp(t, `operation=get collection=users duration=\S+ code=not_found\n`, buf.String())
+	})
+
+	t.Run("Should not log operations faster than the threshold", func(t *testing.T) {
+		_, users := newUsersStore(t)
+		buf := useSlowLog(t, &SlowLogConfig{Threshold: time.Hour})
+
+		users.List()
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("Should sample slow operations", func(t *testing.T) {
+		_, users := newUsersStore(t)
+		buf := useSlowLog(t, &SlowLogConfig{SampleRate: 0.5})
+		defaultSample := sample
+		samples := []float64{0.7, 0.2}
+		sample = func() float64 {
+			s := samples[0]
+			samples = samples[1:]
+			return s
+		}
+		t.Cleanup(func() { sample = defaultSample })
+
+		users.List()
+		assert.Empty(t, buf.String())
+		users.List()
+		assert.Contains(t, buf.String(), "operation=list collection=users")
+	})
+
+	t.Run("Should stop logging", func(t *testing.T) {
+		_, users := newUsersStore(t)
+		buf := useSlowLog(t, &SlowLogConfig{})
+		SetSlowLog(nil)
+
+		users.List()
+		assert.Empty(t, buf.String())
+	})
+}
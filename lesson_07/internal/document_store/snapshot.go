@@ -0,0 +1,123 @@
+package documentstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Snapshot is a read-only view of all collections of a Store at the moment
+// it was taken. Later writes to the store are not visible in the snapshot and
+// are not blocked by it. Collections of a snapshot reject writes with
+// ErrReadOnly. A snapshot must be closed to release engine resources.
+type Snapshot struct {
+	store *Store
+}
+
+// Snapshot captures every collection at a single point in time. Writers are
+// held back only while the views are created: memory collections are shared
+// copy-on-write, file backed collections keep a read transaction open.
+func (s *Store) Snapshot() (*Snapshot, error) {
+	storeLogger().Debug("Snapshot store")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.Collections))
+	for name := range s.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Locks are taken in a stable order so that concurrent snapshots cannot
+	// deadlock; holding all of them makes the views mutually consistent.
+	for _, name := range names {
+		s.Collections[name].mu.RLock()
+	}
+	defer func() {
+		for _, name := range names {
+			s.Collections[name].mu.RUnlock()
+		}
+	}()
+
+	snapshot := &Snapshot{store: NewStore()}
+	for _, name := range names {
+		collection, err := s.Collections[name].snapshot()
+		if err != nil {
+			_ = snapshot.Close()
+			return nil, fmt.Errorf("failed to snapshot collection %q: %w", name, err)
+		}
+		snapshot.store.attach(name, collection)
+	}
+
+	return snapshot, nil
+}
+
+// GetCollection returns the read-only view of a collection.
+func (s *Snapshot) GetCollection(name string) (*Collection, bool) {
+	collection, ok := s.store.Collections[name]
+	return collection, ok
+}
+
+// CollectionNames returns names of all collections in the snapshot.
+func (s *Snapshot) CollectionNames() []string {
+	names := make([]string, 0, len(s.store.Collections))
+	for name := range s.store.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Dump returns the same format as Store.Dump.
+func (s *Snapshot) Dump() ([]byte, error) {
+	return s.DumpContext(context.Background())
+}
+
+// DumpContext is Dump that gives up when ctx is done.
+func (s *Snapshot) DumpContext(ctx context.Context) (_ []byte, err error) {
+	persistenceLogger().DebugContext(ctx, "Dump snapshot")
+	ctx, o := startPersistence(ctx, OpDump)
+	defer o.end(&err)
+	public := struct {
+		Collections map[string]json.RawMessage `json:"collections"`
+	}{Collections: make(map[string]json.RawMessage, len(s.store.Collections))}
+	for name, collection := range s.store.Collections {
+		data, err := collection.marshal(ctx)
+		if err != nil {
+			persistenceLogger().ErrorContext(ctx, "Failed to Dump() snapshot:", "err", err)
+			return nil, fmt.Errorf("collection %q: %w", name, err)
+		}
+		public.Collections[name] = data
+	}
+
+	jsonBytes, err := json.MarshalIndent(public, "", "  ")
+	if err != nil {
+		persistenceLogger().ErrorContext(ctx, "Failed to Dump() snapshot:", "err", err)
+		return nil, err
+	}
+	o.setSize(len(jsonBytes))
+
+	return jsonBytes, nil
+}
+
+func (s *Snapshot) DumpToFile(filename string) error {
+	return s.DumpToFileContext(context.Background(), filename)
+}
+
+// DumpToFileContext is DumpToFile that gives up when ctx is done.
+func (s *Snapshot) DumpToFileContext(ctx context.Context, filename string) error {
+	persistenceLogger().DebugContext(ctx, "Snapshot DumpToFile", "filename", filename)
+	return writeDumpToFile(ctx, filename, s.DumpContext)
+}
+
+func (s *Snapshot) Close() error {
+	var errs []error
+	for _, collection := range s.store.Collections {
+		errs = append(errs, collection.close())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package documentstore
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func userDocument(id string, name string) Document {
+	return Document{
+		Fields: map[string]DocumentField{
+			"id":   {Type: DocumentFieldTypeString, Value: id},
+			"name": {Type: DocumentFieldTypeString, Value: name},
+		},
+	}
+}
+
+func TestStore_Snapshot(t *testing.T) {
+	stores := map[string]func(t *testing.T) *Store{
+		"memory": func(t *testing.T) *Store {
+			return NewStore()
+		},
+		"file": func(t *testing.T) *Store {
+			store, err := OpenStore(filepath.Join(t.TempDir(), "store.db"))
+			assert.Nil(t, err)
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name+": should not see later writes", func(t *testing.T) {
+			store := newStore(t)
+			users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+			orders, _ := store.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+			users.Put(userDocument("1", "John"))
+
+			snapshot, err := store.Snapshot()
+			assert.Nil(t, err)
+			defer snapshot.Close()
+
+			users.Put(userDocument("2", "Jane"))
+			users.Delete("1")
+			orders.Put(userDocument("1", "order"))
+
+			snapshotUsers, ok := snapshot.GetCollection("users")
+			assert.True(t, ok)
+			assert.Len(t, snapshotUsers.List(), 1)
+			doc, err := snapshotUsers.Get("1")
+			assert.Nil(t, err)
+			assert.Equal(t, "John", doc.GetField("name"))
+
+			snapshotOrders, _ := snapshot.GetCollection("orders")
+			assert.Empty(t, snapshotOrders.List())
+			assert.Equal(t, []string{"orders", "users"}, snapshot.CollectionNames())
+
+			assert.Len(t, users.List(), 1)
+			_, err = users.Get("2")
+			assert.Nil(t, err)
+		})
+
+		t.Run(name+": should reject writes", func(t *testing.T) {
+			store := newStore(t)
+			store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+			snapshot, err := store.Snapshot()
+			assert.Nil(t, err)
+			defer snapshot.Close()
+
+			users, _ := snapshot.GetCollection("users")
+			_, err = users.Put(userDocument("1", "John"))
+			assert.ErrorIs(t, err, ErrReadOnly)
+			assert.Error(t, users.Delete("1"))
+		})
+	}
+
+	t.Run("Should dump snapshot in store format", func(t *testing.T) {
+		store := NewStore()
+		users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		users.Put(userDocument("1", "John"))
+
+		snapshot, err := store.Snapshot()
+		assert.Nil(t, err)
+		defer snapshot.Close()
+		users.Put(userDocument("2", "Jane"))
+
+		dump, err := snapshot.Dump()
+		assert.Nil(t, err)
+		restored, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+		restoredUsers, _ := restored.GetCollection("users")
+		assert.Len(t, restoredUsers.List(), 1)
+	})
+
+	t.Run("Should dump while writes continue", func(t *testing.T) {
+		store := NewStore()
+		users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				users.Put(userDocument(fmt.Sprintf("user-%d", i), "John"))
+			}
+		}()
+
+		for i := 0; i < 20; i++ {
+			dump, err := store.Dump()
+			assert.Nil(t, err)
+			_, err = NewStoreFromDump(dump)
+			assert.Nil(t, err)
+		}
+		wg.Wait()
+		assert.Len(t, users.List(), 500)
+	})
+}
@@ -0,0 +1,214 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"lesson_07/internal/btree"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Names of the indexes in CollectionStats.Indexes.
+const (
+	IndexPrimaryKey = "primary_key"
+	IndexText       = "text"
+	IndexGeo        = "geo"
+)
+
+// CollectionStats describes the documents of a collection, see
+// Collection.Stats.
+type CollectionStats struct {
+	// Documents counts documents that have not expired.
+	Documents int `json:"documents"`
+	// Fields describes top-level fields by name.
+	Fields map[string]FieldStats `json:"fields"`
+	// AvgDocumentSize and MaxDocumentSize approximate the memory held by a
+	// document, in bytes.
+	AvgDocumentSize float64 `json:"avgDocumentSize"`
+	MaxDocumentSize int     `json:"maxDocumentSize"`
+	// Indexes describes the primary key and the text and geo indexes of the
+	// collection, keyed by IndexPrimaryKey, IndexText and IndexGeo.
+	Indexes map[string]IndexStats `json:"indexes"`
+	// LastWrite is the time of the last put or delete, kept in dumps, and in
+	// store files when the store is closed; zero if there was none.
+	LastWrite time.Time `json:"lastWrite"`
+}
+
+// FieldStats describes a field of the documents of a collection.
+type FieldStats struct {
+	// Count is the number of documents having the field.
+	Count int `json:"count"`
+	// Presence is the fraction of documents having the field.
+	Presence float64 `json:"presence"`
+	// Types counts the documents having the field by its type. More than one
+	// type means the documents drifted apart.
+	Types map[DocumentFieldType]int `json:"types"`
+}
+
+// IndexStats describes an index.
+type IndexStats struct {
+	// Entries is the number of indexed documents. Expired documents not yet
+	// removed have primary key entries.
+	Entries int `json:"entries"`
+	// Size approximates the memory held by the index, in bytes.
+	Size int `json:"size"`
+}
+
+// StoreStats describes the collections of a store, see Store.Stats.
+type StoreStats struct {
+	Collections map[string]CollectionStats `json:"collections"`
+	// Documents counts documents of all collections.
+	Documents int `json:"documents"`
+	// LastWrite is the latest LastWrite of the collections.
+	LastWrite time.Time `json:"lastWrite"`
+}
+
+// Stats reads every document to describe the collection. Like List it reads
+// from a snapshot, so writers are not blocked.
+func (s *Collection) Stats() (*CollectionStats, error) {
+	return s.StatsContext(context.Background())
+}
+
+// StatsContext is Stats that gives up when ctx is done.
+func (s *Collection) StatsContext(ctx context.Context) (*CollectionStats, error) {
+	collectionLogger().DebugContext(ctx, "Collect collection stats")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := &CollectionStats{Fields: map[string]FieldStats{}, Indexes: map[string]IndexStats{}}
+	s.mu.RLock()
+	documents, err := s.documents.snapshot()
+	if err == nil {
+		stats.LastWrite = s.lastWrite
+		if s.textIndex != nil {
+			stats.Indexes[IndexText] = s.textIndex.stats()
+		}
+		if s.geoIndex != nil {
+			stats.Indexes[IndexGeo] = s.geoIndex.stats()
+		}
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, s.docError("", "", err)
+	}
+	defer documents.close()
+
+	var primaryKey IndexStats
+	totalSize := 0
+	at := now()
+	var ctxErr error
+	err = documents.scan("", func(key string, doc Document) bool {
+		if ctxErr = ctx.Err(); ctxErr != nil {
+			return false
+		}
+		primaryKey.Entries++
+		primaryKey.Size += 16 + len(key)
+		if doc.expired(at) {
+			return true
+		}
+
+		stats.Documents++
+		size := documentSize(doc)
+		totalSize += size
+		stats.MaxDocumentSize = max(stats.MaxDocumentSize, size)
+		for name, field := range doc.Fields {
+			fieldStats := stats.Fields[name]
+			if fieldStats.Types == nil {
+				fieldStats.Types = map[DocumentFieldType]int{}
+			}
+			fieldStats.Count++
+			fieldStats.Types[field.Type]++
+			stats.Fields[name] = fieldStats
+		}
+		return true
+	})
+	if err = errors.Join(err, ctxErr); err != nil {
+		return nil, s.docError("", "", err)
+	}
+
+	stats.Indexes[IndexPrimaryKey] = primaryKey
+	if stats.Documents > 0 {
+		stats.AvgDocumentSize = float64(totalSize) / float64(stats.Documents)
+		for name, fieldStats := range stats.Fields {
+			fieldStats.Presence = float64(fieldStats.Count) / float64(stats.Documents)
+			stats.Fields[name] = fieldStats
+		}
+	}
+
+	return stats, nil
+}
+
+// Stats describes every collection of the store, see Collection.Stats.
+func (s *Store) Stats() (*StoreStats, error) {
+	return s.StatsContext(context.Background())
+}
+
+// StatsContext is Stats that gives up when ctx is done.
+func (s *Store) StatsContext(ctx context.Context) (*StoreStats, error) {
+	storeLogger().DebugContext(ctx, "Collect store stats")
+	s.mu.RLock()
+	collections := maps.Clone(s.Collections)
+	s.mu.RUnlock()
+
+	stats := &StoreStats{Collections: map[string]CollectionStats{}}
+	for _, name := range slices.Sorted(maps.Keys(collections)) {
+		collectionStats, err := collections[name].StatsContext(ctx)
+		if err != nil {
+			return nil, newError(name, "", "", err)
+		}
+		stats.Collections[name] = *collectionStats
+		stats.Documents += collectionStats.Documents
+		if collectionStats.LastWrite.After(stats.LastWrite) {
+			stats.LastWrite = collectionStats.LastWrite
+		}
+	}
+
+	return stats, nil
+}
+
+func (idx *textIndex) stats() IndexStats {
+	size := 0
+	for word, docs := range idx.postings {
+		size += 16 + len(word) + 48
+		for id := range docs {
+			size += 16 + len(id) + 8
+		}
+	}
+	for _, word := range idx.sorted {
+		size += 16 + len(word)
+	}
+	for id := range idx.lengths {
+		size += 16 + len(id) + 8
+	}
+
+	return IndexStats{Entries: len(idx.lengths), Size: size}
+}
+
+func (idx *geoIndex) stats() IndexStats {
+	size := 0
+	for _, entry := range idx.entries {
+		size += 32 + len(entry.hash) + len(entry.id)
+	}
+	for id := range idx.points {
+		size += 16 + len(id) + 16
+	}
+
+	return IndexStats{Entries: len(idx.points), Size: size}
+}
+
+// saveLastWrite updates the catalog entry of the collection with the time of
+// the last write. It is kept in memory until the store is closed or a batch
+// is flushed. The entry of a deleted collection is not written back. Must be
+// called with s.mu held.
+func (s *Collection) saveLastWrite(tx *btree.Tx) error {
+	if s.lastWrite.IsZero() {
+		return nil
+	}
+	if data, err := tx.Get([]byte(catalogPrefix + s.name)); err != nil || data == nil {
+		return err
+	}
+
+	return putCatalogEntry(tx, s.name, catalogEntry{CollectionConfig: s.cfg, LastWrite: &s.lastWrite})
+}
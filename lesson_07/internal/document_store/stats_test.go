@@ -0,0 +1,186 @@
+package documentstore
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"lesson_07/internal/btree"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCollection_Stats(t *testing.T) {
+	t.Run("Should describe fields and documents", func(t *testing.T) {
+		start, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+
+		stats, err := collection.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, stats.Documents)
+		assert.Empty(t, stats.Fields)
+		assert.True(t, stats.LastWrite.IsZero())
+
+		collection.Put(userDocument("1", "John"))
+		collection.Put(userDocument("2", "Jane"))
+		advance(time.Minute)
+		drifted := userDocument("3", "Jack")
+		drifted.Fields["name"] = DocumentField{Type: DocumentFieldTypeNumber, Value: 3}
+		drifted.Fields["age"] = DocumentField{Type: DocumentFieldTypeNumber, Value: 30}
+		collection.Put(drifted)
+
+		stats, err = collection.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, 3, stats.Documents)
+		assert.Equal(t, FieldStats{Count: 3, Presence: 1, Types: map[DocumentFieldType]int{DocumentFieldTypeString: 3}}, stats.Fields["id"])
+		assert.Equal(t, FieldStats{Count: 3, Presence: 1, Types: map[DocumentFieldType]int{DocumentFieldTypeString: 2, DocumentFieldTypeNumber: 1}}, stats.Fields["name"])
+		assert.Equal(t, FieldStats{Count: 1, Presence: 1.0 / 3, Types: map[DocumentFieldType]int{DocumentFieldTypeNumber: 1}}, stats.Fields["age"])
+		assert.Equal(t, documentSize(drifted), stats.MaxDocumentSize)
+		assert.InDelta(t, float64(documentSize(userDocument("1", "John"))*2+documentSize(drifted))/3, stats.AvgDocumentSize, 0.001)
+		assert.Equal(t, map[string]IndexStats{IndexPrimaryKey: {Entries: 3, Size: 51}}, stats.Indexes)
+		assert.Equal(t, start.Add(time.Minute), stats.LastWrite)
+
+		advance(time.Minute)
+		assert.Nil(t, collection.Delete("3"))
+		stats, err = collection.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, stats.Documents)
+		assert.NotContains(t, stats.Fields, "age")
+		assert.Equal(t, start.Add(2*time.Minute), stats.LastWrite)
+	})
+
+	t.Run("Should not count expired documents", func(t *testing.T) {
+		_, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", DefaultTTL: time.Hour})
+		collection.Put(userDocument("1", "John"))
+		advance(2 * time.Hour)
+
+		stats, err := collection.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, stats.Documents)
+		assert.Equal(t, 0.0, stats.AvgDocumentSize)
+		assert.Equal(t, 1, stats.Indexes[IndexPrimaryKey].Entries)
+	})
+
+	t.Run("Should describe text and geo indexes", func(t *testing.T) {
+		places := newPlaces(t)
+		articles := NewCollection(&CollectionConfig{PrimaryKey: "id", TextIndex: &TextIndexConfig{Fields: []string{"title"}}})
+		articles.Put(articleDocument("1", "Go stats"))
+		articles.Put(articleDocument("2", "No words"))
+		articles.Put(Document{Fields: map[string]DocumentField{"id": {Type: DocumentFieldTypeString, Value: "3"}}})
+
+		stats, err := places.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, 6, stats.Indexes[IndexGeo].Entries)
+		assert.Positive(t, stats.Indexes[IndexGeo].Size)
+
+		stats, err = articles.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, stats.Indexes[IndexText].Entries)
+		assert.Positive(t, stats.Indexes[IndexText].Size)
+		assert.Equal(t, 2.0/3, stats.Fields["title"].Presence)
+	})
+
+	t.Run("Should not read with a cancelled context", func(t *testing.T) {
+		_, collection := newUsersStore(t)
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := collection.StatsContext(cancelled)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestStore_Stats(t *testing.T) {
+	t.Run("Should describe every collection", func(t *testing.T) {
+		start, advance := useClock(t)
+		store, _ := newUsersStore(t)
+		advance(time.Minute)
+		posts, _ := store.CreateCollection("posts", &CollectionConfig{PrimaryKey: "id"})
+		posts.Put(userDocument("1", "Post"))
+
+		stats, err := store.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, 3, stats.Documents)
+		assert.Equal(t, 2, stats.Collections["users"].Documents)
+		assert.Equal(t, start, stats.Collections["users"].LastWrite)
+		assert.Equal(t, 1, stats.Collections["posts"].Documents)
+		assert.Equal(t, start.Add(time.Minute), stats.LastWrite)
+	})
+
+	t.Run("Should keep the last write time in dumps", func(t *testing.T) {
+		start, _ := useClock(t)
+		store, _ := newUsersStore(t)
+		dump, err := store.Dump()
+		assert.Nil(t, err)
+
+		loaded, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+		stats, err := loaded.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, start, stats.LastWrite)
+	})
+}
+
+func TestStore_LastWrite(t *testing.T) {
+	t.Run("Should keep the last write time in store files", func(t *testing.T) {
+		start, advance := useClock(t)
+		filename := filepath.Join(t.TempDir(), "store.db")
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		store.CreateCollection("posts", &CollectionConfig{PrimaryKey: "id"})
+		users.Put(userDocument("1", "John"))
+		advance(time.Minute)
+		_, err = users.BulkWrite([]BulkOp{InsertOp(userDocument("2", "Jane"))}, BulkOptions{})
+		assert.Nil(t, err)
+		assert.Nil(t, store.Close())
+
+		store, err = OpenStore(filename)
+		assert.Nil(t, err)
+		stats, err := store.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, start.Add(time.Minute), stats.Collections["users"].LastWrite)
+		assert.True(t, stats.Collections["posts"].LastWrite.IsZero())
+
+		advance(time.Minute)
+		users, _ = store.GetCollection("users")
+		assert.Nil(t, users.Delete("1"))
+		assert.Nil(t, store.Close())
+
+		store, err = OpenStore(filename)
+		assert.Nil(t, err)
+		defer store.Close()
+		stats, err = store.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, start.Add(2*time.Minute), stats.LastWrite)
+	})
+
+	t.Run("Should open store files without the last write time", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "store.db")
+		db, err := btree.Open(filename, nil)
+		assert.Nil(t, err)
+		err = db.Update(func(tx *btree.Tx) error {
+			return tx.Put([]byte(catalogPrefix+"users"), []byte(`{"primaryKey":"id"}`))
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, db.Close())
+
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		defer store.Close()
+		stats, err := store.Stats()
+		assert.Nil(t, err)
+		assert.True(t, stats.LastWrite.IsZero())
+		users, _ := store.GetCollection("users")
+		_, err = users.Put(userDocument("1", "John"))
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should load dumps without the last write time", func(t *testing.T) {
+		store, err := NewStoreFromDump([]byte(`{"collections": {"users": {"cfg": {"primaryKey": "id"}, "documents": {}}}}`))
+		assert.Nil(t, err)
+		stats, err := store.Stats()
+		assert.Nil(t, err)
+		assert.True(t, stats.LastWrite.IsZero())
+	})
+}
@@ -0,0 +1,177 @@
+package documentstore
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"maps"
+	"sync/atomic"
+)
+
+var errReadOnlyTable = errors.New("table is read-only")
+
+// table is an ordered key/value storage behind a collection. Implementations
+// are not safe for concurrent use unless stated otherwise.
+type table[V any] interface {
+	get(key string) (V, bool, error)
+	put(key string, value V) error
+	delete(key string) (bool, error)
+	// scan calls fn for every key >= from in ascending order until fn returns false.
+	scan(from string, fn func(key string, value V) bool) error
+	len() int
+	// snapshot returns a read-only view of the current content that is not
+	// affected by later writes. The view must be closed; it is safe for
+	// concurrent reads.
+	snapshot() (table[V], error)
+	close() error
+}
+
+// memoryItems is a map shared between a memory table and its snapshots.
+type memoryItems[V any] struct {
+	items     map[string]V
+	snapshots atomic.Int32
+	// size is the sum of itemSize of the items, for sized tables.
+	size int
+}
+
+// memoryTable keeps values in a map. Order is established on scan, lazily,
+// so that a scan stopping early does not sort every key. Snapshots
+// share the map; the first write after a snapshot copies it.
+type memoryTable[V any] struct {
+	data     *memoryItems[V]
+	readOnly bool
+	closed   atomic.Bool
+	// sized tracks the size of the items on writes, see itemSize.
+	sized bool
+}
+
+func newMemoryTable[V any](items map[string]V) *memoryTable[V] {
+	if items == nil {
+		items = map[string]V{}
+	}
+
+	return &memoryTable[V]{data: &memoryItems[V]{items: items}}
+}
+
+func (t *memoryTable[V]) get(key string) (V, bool, error) {
+	value, ok := t.data.items[key]
+	return value, ok, nil
+}
+
+// mutable returns the map for a write, copying it when snapshots use it.
+func (t *memoryTable[V]) mutable() (map[string]V, error) {
+	if t.readOnly {
+		return nil, errReadOnlyTable
+	}
+	if t.data.snapshots.Load() > 0 {
+		t.data = &memoryItems[V]{items: maps.Clone(t.data.items), size: t.data.size}
+	}
+
+	return t.data.items, nil
+}
+
+func (t *memoryTable[V]) put(key string, value V) error {
+	items, err := t.mutable()
+	if err != nil {
+		return err
+	}
+
+	if t.sized {
+		if old, ok := items[key]; ok {
+			t.data.size -= itemSize(key, old)
+		}
+		t.data.size += itemSize(key, value)
+	}
+	items[key] = value
+	return nil
+}
+
+func (t *memoryTable[V]) delete(key string) (bool, error) {
+	old, ok := t.data.items[key]
+	if !ok {
+		return false, nil
+	}
+
+	items, err := t.mutable()
+	if err != nil {
+		return false, err
+	}
+
+	if t.sized {
+		t.data.size -= itemSize(key, old)
+	}
+	delete(items, key)
+	return true, nil
+}
+
+func (t *memoryTable[V]) scan(from string, fn func(key string, value V) bool) error {
+	items := t.data.items
+	keys := make(keyHeap, 0, len(items))
+	for key := range items {
+		if key >= from {
+			keys = append(keys, key)
+		}
+	}
+	heap.Init(&keys)
+
+	for keys.Len() > 0 {
+		key := heap.Pop(&keys).(string)
+		if !fn(key, items[key]) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// keyHeap pops keys in ascending order.
+type keyHeap []string
+
+func (h keyHeap) Len() int           { return len(h) }
+func (h keyHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h keyHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *keyHeap) Push(x any)        { *h = append(*h, x.(string)) }
+
+func (h *keyHeap) Pop() any {
+	old := *h
+	key := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return key
+}
+
+func (t *memoryTable[V]) len() int {
+	return len(t.data.items)
+}
+
+func (t *memoryTable[V]) snapshot() (table[V], error) {
+	t.data.snapshots.Add(1)
+	return &memoryTable[V]{data: t.data, readOnly: true}, nil
+}
+
+func (t *memoryTable[V]) close() error {
+	if t.readOnly && t.closed.CompareAndSwap(false, true) {
+		t.data.snapshots.Add(-1)
+	}
+
+	return nil
+}
+
+// tableItems collects the content of a table into a map.
+func tableItems[V any](t table[V]) (map[string]V, error) {
+	return tableItemsContext(context.Background(), t)
+}
+
+// tableItemsContext is tableItems that gives up when ctx is done.
+func tableItemsContext[V any](ctx context.Context, t table[V]) (map[string]V, error) {
+	items := make(map[string]V, t.len())
+	var ctxErr error
+	err := t.scan("", func(key string, value V) bool {
+		if ctxErr = ctx.Err(); ctxErr != nil {
+			return false
+		}
+		items[key] = value
+		return true
+	})
+
+	return items, errors.Join(err, ctxErr)
+}
@@ -0,0 +1,396 @@
+package documentstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lesson_07/internal/btree"
+	"sort"
+	"sync/atomic"
+)
+
+// Key layout of a database file:
+//
+//	c\x00<collection>              collection config
+//	n\x00<collection>              number of documents
+//	d\x00<collection>\x00<id>      document
+//	n\x00h\x00<collection>          number of document histories
+//	h\x00<collection>\x00<id>      versions of a document
+const (
+	catalogPrefix  = "c\x00"
+	countPrefix    = "n\x00"
+	documentPrefix = "d\x00"
+	historyPrefix  = "h\x00"
+)
+
+func tableKeyPrefix(kind string, collection string) []byte {
+	return []byte(kind + collection + "\x00")
+}
+
+// treeTable stores JSON encoded values in a B+tree file under a key prefix.
+// Every write is committed in its own transaction, unless a batch is
+// running: its writes are kept in memory and committed together by flush.
+type treeTable[V any] struct {
+	db       *btree.DB
+	prefix   []byte
+	countKey []byte
+	count    int
+	// pending holds encoded values written by a running batch; nil marks a
+	// delete.
+	pending map[string][]byte
+}
+
+func openTreeTable[V any](db *btree.DB, prefix []byte, countKey []byte) (*treeTable[V], error) {
+	t := &treeTable[V]{db: db, prefix: prefix, countKey: countKey}
+	return t, t.loadCount()
+}
+
+func (t *treeTable[V]) loadCount() error {
+	t.count = 0
+	return t.db.View(func(tx *btree.Tx) error {
+		value, err := tx.Get(t.countKey)
+		if err != nil || value == nil {
+			return err
+		}
+		t.count = int(binary.BigEndian.Uint64(value))
+		return nil
+	})
+}
+
+func (t *treeTable[V]) key(key string) []byte {
+	return append(bytes.Clone(t.prefix), key...)
+}
+
+func (t *treeTable[V]) get(key string) (V, bool, error) {
+	var value V
+	if data, ok := t.pending[key]; ok {
+		if data == nil {
+			return value, false, nil
+		}
+		return value, true, json.Unmarshal(data, &value)
+	}
+
+	var found bool
+	err := t.db.View(func(tx *btree.Tx) (err error) {
+		value, found, err = treeGet[V](tx, t.prefix, key)
+		return err
+	})
+
+	return value, found, err
+}
+
+func treeGet[V any](tx *btree.Tx, prefix []byte, key string) (V, bool, error) {
+	var value V
+	data, err := tx.Get(append(bytes.Clone(prefix), key...))
+	if err != nil || data == nil {
+		return value, false, err
+	}
+
+	return value, true, json.Unmarshal(data, &value)
+}
+
+func (t *treeTable[V]) put(key string, value V) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %q: %w", key, err)
+	}
+
+	if t.pending != nil {
+		found, err := t.exists(key)
+		if err != nil {
+			return err
+		}
+		if !found {
+			t.count++
+		}
+		t.pending[key] = data
+		return nil
+	}
+
+	count := t.count
+	err = t.db.Update(func(tx *btree.Tx) error {
+		existing, err := tx.Get(t.key(key))
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			count++
+		}
+		if err := tx.Put(t.key(key), data); err != nil {
+			return err
+		}
+		return t.putCount(tx, count)
+	})
+	if err != nil {
+		return err
+	}
+
+	t.count = count
+	return nil
+}
+
+func (t *treeTable[V]) delete(key string) (bool, error) {
+	if t.pending != nil {
+		found, err := t.exists(key)
+		if err != nil || !found {
+			return false, err
+		}
+		t.count--
+		t.pending[key] = nil
+		return true, nil
+	}
+
+	var deleted bool
+	err := t.db.Update(func(tx *btree.Tx) (err error) {
+		if deleted, err = tx.Delete(t.key(key)); err != nil || !deleted {
+			return err
+		}
+		return t.putCount(tx, t.count-1)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if deleted {
+		t.count--
+	}
+	return deleted, nil
+}
+
+func (t *treeTable[V]) exists(key string) (bool, error) {
+	if data, ok := t.pending[key]; ok {
+		return data != nil, nil
+	}
+
+	var found bool
+	err := t.db.View(func(tx *btree.Tx) error {
+		data, err := tx.Get(t.key(key))
+		found = data != nil
+		return err
+	})
+
+	return found, err
+}
+
+// begin starts keeping writes in memory until flush.
+func (t *treeTable[V]) begin() {
+	t.pending = map[string][]byte{}
+}
+
+// flush writes the values of a batch in tx and ends the batch. When tx
+// fails to commit, end must be called with the error to restore the count.
+func (t *treeTable[V]) flush(tx *btree.Tx) error {
+	for key, data := range t.pending {
+		var err error
+		if data == nil {
+			_, err = tx.Delete(t.key(key))
+		} else {
+			err = tx.Put(t.key(key), data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(t.pending) == 0 {
+		return nil
+	}
+
+	return t.putCount(tx, t.count)
+}
+
+// end drops the writes of a batch. After a failed flush the count is read
+// back from the file.
+func (t *treeTable[V]) end(err error) error {
+	t.pending = nil
+	if err != nil {
+		return t.loadCount()
+	}
+
+	return nil
+}
+
+func (t *treeTable[V]) putCount(tx *btree.Tx, count int) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(count))
+
+	return tx.Put(t.countKey, value)
+}
+
+func (t *treeTable[V]) scan(from string, fn func(key string, value V) bool) error {
+	if t.pending == nil {
+		return t.db.View(func(tx *btree.Tx) error {
+			return treeScan(tx, t.prefix, from, fn)
+		})
+	}
+
+	// Merge the pending writes of a batch into the stored values.
+	var keys []string
+	for key := range t.pending {
+		if key >= from {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	next := 0
+	stopped := false
+	var decodeErr error
+	emit := func(key string, data []byte) bool {
+		if data == nil {
+			return true
+		}
+		var value V
+		if decodeErr = json.Unmarshal(data, &value); decodeErr != nil {
+			decodeErr = fmt.Errorf("failed to decode %q: %w", key, decodeErr)
+			return false
+		}
+		stopped = !fn(key, value)
+		return !stopped
+	}
+
+	err := t.db.View(func(tx *btree.Tx) error {
+		return treeScanData(tx, t.prefix, from, func(key string, data []byte) bool {
+			for ; next < len(keys) && keys[next] < key; next++ {
+				if !emit(keys[next], t.pending[keys[next]]) {
+					return false
+				}
+			}
+			if next < len(keys) && keys[next] == key {
+				data = t.pending[key]
+				next++
+			}
+			return emit(key, data)
+		})
+	})
+	for ; err == nil && decodeErr == nil && !stopped && next < len(keys); next++ {
+		emit(keys[next], t.pending[keys[next]])
+	}
+
+	return errors.Join(err, decodeErr)
+}
+
+func treeScan[V any](tx *btree.Tx, prefix []byte, from string, fn func(key string, value V) bool) error {
+	var decodeErr error
+	err := treeScanData(tx, prefix, from, func(key string, data []byte) bool {
+		var value V
+		if decodeErr = json.Unmarshal(data, &value); decodeErr != nil {
+			decodeErr = fmt.Errorf("failed to decode %q: %w", key, decodeErr)
+			return false
+		}
+		return fn(key, value)
+	})
+
+	return errors.Join(err, decodeErr)
+}
+
+// treeScanData calls fn with the encoded values of keys >= from under prefix.
+func treeScanData(tx *btree.Tx, prefix []byte, from string, fn func(key string, data []byte) bool) error {
+	c := tx.Cursor()
+	for k, data := c.Seek(append(bytes.Clone(prefix), from...)); k != nil && bytes.HasPrefix(k, prefix); k, data = c.Next() {
+		if !fn(string(k[len(prefix):]), data) {
+			return nil
+		}
+	}
+
+	return c.Err()
+}
+
+func (t *treeTable[V]) len() int {
+	return t.count
+}
+
+// snapshot keeps a read transaction open, so the view observes the version
+// of the file at the time it was taken. During a batch the content is copied
+// to memory instead, as pending writes are not in the file yet.
+func (t *treeTable[V]) snapshot() (table[V], error) {
+	if t.pending != nil {
+		items, err := tableItems[V](t)
+		if err != nil {
+			return nil, err
+		}
+		return newMemoryTable(items).snapshot()
+	}
+
+	tx, err := t.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &treeView{tx: tx}
+	view.refs.Store(1)
+
+	return &treeSnapshot[V]{view: view, prefix: t.prefix, count: t.count}, nil
+}
+
+func (t *treeTable[V]) close() error {
+	return nil
+}
+
+// drop removes every key of the table in a single transaction.
+func (t *treeTable[V]) drop(tx *btree.Tx) error {
+	var keys [][]byte
+	c := tx.Cursor()
+	for k, _ := c.Seek(t.prefix); k != nil && bytes.HasPrefix(k, t.prefix); k, _ = c.Next() {
+		keys = append(keys, k)
+	}
+	if err := c.Err(); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if _, err := tx.Delete(k); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Delete(t.countKey)
+
+	return err
+}
+
+// treeView is a read transaction shared by snapshots of a tree table.
+type treeView struct {
+	tx   *btree.Tx
+	refs atomic.Int32
+}
+
+type treeSnapshot[V any] struct {
+	view   *treeView
+	prefix []byte
+	count  int
+	closed atomic.Bool
+}
+
+func (t *treeSnapshot[V]) get(key string) (V, bool, error) {
+	return treeGet[V](t.view.tx, t.prefix, key)
+}
+
+func (t *treeSnapshot[V]) put(string, V) error {
+	return errReadOnlyTable
+}
+
+func (t *treeSnapshot[V]) delete(string) (bool, error) {
+	return false, errReadOnlyTable
+}
+
+func (t *treeSnapshot[V]) scan(from string, fn func(key string, value V) bool) error {
+	return treeScan(t.view.tx, t.prefix, from, fn)
+}
+
+func (t *treeSnapshot[V]) len() int {
+	return t.count
+}
+
+func (t *treeSnapshot[V]) snapshot() (table[V], error) {
+	t.view.refs.Add(1)
+	return &treeSnapshot[V]{view: t.view, prefix: t.prefix, count: t.count}, nil
+}
+
+func (t *treeSnapshot[V]) close() error {
+	if t.closed.CompareAndSwap(false, true) && t.view.refs.Add(-1) == 0 {
+		return t.view.tx.Rollback()
+	}
+
+	return nil
+}
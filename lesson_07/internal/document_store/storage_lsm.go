@@ -0,0 +1,181 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"lesson_07/internal/lsm"
+	"sync/atomic"
+)
+
+// lsmTable stores JSON encoded values in an LSM tree directory. The number
+// of values is tracked in memory and rebuilt on open.
+type lsmTable[V any] struct {
+	db    *lsm.DB
+	count int
+}
+
+func openLSMTable[V any](cfg *LSMConfig) (*lsmTable[V], error) {
+	if cfg == nil || cfg.Dir == "" {
+		return nil, fmt.Errorf("%w: lsm engine requires a directory", ErrInvalidCollectionConfig)
+	}
+
+	db, err := lsm.Open(cfg.Dir, &lsm.Options{
+		MemtableSize:        cfg.MemtableSize,
+		L0CompactionTrigger: cfg.L0CompactionTrigger,
+		BaseLevelSize:       cfg.BaseLevelSize,
+		LevelSizeMultiplier: cfg.LevelSizeMultiplier,
+		TargetFileSize:      cfg.TargetFileSize,
+		BloomBitsPerKey:     cfg.BloomBitsPerKey,
+		SyncWrites:          cfg.SyncWrites,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t := &lsmTable[V]{db: db}
+	err = db.Scan("", func(string, []byte) bool {
+		t.count++
+		return true
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *lsmTable[V]) get(key string) (V, bool, error) {
+	var value V
+	data, ok, err := t.db.Get(key)
+	if err != nil || !ok {
+		return value, false, err
+	}
+
+	return value, true, json.Unmarshal(data, &value)
+}
+
+func (t *lsmTable[V]) put(key string, value V) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %q: %w", key, err)
+	}
+
+	_, exists, err := t.db.Get(key)
+	if err != nil {
+		return err
+	}
+	if err := t.db.Put(key, data); err != nil {
+		return err
+	}
+	if !exists {
+		t.count++
+	}
+
+	return nil
+}
+
+func (t *lsmTable[V]) delete(key string) (bool, error) {
+	_, exists, err := t.db.Get(key)
+	if err != nil || !exists {
+		return false, err
+	}
+	if err := t.db.Delete(key); err != nil {
+		return false, err
+	}
+	t.count--
+
+	return true, nil
+}
+
+func (t *lsmTable[V]) scan(from string, fn func(key string, value V) bool) error {
+	return lsmScan(t.db.Scan, from, fn)
+}
+
+func lsmScan[V any](scan func(string, func(string, []byte) bool) error, from string, fn func(key string, value V) bool) error {
+	var decodeErr error
+	err := scan(from, func(key string, data []byte) bool {
+		var value V
+		if decodeErr = json.Unmarshal(data, &value); decodeErr != nil {
+			decodeErr = fmt.Errorf("failed to decode %q: %w", key, decodeErr)
+			return false
+		}
+		return fn(key, value)
+	})
+	if err != nil {
+		return err
+	}
+
+	return decodeErr
+}
+
+func (t *lsmTable[V]) len() int {
+	return t.count
+}
+
+func (t *lsmTable[V]) close() error {
+	return t.db.Close()
+}
+
+func (t *lsmTable[V]) snapshot() (table[V], error) {
+	snapshot, err := t.db.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	view := &lsmView{snapshot: snapshot}
+	view.refs.Store(1)
+
+	return &lsmSnapshot[V]{view: view, count: t.count}, nil
+}
+
+// lsmView is an engine snapshot shared by snapshots of an LSM table.
+type lsmView struct {
+	snapshot *lsm.Snapshot
+	refs     atomic.Int32
+}
+
+type lsmSnapshot[V any] struct {
+	view   *lsmView
+	count  int
+	closed atomic.Bool
+}
+
+func (t *lsmSnapshot[V]) get(key string) (V, bool, error) {
+	var value V
+	data, ok, err := t.view.snapshot.Get(key)
+	if err != nil || !ok {
+		return value, false, err
+	}
+
+	return value, true, json.Unmarshal(data, &value)
+}
+
+func (t *lsmSnapshot[V]) put(string, V) error {
+	return errReadOnlyTable
+}
+
+func (t *lsmSnapshot[V]) delete(string) (bool, error) {
+	return false, errReadOnlyTable
+}
+
+func (t *lsmSnapshot[V]) scan(from string, fn func(key string, value V) bool) error {
+	return lsmScan(t.view.snapshot.Scan, from, fn)
+}
+
+func (t *lsmSnapshot[V]) len() int {
+	return t.count
+}
+
+func (t *lsmSnapshot[V]) snapshot() (table[V], error) {
+	t.view.refs.Add(1)
+	return &lsmSnapshot[V]{view: t.view, count: t.count}, nil
+}
+
+func (t *lsmSnapshot[V]) close() error {
+	if t.closed.CompareAndSwap(false, true) && t.view.refs.Add(-1) == 0 {
+		t.view.snapshot.Close()
+	}
+
+	return nil
+}
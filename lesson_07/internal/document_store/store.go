@@ -1,13 +1,35 @@
 package documentstore
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"lesson_07/internal/btree"
+	"lesson_07/internal/lsm"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
+var ErrInvalidCollectionName = errors.New("invalid collection name")
+var ErrCollectionExists = errors.New("collection already exists")
+var ErrCollectionNotFound = errors.New("collection not found")
+
 type Store struct {
 	Collections map[string]*Collection `json:"collections"`
+
+	mu sync.RWMutex
+	// refMu serializes writes while collections declare references.
+	refMu sync.Mutex
+	db    *btree.DB
+	// migrations are registered per collection in version order.
+	// migrationMu guards them and runs one migration at a time.
+	migrationMu sync.Mutex
+	migrations  map[string][]Migration
 }
 
 func NewStore() *Store {
@@ -18,23 +40,33 @@ func NewStore() *Store {
 	return &store
 }
 
-func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (bool, *Collection) {
-	slog.Debug("CreateCollection", "name", name, "cfg", cfg)
-	// Створюємо нову колекцію і повертаємо `true` якщо колекція була створена
-	// Якщо ж колекція вже створення, то повертаємо `false` та nil
-	if _, exists := s.GetCollection(name); exists {
-		slog.Error("Cannot create collections. Not unique one", "name", name)
-		return false, nil
+func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (*Collection, error) {
+	storeLogger().Debug("CreateCollection", "name", name, "cfg", cfg)
+	// Створюємо нову колекцію і повертаємо її якщо колекція була створена
+	// Якщо ж колекція вже створення, то повертаємо ErrCollectionExists
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.Collections[name]; exists {
+		storeLogger().Error("Cannot create collections. Not unique one", "name", name)
+		return nil, newError(name, "", "", ErrCollectionExists)
 	}
 
-	newCollection := NewCollection(cfg)
-	s.Collections[name] = newCollection
+	newCollection, err := s.openCollection(name, cfg)
+	if err != nil {
+		storeLogger().Error("Cannot create collection", "name", name, "err", err)
+		return nil, newError(name, "", "", err)
+	}
+	s.attach(name, newCollection)
 
-	return true, newCollection
+	return newCollection, nil
 }
 
 func (s *Store) GetCollection(name string) (*Collection, bool) {
-	slog.Debug("GetCollection", "name", name)
+	storeLogger().Debug("GetCollection", "name", name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	if collection, ok := s.Collections[name]; ok {
 		return collection, true
 	}
@@ -42,58 +74,126 @@ func (s *Store) GetCollection(name string) (*Collection, bool) {
 	return nil, false
 }
 
-func (s *Store) DeleteCollection(name string) bool {
-	slog.Debug("DeleteCollection", "name", name)
-	if _, exists := s.GetCollection(name); exists {
+// DeleteCollection drops a collection with its data. It fails with
+// ErrReferenceViolation when documents of other collections keep restricting
+// references to it, and like Collection.Delete when referencing documents
+// cannot follow. The collection is kept when updating referencing documents
+// fails, so the delete can be retried.
+func (s *Store) DeleteCollection(name string) error {
+	storeLogger().Debug("DeleteCollection", "name", name)
+	s.refMu.Lock()
+	defer s.refMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if collection, exists := s.Collections[name]; exists {
+		plan, err := s.planCollectionDelete(name)
+		if err != nil {
+			storeLogger().Error("DeleteCollection: collection is referenced", "name", name, "err", err)
+			return newError(name, "", "", err)
+		}
+		// Referencing documents are updated first, so that the delete can
+		// be retried when some of them fail.
+		if err := plan.apply(context.Background()); err != nil {
+			storeLogger().Error("DeleteCollection: failed to update referencing documents", "name", name, "err", err)
+			return newError(name, "", "", fmt.Errorf("failed to update referencing documents: %w", err))
+		}
+		if err := s.dropCollection(name, collection); err != nil {
+			storeLogger().Error("DeleteCollection: failed to drop collection data", "name", name, "err", err)
+			return newError(name, "", "", fmt.Errorf("failed to drop collection data: %w", err))
+		}
 		delete(s.Collections, name)
-		return true
+		return nil
 	}
 
-	slog.Error("DeleteCollection: Collection does not exists:", "name", name)
+	storeLogger().Error("DeleteCollection: Collection does not exists:", "name", name)
 
-	return false
+	return newError(name, "", "", ErrCollectionNotFound)
 }
 
 func NewStoreFromDump(dump []byte) (*Store, error) {
-	slog.Debug("NewStoreFromDump")
+	return NewStoreFromDumpContext(context.Background(), dump)
+}
+
+// NewStoreFromDumpContext is NewStoreFromDump that gives up when ctx is done.
+func NewStoreFromDumpContext(ctx context.Context, dump []byte) (_ *Store, err error) {
+	persistenceLogger().DebugContext(ctx, "NewStoreFromDump")
+	ctx, o := startPersistence(ctx, OpLoad)
+	defer o.end(&err)
+	o.setSize(len(dump))
 	// Функція повинна створити та проініціалізувати новий `Store`
 	// зі всіма колекціями та даними з вхідного дампу.
-	store := &Store{}
+	store, err := unmarshalStore(ctx, dump)
+	if err != nil {
+		persistenceLogger().ErrorContext(ctx, "Failed to create store for dump:", "err", err)
+		return nil, err
+	}
+
+	return store, nil
+}
 
-	if err := json.Unmarshal(dump, store); err != nil {
-		slog.Error("Failed to create store for dump:", "err", err)
+func unmarshalStore(ctx context.Context, dump []byte) (*Store, error) {
+	var public struct {
+		Collections map[string]json.RawMessage `json:"collections"`
+	}
+	if err := json.Unmarshal(dump, &public); err != nil {
 		return nil, err
 	}
 
+	store := &Store{}
+	if public.Collections != nil {
+		store.Collections = make(map[string]*Collection, len(public.Collections))
+	}
+	for name, data := range public.Collections {
+		collection := &Collection{}
+		if err := collection.unmarshal(ctx, data); err != nil {
+			_ = store.Close()
+			return nil, fmt.Errorf("collection %q: %w", name, err)
+		}
+		store.attach(name, collection)
+	}
+
 	return store, nil
 }
 
 // Dump Методи повинен віддати дамп нашого стору в який включені дані про колекції та документ
+// The dump is produced from a snapshot, so writes may continue meanwhile.
 func (s *Store) Dump() ([]byte, error) {
-	slog.Debug("Dump store")
+	return s.DumpContext(context.Background())
+}
 
-	jsonBytes, err := json.MarshalIndent(s, "", "  ")
+// DumpContext is Dump that gives up when ctx is done.
+func (s *Store) DumpContext(ctx context.Context) ([]byte, error) {
+	persistenceLogger().DebugContext(ctx, "Dump store")
+	snapshot, err := s.Snapshot()
 	if err != nil {
-		slog.Error("Failed to Dump() store:", "err", err)
+		persistenceLogger().ErrorContext(ctx, "Failed to Dump() store:", "err", err)
 		return nil, err
 	}
+	defer snapshot.Close()
 
-	return jsonBytes, nil
+	return snapshot.DumpContext(ctx)
 }
 
 // NewStoreFromFile Значення яке повертає метод `store.Dump()` має без помилок оброблятись функцією `NewStoreFromDump`
 func NewStoreFromFile(filename string) (*Store, error) {
-	slog.Debug("NewStoreFromFile", "filename", filename)
+	return NewStoreFromFileContext(context.Background(), filename)
+}
+
+// NewStoreFromFileContext is NewStoreFromFile that gives up when ctx is
+// done.
+func NewStoreFromFileContext(ctx context.Context, filename string) (*Store, error) {
+	persistenceLogger().DebugContext(ctx, "NewStoreFromFile", "filename", filename)
 	// Робить те ж саме що і функція `NewStoreFromDump`, але сам дамп має діставатись з файлу
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		slog.Error("Failed to read file:", "filename", filename, "err", err)
+		persistenceLogger().ErrorContext(ctx, "Failed to read file:", "filename", filename, "err", err)
 		return nil, err
 	}
 
-	store, err := NewStoreFromDump(data)
+	store, err := NewStoreFromDumpContext(ctx, data)
 	if err != nil {
-		slog.Error("Failed on store from dump creation:", "err", err)
+		persistenceLogger().ErrorContext(ctx, "Failed on store from dump creation:", "err", err)
 		return nil, err
 	}
 
@@ -101,19 +201,216 @@ func NewStoreFromFile(filename string) (*Store, error) {
 }
 
 func (s *Store) DumpToFile(filename string) error {
-	slog.Debug("DumpToFile", "filename", filename)
+	return s.DumpToFileContext(context.Background(), filename)
+}
+
+// DumpToFileContext is DumpToFile that gives up when ctx is done. The file
+// is not written then.
+func (s *Store) DumpToFileContext(ctx context.Context, filename string) error {
+	persistenceLogger().DebugContext(ctx, "DumpToFile", "filename", filename)
 	// Робить те ж саме що і метод `Dump`, але записує у файл замість того щоб повертати сам дамп
-	jsonBytes, err := s.Dump()
+	return writeDumpToFile(ctx, filename, s.DumpContext)
+}
+
+func writeDumpToFile(ctx context.Context, filename string, dump func(ctx context.Context) ([]byte, error)) error {
+	ctx, span := startSpan(ctx, "dump to file", slog.String("file.path", filename))
+	defer span.End()
+
+	jsonBytes, err := dump(ctx)
 	if err != nil {
-		slog.Error("Error on Dump()", "err", err)
+		persistenceLogger().ErrorContext(ctx, "Error on Dump()", "err", err)
+		span.RecordError(err)
 		return err
 	}
 
-	err = os.WriteFile(filename, jsonBytes, 0644)
+	err = writeFileSync(ctx, filename, jsonBytes)
 	if err != nil {
-		slog.Error("Error on WriteFile()", "err", err)
+		persistenceLogger().ErrorContext(ctx, "Error on WriteFile()", "err", err)
+		span.RecordError(err)
 		return err
 	}
 
 	return nil
 }
+
+// writeFileSync is os.WriteFile syncing the file, so that a dump survives a
+// crash once written.
+func writeFileSync(ctx context.Context, filename string, data []byte) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := traceStep(ctx, "fsync", f.Sync); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// OpenStore opens a store backed by a B+tree database file, creating the
+// file if needed. Documents are read from disk on demand instead of being
+// loaded into memory; the store must be closed with Close.
+func OpenStore(filename string) (*Store, error) {
+	persistenceLogger().Debug("OpenStore", "filename", filename)
+	db, err := btree.Open(filename, nil)
+	if err != nil {
+		persistenceLogger().Error("Failed to open database file:", "filename", filename, "err", err)
+		return nil, err
+	}
+
+	store := NewStore()
+	store.db = db
+
+	entries := map[string]catalogEntry{}
+	err = db.View(func(tx *btree.Tx) error {
+		c := tx.Cursor()
+		for k, v := c.Seek([]byte(catalogPrefix)); k != nil && bytes.HasPrefix(k, []byte(catalogPrefix)); k, v = c.Next() {
+			entry := catalogEntry{}
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("invalid config of collection %q: %w", k[len(catalogPrefix):], err)
+			}
+			entries[string(k[len(catalogPrefix):])] = entry
+		}
+		return c.Err()
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	for name, entry := range entries {
+		collection, err := openCollectionTables(db, name, &entry.CollectionConfig)
+		if err != nil {
+			_ = store.Close()
+			return nil, fmt.Errorf("failed to open collection %q: %w", name, err)
+		}
+		if entry.LastWrite != nil {
+			collection.lastWrite = *entry.LastWrite
+		}
+		store.attach(name, collection)
+	}
+
+	return store, nil
+}
+
+// attach adds a collection to the store.
+func (s *Store) attach(name string, collection *Collection) {
+	collection.name = name
+	collection.store = s
+	s.Collections[name] = collection
+}
+
+// Close releases collection engines and the database file of a store opened
+// with OpenStore. The last write times of collections are saved to the file
+// first, see CollectionStats.LastWrite.
+func (s *Store) Close() error {
+	storeLogger().Debug("Close store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	if s.db != nil {
+		errs = append(errs, s.db.Update(func(tx *btree.Tx) error {
+			for _, collection := range s.Collections {
+				collection.mu.RLock()
+				err := collection.saveLastWrite(tx)
+				collection.mu.RUnlock()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}
+	for name, collection := range s.Collections {
+		if err := collection.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("collection %q: %w", name, err))
+		}
+	}
+	if s.db != nil {
+		errs = append(errs, s.db.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Store) openCollection(name string, cfg *CollectionConfig) (*Collection, error) {
+	if s.db == nil {
+		return openCollectionTables(nil, name, cfg)
+	}
+	if name == "" || strings.ContainsRune(name, 0) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCollectionName, name)
+	}
+	data, err := json.Marshal(catalogEntry{CollectionConfig: *cfg})
+	if err != nil {
+		return nil, err
+	}
+
+	// The catalog entry is written last: OpenStore opens every collection
+	// of the catalog, so an entry of a collection failing to open would
+	// prevent opening the store.
+	collection, err := openCollectionTables(s.db, name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Update(func(tx *btree.Tx) error {
+		return tx.Put([]byte(catalogPrefix+name), data)
+	})
+	if err != nil {
+		_ = collection.close()
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// catalogEntry describes a collection in a database file. Entries written
+// before LastWrite was kept hold the config only.
+type catalogEntry struct {
+	CollectionConfig
+	LastWrite *time.Time `json:"lastWrite,omitempty"`
+}
+
+func putCatalogEntry(tx *btree.Tx, name string, entry catalogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return tx.Put([]byte(catalogPrefix+name), data)
+}
+
+// dropCollection removes the data of a deleted collection from its engine.
+func (s *Store) dropCollection(name string, collection *Collection) error {
+	if err := collection.Close(); err != nil {
+		return err
+	}
+	if collection.cfg.Engine == CollectionEngineLSM {
+		if err := lsm.Remove(collection.cfg.LSM.Dir); err != nil {
+			return err
+		}
+	}
+	if s.db == nil {
+		return nil
+	}
+
+	return s.db.Update(func(tx *btree.Tx) error {
+		if documents, ok := collection.documents.(*treeTable[Document]); ok {
+			if err := documents.drop(tx); err != nil {
+				return err
+			}
+		}
+		if history, ok := collection.history.(*treeTable[[]DocumentVersion]); ok {
+			if err := history.drop(tx); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Delete([]byte(catalogPrefix + name))
+		return err
+	})
+}
@@ -3,6 +3,7 @@ package documentstore
 import (
 	"github.com/stretchr/testify/assert"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -16,8 +17,8 @@ func TestStore_CreateCollection(t *testing.T) {
 	t.Run("Should create a new collection", func(t *testing.T) {
 		store := NewStore()
 
-		ok, col := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
-		assert.True(t, ok)
+		col, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		assert.Nil(t, err)
 		assert.NotNil(t, col)
 
 		retrieved, exists := store.GetCollection("users")
@@ -29,8 +30,9 @@ func TestStore_CreateCollection(t *testing.T) {
 		store := NewStore()
 		store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
 
-		ok, col := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
-		assert.False(t, ok)
+		col, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		assert.ErrorIs(t, err, ErrCollectionExists)
+		assert.Equal(t, CodeAlreadyExists, ErrorCodeOf(err))
 		assert.Nil(t, col)
 	})
 }
@@ -57,7 +59,7 @@ func TestDeleteCollection_Success(t *testing.T) {
 		store := NewStore()
 		store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
 
-		store.DeleteCollection("users")
+		assert.Nil(t, store.DeleteCollection("users"))
 
 		_, exists := store.GetCollection("users")
 		assert.False(t, exists)
@@ -66,15 +68,17 @@ func TestDeleteCollection_Success(t *testing.T) {
 	t.Run("Should return not found error", func(t *testing.T) {
 		store := NewStore()
 
-		ok := store.DeleteCollection("nonexistent")
-		assert.False(t, ok)
+		err := store.DeleteCollection("nonexistent")
+		assert.ErrorIs(t, err, ErrCollectionNotFound)
+		assert.ErrorIs(t, err, &Error{Code: CodeNotFound, Collection: "nonexistent"})
 	})
 }
 
 func TestStore_Dump(t *testing.T) {
 	t.Run("Should dump", func(t *testing.T) {
+		useClock(t)
 		store := NewStore()
-		_, collection := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		collection, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
 		collection.Put(Document{
 			Fields: map[string]DocumentField{
 				"id": {
@@ -108,7 +112,8 @@ func TestStore_Dump(t *testing.T) {
             }
           }
         }
-      }
+      },
+      "lastWrite": "2024-01-01T12:00:00Z"
     }
   }
 }`
@@ -119,7 +124,7 @@ func TestStore_Dump(t *testing.T) {
 func TestStore_NewStoreFromDump(t *testing.T) {
 	t.Run("Should create a new store from dump", func(t *testing.T) {
 		store := NewStore()
-		_, collection := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		collection, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
 		collection.Put(Document{
 			Fields: map[string]DocumentField{
 				"id": {
@@ -150,8 +155,9 @@ func TestStore_DumpToFile(t *testing.T) {
 		tmpFile := "store_test_dump.json"
 		defer os.Remove(tmpFile)
 
+		useClock(t)
 		store := NewStore()
-		_, collection := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		collection, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
 		collection.Put(Document{
 			Fields: map[string]DocumentField{
 				"id": {
@@ -188,7 +194,8 @@ func TestStore_DumpToFile(t *testing.T) {
             }
           }
         }
-      }
+      },
+      "lastWrite": "2024-01-01T12:00:00Z"
     }
   }
 }`
@@ -201,7 +208,7 @@ func TestStore_NewStoreFromFile(t *testing.T) {
 		tmpFile := "store_test_dump.json"
 		defer os.Remove(tmpFile)
 		store := NewStore()
-		_, collection := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		collection, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
 		collection.Put(Document{
 			Fields: map[string]DocumentField{
 				"id": {
@@ -226,3 +233,170 @@ func TestStore_NewStoreFromFile(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestStore_OpenStore(t *testing.T) {
+	t.Run("Should persist collections and documents in database file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "store.db")
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		collection, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		for _, id := range []string{"b", "a", "c"} {
+			_, err := collection.Put(Document{
+				Fields: map[string]DocumentField{
+					"id":   {Type: DocumentFieldTypeString, Value: id},
+					"name": {Type: DocumentFieldTypeString, Value: "John Doe"},
+				},
+			})
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, collection.Delete("c"))
+		assert.Nil(t, store.Close())
+
+		store2, err := OpenStore(filename)
+		assert.Nil(t, err)
+		defer store2.Close()
+
+		collection2, exists := store2.GetCollection("users")
+		assert.True(t, exists)
+		assert.Equal(t, "id", collection2.cfg.PrimaryKey)
+		assert.Equal(t, 2, collection2.documents.len())
+
+		docs := collection2.List()
+		assert.Equal(t, "a", docs[0].GetField("id"))
+		assert.Equal(t, "b", docs[1].GetField("id"))
+
+		_, err = collection2.Get("c")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+	})
+
+	t.Run("Should drop collection data on delete", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "store.db")
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		collection, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		collection.Put(Document{
+			Fields: map[string]DocumentField{
+				"id": {Type: DocumentFieldTypeString, Value: "a"},
+			},
+		})
+		assert.Nil(t, store.DeleteCollection("users"))
+		collection, _ = store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		assert.Empty(t, collection.List())
+		assert.Nil(t, store.Close())
+
+		store2, err := OpenStore(filename)
+		assert.Nil(t, err)
+		defer store2.Close()
+		collection2, _ := store2.GetCollection("users")
+		assert.Empty(t, collection2.List())
+	})
+
+	t.Run("Should open after a rejected collection", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "store.db")
+		store, err := OpenStore(filename)
+		assert.Nil(t, err)
+		_, err = store.CreateCollection("events", &CollectionConfig{PrimaryKey: "id", Engine: CollectionEngineLSM})
+		assert.Error(t, err)
+		_, err = store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		assert.Nil(t, err)
+		assert.Nil(t, store.Close())
+
+		store2, err := OpenStore(filename)
+		assert.Nil(t, err)
+		defer store2.Close()
+		_, exists := store2.GetCollection("events")
+		assert.False(t, exists)
+		_, exists = store2.GetCollection("users")
+		assert.True(t, exists)
+	})
+
+	t.Run("Should dump file backed store", func(t *testing.T) {
+		store, err := OpenStore(filepath.Join(t.TempDir(), "store.db"))
+		assert.Nil(t, err)
+		defer store.Close()
+		collection, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		collection.Put(Document{
+			Fields: map[string]DocumentField{
+				"id": {Type: DocumentFieldTypeString, Value: "a"},
+			},
+		})
+
+		dump, err := store.Dump()
+		assert.Nil(t, err)
+		store2, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+		collection2, _ := store2.GetCollection("users")
+		assert.Len(t, collection2.List(), 1)
+	})
+}
+
+func TestStore_LSMCollection(t *testing.T) {
+	t.Run("Should keep LSM collection in its directory", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := &CollectionConfig{PrimaryKey: "id", Engine: CollectionEngineLSM, LSM: &LSMConfig{Dir: filepath.Join(dir, "events")}}
+
+		store, err := OpenStore(filepath.Join(dir, "store.db"))
+		assert.Nil(t, err)
+		collection, err := store.CreateCollection("events", cfg)
+		assert.Nil(t, err)
+		_, err = collection.Put(Document{
+			Fields: map[string]DocumentField{
+				"id": {Type: DocumentFieldTypeString, Value: "a"},
+			},
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, store.Close())
+
+		store2, err := OpenStore(filepath.Join(dir, "store.db"))
+		assert.Nil(t, err)
+		collection2, exists := store2.GetCollection("events")
+		assert.True(t, exists)
+		assert.Equal(t, CollectionEngineLSM, collection2.cfg.Engine)
+		assert.Len(t, collection2.List(), 1)
+
+		assert.Nil(t, store2.DeleteCollection("events"))
+		_, err = os.Stat(filepath.Join(dir, "events"))
+		assert.True(t, os.IsNotExist(err))
+		assert.Nil(t, store2.Close())
+	})
+
+	t.Run("Should keep other files of the LSM directory on delete", func(t *testing.T) {
+		dir := t.TempDir()
+		store := NewStore()
+		_, err := store.CreateCollection("events", &CollectionConfig{PrimaryKey: "id", Engine: CollectionEngineLSM, LSM: &LSMConfig{Dir: dir}})
+		assert.Nil(t, err)
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep"), 0644))
+
+		assert.Nil(t, store.DeleteCollection("events"))
+		files, err := os.ReadDir(dir)
+		assert.Nil(t, err)
+		assert.Len(t, files, 1)
+		assert.Equal(t, "notes.txt", files[0].Name())
+	})
+
+	t.Run("Should reload a dump into the same LSM directory", func(t *testing.T) {
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "dump.json")
+		store := NewStore()
+		collection, err := store.CreateCollection("events", &CollectionConfig{PrimaryKey: "id", Engine: CollectionEngineLSM, LSM: &LSMConfig{Dir: filepath.Join(dir, "events")}})
+		assert.Nil(t, err)
+		collection.Put(userDocument("a", "John"))
+		collection.Put(userDocument("b", "Jane"))
+		assert.Nil(t, store.DumpToFile(filename))
+		collection.Put(userDocument("c", "Jack"))
+		assert.Nil(t, collection.Delete("a"))
+		assert.Nil(t, store.Close())
+
+		restored, err := NewStoreFromFile(filename)
+		assert.Nil(t, err)
+		collection, _ = restored.GetCollection("events")
+		assert.Equal(t, []Document{userDocument("a", "John"), userDocument("b", "Jane")}, collection.List())
+		assert.Nil(t, restored.Close())
+
+		restored, err = NewStoreFromFile(filename)
+		assert.Nil(t, err)
+		defer restored.Close()
+		collection, _ = restored.GetCollection("events")
+		assert.Len(t, collection.List(), 2)
+	})
+}
@@ -0,0 +1,293 @@
+package documentstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tracer starts spans around operations of the store, see SetTracer. It
+// mirrors the OpenTelemetry tracing API, so that an OpenTelemetry tracer is
+// adapted in a few lines; WriterTracer exports spans without dependencies.
+type Tracer interface {
+	// Start starts a span, a child of the span of ctx if any, and returns
+	// a context carrying it.
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span is an operation traced by a Tracer.
+type Span interface {
+	SetAttributes(attrs ...slog.Attr)
+	// RecordError marks the span as failed.
+	RecordError(err error)
+	End()
+}
+
+// Span attributes follow the OpenTelemetry semantic conventions for
+// databases where they apply.
+const (
+	AttrDBSystem     = "db.system"
+	AttrCollection   = "db.collection.name"
+	AttrOperation    = "db.operation.name"
+	AttrReturnedRows = "db.response.returned_rows"
+	AttrErrorType    = "error.type"
+	// AttrSize is the size in bytes of a dump or a load.
+	AttrSize = "documentstore.size"
+	// AttrIndex names the indexes used or updated, "text" and "geo"
+	// separated by commas.
+	AttrIndex = "documentstore.index"
+	// AttrEngine is the engine of a persisted collection.
+	AttrEngine = "documentstore.engine"
+)
+
+var tracer atomic.Pointer[Tracer]
+
+// SetTracer makes every store and collection trace its operations with t.
+// A nil t stops tracing.
+func SetTracer(t Tracer) {
+	if t == nil {
+		tracer.Store(nil)
+		return
+	}
+	tracer.Store(&t)
+}
+
+// startSpan starts a span with the tracer set by SetTracer, or a span doing
+// nothing.
+func startSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	if t := tracer.Load(); t != nil {
+		return (*t).Start(ctx, name, attrs...)
+	}
+
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...slog.Attr) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
+
+// operation is an operation in progress, traced and measured for metrics.
+type operation struct {
+	ctx        context.Context
+	collection *Collection
+	op         Operation
+	start      time.Time
+	span       Span
+	size       int
+	// filter, scanned and returned describe queries for the slow log;
+	// counts are negative when unknown.
+	filter       string
+	scannedDocs  int
+	returnedDocs int
+}
+
+// startOperation starts an operation on the collection. The returned
+// context carries its span for the steps of the operation. end must be
+// deferred with the named error result.
+func (s *Collection) startOperation(ctx context.Context, op Operation) (context.Context, *operation) {
+	name := string(op)
+	if s.name != "" {
+		name += " " + s.name
+	}
+	ctx, span := startSpan(ctx, name,
+		slog.String(AttrDBSystem, "documentstore"),
+		slog.String(AttrCollection, s.name),
+		slog.String(AttrOperation, string(op)))
+
+	return ctx, &operation{ctx: ctx, collection: s, op: op, start: time.Now(), span: span, scannedDocs: -1, returnedDocs: -1}
+}
+
+// startPersistence starts a dump or a load; its size is set with setSize.
+func startPersistence(ctx context.Context, op Operation) (context.Context, *operation) {
+	ctx, span := startSpan(ctx, string(op),
+		slog.String(AttrDBSystem, "documentstore"),
+		slog.String(AttrOperation, string(op)))
+
+	return ctx, &operation{ctx: ctx, op: op, start: time.Now(), span: span, scannedDocs: -1, returnedDocs: -1}
+}
+
+// returned records the number of documents the operation returned.
+func (o *operation) returned(n int) {
+	o.returnedDocs = n
+	o.span.SetAttributes(slog.Int(AttrReturnedRows, n))
+}
+
+// scanned records the number of documents the operation read.
+func (o *operation) scanned(n int) {
+	o.scannedDocs = n
+}
+
+// query records the filter and the documents read by an aggregation.
+func (o *operation) query(plan *QueryPlan) {
+	if plan != nil {
+		o.filter = plan.Filter
+		o.scanned(plan.Scanned)
+	}
+}
+
+func (o *operation) setSize(size int) {
+	o.size = size
+	o.span.SetAttributes(slog.Int(AttrSize, size))
+}
+
+func (o *operation) end(err *error) {
+	duration := time.Since(o.start)
+	if *err != nil {
+		o.span.SetAttributes(slog.String(AttrErrorType, string(ErrorCodeOf(*err))))
+		o.span.RecordError(*err)
+	}
+	o.span.End()
+	o.logSlow(duration, *err)
+
+	m := currentMetrics()
+	switch {
+	case m == nil:
+	case o.collection != nil:
+		m.ObserveOperation(o.collection.name, o.op, duration, *err)
+	default:
+		m.ObservePersistence(o.op, duration, o.size, *err)
+	}
+}
+
+// traceStep runs a step of an operation, such as validation, in a child span
+// of the span of ctx.
+func traceStep(ctx context.Context, name string, fn func() error, attrs ...slog.Attr) error {
+	_, span := startSpan(ctx, name, attrs...)
+	defer span.End()
+
+	err := fn()
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	return err
+}
+
+// startIndexSpan starts the span of an index lookup or update.
+func startIndexSpan(ctx context.Context, index string) Span {
+	_, span := startSpan(ctx, "index", slog.String(AttrIndex, index))
+	return span
+}
+
+// tableEngine names the engine of a table for AttrEngine.
+func tableEngine[V any](t table[V]) string {
+	switch t.(type) {
+	case *memoryTable[V]:
+		return "memory"
+	case *treeTable[V]:
+		return "btree"
+	case *lsmTable[V]:
+		return "lsm"
+	default:
+		return "unknown"
+	}
+}
+
+// WriterTracer is a Tracer writing finished spans as JSON lines, e.g. to
+// os.Stdout or a file, for local debugging and tests. Lines are SpanData.
+type WriterTracer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewWriterTracer(w io.Writer) *WriterTracer {
+	return &WriterTracer{enc: json.NewEncoder(w)}
+}
+
+// SpanData is a finished span written by WriterTracer. Field names and
+// status codes follow the OpenTelemetry span model.
+type SpanData struct {
+	TraceID      string                 `json:"traceId"`
+	SpanID       string                 `json:"spanId"`
+	ParentSpanID string                 `json:"parentSpanId,omitempty"`
+	Name         string                 `json:"name"`
+	StartTime    time.Time              `json:"startTime"`
+	EndTime      time.Time              `json:"endTime"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Status       SpanStatus             `json:"status"`
+}
+
+type SpanStatus struct {
+	// Code is "Unset" or "Error".
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type writerSpanKey struct{}
+
+type writerSpan struct {
+	tracer *WriterTracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+func (t *WriterTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	span := &writerSpan{tracer: t, data: SpanData{
+		SpanID:    randomID(8),
+		Name:      name,
+		StartTime: time.Now(),
+		Status:    SpanStatus{Code: "Unset"},
+	}}
+	if parent, ok := ctx.Value(writerSpanKey{}).(*writerSpan); ok {
+		span.data.TraceID = parent.data.TraceID
+		span.data.ParentSpanID = parent.data.SpanID
+	} else {
+		span.data.TraceID = randomID(16)
+	}
+	span.SetAttributes(attrs...)
+
+	return context.WithValue(ctx, writerSpanKey{}, span), span
+}
+
+func (s *writerSpan) SetAttributes(attrs ...slog.Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, attr := range attrs {
+		if s.data.Attributes == nil {
+			s.data.Attributes = map[string]interface{}{}
+		}
+		s.data.Attributes[attr.Key] = attr.Value.Resolve().Any()
+	}
+}
+
+func (s *writerSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Status = SpanStatus{Code: "Error", Message: err.Error()}
+}
+
+func (s *writerSpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	if err := s.tracer.enc.Encode(data); err != nil {
+		collectionLogger().Error("Failed to export span", "name", data.Name, "err", err)
+	}
+}
+
+func randomID(n int) string {
+	id := make([]byte, n)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,105 @@
+package documentstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func useTracer(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	SetTracer(NewWriterTracer(&buf))
+	t.Cleanup(func() { SetTracer(nil) })
+
+	return &buf
+}
+
+func readSpans(t *testing.T, buf *bytes.Buffer) map[string]SpanData {
+	spans := map[string]SpanData{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var span SpanData
+		assert.Nil(t, json.Unmarshal([]byte(line), &span))
+		spans[span.Name] = span
+	}
+	buf.Reset()
+
+	return spans
+}
+
+func TestTracing(t *testing.T) {
+	t.Run("Should trace steps of a write", func(t *testing.T) {
+		store := NewStore()
+		articles, _ := store.CreateCollection("articles", &CollectionConfig{PrimaryKey: "id", TextIndex: &TextIndexConfig{Fields: []string{"title"}}})
+		buf := useTracer(t)
+
+		_, err := articles.Put(articleDocument("1", "Go tracing"))
+		assert.Nil(t, err)
+
+		spans := readSpans(t, buf)
+		put := spans["put articles"]
+		assert.Equal(t, map[string]interface{}{
+			AttrDBSystem:   "documentstore",
+			AttrCollection: "articles",
+			AttrOperation:  "put",
+		}, put.Attributes)
+		assert.Equal(t, "Unset", put.Status.Code)
+		assert.Empty(t, put.ParentSpanID)
+		assert.Len(t, put.TraceID, 32)
+		assert.Len(t, put.SpanID, 16)
+		assert.False(t, put.EndTime.Before(put.StartTime))
+
+		for _, name := range []string{"validate", "persist", "index"} {
+			assert.Equal(t, put.TraceID, spans[name].TraceID, name)
+			assert.Equal(t, put.SpanID, spans[name].ParentSpanID, name)
+		}
+		assert.Equal(t, "memory", spans["persist"].Attributes[AttrEngine])
+		assert.Equal(t, "text", spans["index"].Attributes[AttrIndex])
+	})
+
+	t.Run("Should trace queries with their result size", func(t *testing.T) {
+		_, users := newUsersStore(t)
+		articles := NewCollection(&CollectionConfig{PrimaryKey: "id", TextIndex: &TextIndexConfig{Fields: []string{"title"}}})
+		articles.Put(articleDocument("1", "Go tracing"))
+		buf := useTracer(t)
+
+		users.List()
+		spans := readSpans(t, buf)
+		assert.Equal(t, float64(2), spans["list users"].Attributes[AttrReturnedRows])
+
+		_, err := articles.Search("tracing", SearchOptions{})
+		assert.Nil(t, err)
+		spans = readSpans(t, buf)
+		assert.Equal(t, float64(1), spans["search"].Attributes[AttrReturnedRows])
+		assert.Equal(t, spans["search"].SpanID, spans["index"].ParentSpanID)
+	})
+
+	t.Run("Should record errors", func(t *testing.T) {
+		_, users := newUsersStore(t)
+		buf := useTracer(t)
+
+		_, err := users.Get("9")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+
+		get := readSpans(t, buf)["get users"]
+		assert.Equal(t, "Error", get.Status.Code)
+		assert.Equal(t, err.Error(), get.Status.Message)
+		assert.Equal(t, string(CodeNotFound), get.Attributes[AttrErrorType])
+	})
+
+	t.Run("Should trace dumps with fsync", func(t *testing.T) {
+		store, _ := newUsersStore(t)
+		buf := useTracer(t)
+
+		assert.Nil(t, store.DumpToFile(filepath.Join(t.TempDir(), "dump.json")))
+
+		spans := readSpans(t, buf)
+		file := spans["dump to file"]
+		assert.Contains(t, file.Attributes["file.path"], "dump.json")
+		assert.Equal(t, file.SpanID, spans["dump"].ParentSpanID)
+		assert.Equal(t, file.SpanID, spans["fsync"].ParentSpanID)
+		assert.Greater(t, spans["dump"].Attributes[AttrSize], float64(0))
+	})
+}
@@ -0,0 +1,184 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+var ErrInvalidSweepInterval = errors.New("invalid sweep interval")
+
+func (d *Document) expired(at time.Time) bool {
+	return d.ExpiresAt != nil && !d.ExpiresAt.After(at)
+}
+
+// RemoveExpired deletes expired documents from storage and returns how many
+// were removed. Expired documents are already invisible to reads; this only
+// reclaims their space. In a store the delete actions of references to them
+// apply like for Delete: referencing documents are deleted or set to null,
+// and a restricting reference keeps an expired document stored, still
+// invisible, until the reference is removed.
+func (s *Collection) RemoveExpired() (int, error) {
+	collectionLogger().Debug("Remove expired documents")
+	if err := s.writable(); err != nil {
+		return 0, err
+	}
+
+	release := s.lockReferences()
+	defer release()
+
+	s.mu.Lock()
+	at := now()
+	var expired []string
+	err := s.documents.scan("", func(key string, doc Document) bool {
+		if doc.expired(at) {
+			expired = append(expired, key)
+		}
+		return true
+	})
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	referenced := s.store != nil && s.store.hasReferences()
+	removed := 0
+	var errs []error
+	for _, key := range expired {
+		ok, err := s.expire(key, referenced)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if ok {
+			removed++
+		}
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// expire removes a document if it is expired, applying the delete actions
+// of references to it when referenced is set. Must be called under
+// lockReferences.
+func (s *Collection) expire(key string, referenced bool) (removed bool, err error) {
+	var plan *deletePlan
+	if referenced {
+		if plan, err = s.store.planDocumentDelete(s.name, key); err != nil {
+			return false, s.docError(key, "", err)
+		}
+	}
+
+	s.mu.Lock()
+	doc, found, err := s.documents.get(key)
+	if err != nil {
+		s.mu.Unlock()
+		return false, s.docError(key, "", fmt.Errorf("failed to read document: %w", err))
+	}
+	if !found || !doc.expired(now()) {
+		s.mu.Unlock()
+		return false, nil
+	}
+	err = s.removeExpired(key, doc)
+	s.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	if plan != nil {
+		if err := plan.apply(context.Background()); err != nil {
+			return true, s.docError(key, "", fmt.Errorf("failed to update referencing documents: %w", err))
+		}
+	}
+
+	return true, nil
+}
+
+// removeExpired deletes an expired document. Its history records the delete
+// at the moment it expired. Must be called with s.mu held.
+func (s *Collection) removeExpired(key string, doc Document) error {
+	undo, err := s.recordVersionAt(key, nil, *doc.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if _, err := s.documents.delete(key); err != nil {
+		undo()
+		return s.docError(key, "", fmt.Errorf("failed to remove expired document: %w", err))
+	}
+	s.updateIndexes(key, &doc, nil)
+
+	return nil
+}
+
+// RemoveExpired deletes expired documents from every collection of the store.
+func (s *Store) RemoveExpired() (int, error) {
+	storeLogger().Debug("Remove expired documents from store")
+	// Collections take the lock of references, which comes before s.mu.
+	s.mu.RLock()
+	collections := maps.Clone(s.Collections)
+	s.mu.RUnlock()
+
+	removed := 0
+	var errs []error
+	for name, collection := range collections {
+		n, err := collection.RemoveExpired()
+		removed += n
+		if err != nil {
+			errs = append(errs, newError(name, "", "", err))
+		}
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// Sweeper periodically removes expired documents of a store in the
+// background.
+type Sweeper struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// StartSweeper runs Store.RemoveExpired every interval until the returned
+// sweeper is stopped. It fails with ErrInvalidSweepInterval unless interval
+// is positive.
+func (s *Store) StartSweeper(interval time.Duration) (*Sweeper, error) {
+	storeLogger().Debug("Start sweeper", "interval", interval)
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSweepInterval, interval)
+	}
+
+	sweeper := &Sweeper{stop: make(chan struct{}), done: make(chan struct{})}
+	go sweeper.run(s, interval)
+
+	return sweeper, nil
+}
+
+func (sw *Sweeper) run(store *Store, interval time.Duration) {
+	defer close(sw.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sw.stop:
+			return
+		case <-ticker.C:
+			if removed, err := store.RemoveExpired(); err != nil {
+				storeLogger().Error("Sweeper failed to remove expired documents", "err", err)
+			} else if removed > 0 {
+				storeLogger().Debug("Sweeper removed expired documents", "count", removed)
+			}
+		}
+	}
+}
+
+// Stop stops the sweeper and waits for a running sweep to finish. It is safe
+// to call more than once.
+func (sw *Sweeper) Stop() {
+	sw.once.Do(func() { close(sw.stop) })
+	<-sw.done
+}
@@ -0,0 +1,185 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func expiringDocument(id string, expiresAt time.Time) Document {
+	doc := userDocument(id, "session")
+	doc.ExpiresAt = &expiresAt
+	return doc
+}
+
+func TestCollection_TTL(t *testing.T) {
+	t.Run("Should hide expired documents", func(t *testing.T) {
+		start, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		collection.Put(expiringDocument("1", start.Add(time.Minute)))
+		collection.Put(userDocument("2", "John"))
+
+		_, err := collection.Get("1")
+		assert.Nil(t, err)
+		assert.Len(t, collection.List(), 2)
+
+		advance(time.Minute)
+		_, err = collection.Get("1")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+		assert.Len(t, collection.List(), 1)
+		assert.Equal(t, 2, collection.documents.len())
+	})
+
+	t.Run("Should apply default TTL", func(t *testing.T) {
+		start, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", DefaultTTL: time.Hour})
+		doc, err := collection.Put(userDocument("1", "John"))
+		assert.Nil(t, err)
+		assert.Equal(t, start.Add(time.Hour), *doc.ExpiresAt)
+
+		collection.Put(expiringDocument("2", start.Add(2*time.Hour)))
+		advance(time.Hour)
+		_, err = collection.Get("1")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+		_, err = collection.Get("2")
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should allow reusing key of expired document", func(t *testing.T) {
+		start, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		collection.Put(expiringDocument("1", start.Add(time.Minute)))
+		advance(time.Minute)
+
+		_, err := collection.Replace(userDocument("1", "John"))
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+		_, err = collection.Put(userDocument("1", "John"))
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should report expired document as not deleted", func(t *testing.T) {
+		start, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		collection.Put(expiringDocument("1", start.Add(time.Minute)))
+		advance(time.Minute)
+
+		assert.Error(t, collection.Delete("1"))
+		assert.Equal(t, 0, collection.documents.len())
+	})
+
+	t.Run("Should remove expired documents", func(t *testing.T) {
+		start, advance := useClock(t)
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", History: &HistoryConfig{}})
+		collection.Put(expiringDocument("1", start.Add(time.Minute)))
+		collection.Put(expiringDocument("2", start.Add(time.Hour)))
+		advance(10 * time.Minute)
+
+		removed, err := collection.RemoveExpired()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, removed)
+		assert.Equal(t, 1, collection.documents.len())
+
+		versions, _ := collection.History("1")
+		assert.Len(t, versions, 2)
+		assert.True(t, versions[1].Deleted)
+		assert.Equal(t, start.Add(time.Minute), versions[1].Timestamp)
+
+		_, err = collection.GetAsOf("1", start.Add(30*time.Second))
+		assert.Nil(t, err)
+	})
+}
+
+func TestStore_TTL(t *testing.T) {
+	t.Run("Should keep expiry in dump", func(t *testing.T) {
+		start, advance := useClock(t)
+		store := NewStore()
+		sessions, _ := store.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", DefaultTTL: time.Minute})
+		sessions.Put(userDocument("1", "session"))
+
+		dump, err := store.Dump()
+		assert.Nil(t, err)
+		restored, err := NewStoreFromDump(dump)
+		assert.Nil(t, err)
+		assert.Equal(t, store, restored)
+
+		restoredSessions, _ := restored.GetCollection("sessions")
+		doc, err := restoredSessions.Get("1")
+		assert.Nil(t, err)
+		assert.Equal(t, start.Add(time.Minute), *doc.ExpiresAt)
+
+		advance(time.Minute)
+		_, err = restoredSessions.Get("1")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+	})
+
+	t.Run("Should apply delete actions of references to expired documents", func(t *testing.T) {
+		_, advance := useClock(t)
+		store := NewStore()
+		sessions, _ := store.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", DefaultTTL: time.Minute})
+		events, _ := store.CreateCollection("events", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "session", Collection: "sessions", OnDelete: ReferenceCascade}},
+		})
+		audits, _ := store.CreateCollection("audits", &CollectionConfig{
+			PrimaryKey: "id",
+			References: []ReferenceConfig{{Field: "session", Collection: "sessions"}},
+		})
+		for _, id := range []string{"1", "2"} {
+			sessions.Put(userDocument(id, "session"))
+			event := userDocument("e"+id, "login")
+			event.Fields["session"] = newField(id)
+			_, err := events.Put(event)
+			assert.Nil(t, err)
+		}
+		audit := userDocument("a2", "audit")
+		audit.Fields["session"] = newField("2")
+		_, err := audits.Put(audit)
+		assert.Nil(t, err)
+		advance(time.Hour)
+
+		removed, err := store.RemoveExpired()
+		assert.Equal(t, 1, removed)
+		assert.ErrorIs(t, err, ErrReferenceViolation)
+		assert.ErrorIs(t, err, &Error{Collection: "sessions", ID: "2"})
+		assert.Equal(t, 1, sessions.documents.len())
+		remaining := events.List()
+		assert.Len(t, remaining, 1)
+		assert.Equal(t, "e2", remaining[0].GetField("id"))
+
+		assert.Nil(t, audits.Delete("a2"))
+		removed, err = store.RemoveExpired()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, removed)
+		assert.Empty(t, events.List())
+	})
+
+	t.Run("Should sweep in background until stopped", func(t *testing.T) {
+		store := NewStore()
+		sessions, _ := store.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", DefaultTTL: 10 * time.Millisecond})
+		sessions.Put(userDocument("1", "session"))
+
+		sweeper, err := store.StartSweeper(5 * time.Millisecond)
+		assert.Nil(t, err)
+		assert.Eventually(t, func() bool {
+			sessions.mu.RLock()
+			defer sessions.mu.RUnlock()
+			return sessions.documents.len() == 0
+		}, time.Second, 5*time.Millisecond)
+
+		sweeper.Stop()
+		sweeper.Stop()
+
+		sessions.Put(userDocument("2", "session"))
+		time.Sleep(30 * time.Millisecond)
+		assert.Equal(t, 1, sessions.documents.len())
+	})
+
+	t.Run("Should reject intervals that are not positive", func(t *testing.T) {
+		store := NewStore()
+		for _, interval := range []time.Duration{0, -time.Second} {
+			sweeper, err := store.StartSweeper(interval)
+			assert.ErrorIs(t, err, ErrInvalidSweepInterval)
+			assert.Nil(t, sweeper)
+		}
+	})
+}
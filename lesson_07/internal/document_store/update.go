@@ -0,0 +1,219 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidUpdate = errors.New("invalid update")
+
+// Update changes fields of a stored document in place of replacing it.
+// Paths are dotted and may address array elements by index, e.g.
+// "address.city" or "tags.0". Operators are applied in the order of the
+// fields below; the primary key cannot be changed. Only Set and Unset may
+// change the type of an existing value.
+type Update struct {
+	// Set assigns values, creating missing objects on the way.
+	Set map[string]interface{}
+	// Unset removes fields. Array elements are set to null instead.
+	Unset []string
+	// Inc adds to numeric values. A missing value counts as zero.
+	Inc map[string]float64
+	// Min and Max assign values that are less, respectively greater, than
+	// the current ones, or to missing and null values. Numbers compare with
+	// decimals; other values compare with values of their own type only.
+	Min map[string]interface{}
+	Max map[string]interface{}
+	// Push appends values to arrays, creating missing ones.
+	Push map[string][]interface{}
+	// AddToSet appends the values that arrays do not hold yet, creating
+	// missing ones.
+	AddToSet map[string][]interface{}
+	// CurrentDate sets datetimes, or missing values, to the current time.
+	CurrentDate []string
+}
+
+// apply returns doc with the update applied. Paths of an operator are
+// applied in sorted order, so a parent is set before its children. Every
+// value set is validated like a field of a document.
+func (u Update) apply(doc Document, primaryKey string) (Document, error) {
+	set := func(path string, value interface{}) error {
+		if err := validateValue(GetValueType(value), value); err != nil {
+			return newError("", "", path, err)
+		}
+		var ok bool
+		if doc, ok = setPath(doc, path, value); !ok {
+			return newError("", "", path, fmt.Errorf("%w: cannot set path", ErrInvalidUpdate))
+		}
+		return nil
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(u.Set)) {
+		if err := checkUpdatePath(path, primaryKey); err != nil {
+			return doc, err
+		}
+		if err := set(path, u.Set[path]); err != nil {
+			return doc, err
+		}
+	}
+
+	for _, path := range u.Unset {
+		if err := checkUpdatePath(path, primaryKey); err != nil {
+			return doc, err
+		}
+		doc, _ = unsetPath(doc, path)
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(u.Inc)) {
+		if err := checkUpdatePath(path, primaryKey); err != nil {
+			return doc, err
+		}
+		current, ok := lookupPath(doc, path)
+		value, isNumber := toNumber(current)
+		if ok && !isNumber {
+			return doc, newError("", "", path, fmt.Errorf("%w: cannot increment %T", ErrInvalidUpdate, current))
+		}
+		if err := set(path, value+u.Inc[path]); err != nil {
+			return doc, err
+		}
+	}
+
+	for _, bound := range []struct {
+		values map[string]interface{}
+		sign   int
+	}{{u.Min, -1}, {u.Max, 1}} {
+		for _, path := range slices.Sorted(maps.Keys(bound.values)) {
+			if err := checkUpdatePath(path, primaryKey); err != nil {
+				return doc, err
+			}
+			value := bound.values[path]
+			current, ok := lookupPath(doc, path)
+			if ok && current != nil {
+				if valueRank(current) != valueRank(value) {
+					return doc, newError("", "", path, fmt.Errorf("%w: cannot compare %s with %s", ErrInvalidUpdate, GetValueType(current), GetValueType(value)))
+				}
+				if compareValues(value, current) != bound.sign {
+					continue
+				}
+			}
+			if err := set(path, value); err != nil {
+				return doc, err
+			}
+		}
+	}
+
+	for _, appended := range []struct {
+		values map[string][]interface{}
+		unique bool
+	}{{u.Push, false}, {u.AddToSet, true}} {
+		for _, path := range slices.Sorted(maps.Keys(appended.values)) {
+			if err := checkUpdatePath(path, primaryKey); err != nil {
+				return doc, err
+			}
+			current, ok := lookupPath(doc, path)
+			// normalizeContainer returns a copy of arrays.
+			items, isArray := normalizeContainer(current).([]interface{})
+			if ok && !isArray {
+				return doc, newError("", "", path, fmt.Errorf("%w: cannot append to %s", ErrInvalidUpdate, GetValueType(current)))
+			}
+			for _, value := range appended.values[path] {
+				if err := validateValue(GetValueType(value), value); err != nil {
+					return doc, newError("", "", path+"."+strconv.Itoa(len(items)), err)
+				}
+				contains := func(item interface{}) bool { return valuesEqual(item, value) }
+				if !appended.unique || !slices.ContainsFunc(items, contains) {
+					items = append(items, value)
+				}
+			}
+			if err := set(path, items); err != nil {
+				return doc, err
+			}
+		}
+	}
+
+	at := now().UTC().Round(0)
+	for _, path := range u.CurrentDate {
+		if err := checkUpdatePath(path, primaryKey); err != nil {
+			return doc, err
+		}
+		if current, ok := lookupPath(doc, path); ok && current != nil && GetValueType(current) != DocumentFieldTypeDateTime {
+			return doc, newError("", "", path, fmt.Errorf("%w: cannot set the current date of %s", ErrInvalidUpdate, GetValueType(current)))
+		}
+		if err := set(path, at); err != nil {
+			return doc, err
+		}
+	}
+
+	return doc, nil
+}
+
+func checkUpdatePath(path string, primaryKey string) error {
+	if name, _, _ := strings.Cut(path, "."); name == primaryKey {
+		return newError("", "", path, fmt.Errorf("%w: primary key cannot be updated", ErrInvalidUpdate))
+	}
+
+	return nil
+}
+
+// Update applies update to an existing document and returns the result. The
+// updated document is validated like a replacement.
+func (s *Collection) Update(key string, update Update) (*Document, error) {
+	return s.UpdateContext(context.Background(), key, update)
+}
+
+// UpdateContext is Update that gives up when ctx is done before the document
+// is written.
+func (s *Collection) UpdateContext(ctx context.Context, key string, update Update) (_ *Document, err error) {
+	collectionLogger().DebugContext(ctx, "Update document", "key", key, "update", update)
+	ctx, o := s.startOperation(ctx, OpUpdate)
+	defer o.end(&err)
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+
+	release := s.lockReferences()
+	defer release()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// References are checked before locking, like for Put. Writes are
+	// serialized by lockReferences then, so the document does not change
+	// in between.
+	if s.store != nil && len(s.cfg.References) > 0 {
+		s.mu.RLock()
+		current, err := s.get(key)
+		s.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update.apply(*current, s.cfg.PrimaryKey)
+		if err != nil {
+			return nil, s.docError(key, "", err)
+		}
+		if err := s.checkReferences(updated); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	current, err := s.get(key)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := update.apply(*current, s.cfg.PrimaryKey)
+	if err != nil {
+		return nil, s.docError(key, "", err)
+	}
+
+	return s.write(ctx, key, updated)
+}
@@ -0,0 +1,162 @@
+package documentstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCollection_Update(t *testing.T) {
+	newCollection := func(t *testing.T) *Collection {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+		doc := userDocument("1", "John")
+		doc.Fields["address"] = DocumentField{Type: DocumentFieldTypeObject, Value: map[string]interface{}{"city": "Kyiv", "zip": "01001"}}
+		doc.Fields["tags"] = DocumentField{Type: DocumentFieldTypeArray, Value: []interface{}{"a", "b"}}
+		doc.Fields["visits"] = DocumentField{Type: DocumentFieldTypeNumber, Value: 2}
+		_, err := collection.Put(doc)
+		assert.Nil(t, err)
+		return collection
+	}
+
+	t.Run("Should set, unset and increment fields", func(t *testing.T) {
+		collection := newCollection(t)
+
+		doc, err := collection.Update("1", Update{
+			Set:   map[string]interface{}{"name": "Jane", "address.city": "Lviv", "tags.1": "c"},
+			Unset: []string{"address.zip"},
+			Inc:   map[string]float64{"visits": 1, "score": 0.5},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "Jane", doc.GetField("name"))
+		assert.Equal(t, map[string]interface{}{"city": "Lviv"}, doc.GetField("address"))
+		assert.Equal(t, []interface{}{"a", "c"}, doc.GetField("tags"))
+		assert.Equal(t, 3.0, doc.GetField("visits"))
+		assert.Equal(t, 0.5, doc.GetField("score"))
+
+		stored, _ := collection.Get("1")
+		assert.Equal(t, doc, stored)
+	})
+
+	t.Run("Should unset fields and array elements", func(t *testing.T) {
+		collection := newCollection(t)
+
+		doc, err := collection.Update("1", Update{Unset: []string{"visits", "tags.0", "missing.path"}})
+		assert.Nil(t, err)
+		assert.NotContains(t, doc.Fields, "visits")
+		assert.Equal(t, []interface{}{nil, "b"}, doc.GetField("tags"))
+	})
+
+	t.Run("Should reject invalid updates", func(t *testing.T) {
+		collection := newCollection(t)
+
+		_, err := collection.Update("1", Update{Set: map[string]interface{}{"id": "2"}})
+		assert.ErrorIs(t, err, ErrInvalidUpdate)
+		_, err = collection.Update("1", Update{Inc: map[string]float64{"name": 1}})
+		assert.ErrorIs(t, err, ErrInvalidUpdate)
+		_, err = collection.Update("1", Update{Set: map[string]interface{}{"name.first": "John"}})
+		assert.ErrorIs(t, err, ErrInvalidUpdate)
+		_, err = collection.Update("1", Update{Set: map[string]interface{}{"tags.5": "x"}})
+		assert.ErrorIs(t, err, ErrInvalidUpdate)
+		_, err = collection.Update("2", Update{Set: map[string]interface{}{"name": "Jane"}})
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+
+		stored, _ := collection.Get("1")
+		assert.Equal(t, "John", stored.GetField("name"))
+	})
+
+	t.Run("Should assign bounds", func(t *testing.T) {
+		collection := newCollection(t)
+
+		doc, err := collection.Update("1", Update{
+			Min: map[string]interface{}{"visits": 1, "address.zip": "02000", "lowest": Decimal("9.99")},
+			Max: map[string]interface{}{"visits": 5, "address.city": "Dnipro"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 5, doc.GetField("visits"))
+		assert.Equal(t, map[string]interface{}{"city": "Kyiv", "zip": "01001"}, doc.GetField("address"))
+		assert.Equal(t, Decimal("9.99"), doc.GetField("lowest"))
+
+		doc, err = collection.Update("1", Update{Min: map[string]interface{}{"lowest": 5}})
+		assert.Nil(t, err)
+		assert.Equal(t, 5, doc.GetField("lowest"))
+	})
+
+	t.Run("Should push and add to sets", func(t *testing.T) {
+		collection := newCollection(t)
+
+		doc, err := collection.Update("1", Update{
+			Push:     map[string][]interface{}{"tags": {"a", "c"}, "logins": {1}},
+			AddToSet: map[string][]interface{}{"tags": {"a", "d", "d"}, "roles": {"admin"}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"a", "b", "a", "c", "d"}, doc.GetField("tags"))
+		assert.Equal(t, []interface{}{1}, doc.GetField("logins"))
+		assert.Equal(t, []interface{}{"admin"}, doc.GetField("roles"))
+	})
+
+	t.Run("Should set current dates", func(t *testing.T) {
+		start, advance := useClock(t)
+		collection := newCollection(t)
+
+		doc, err := collection.Update("1", Update{CurrentDate: []string{"updatedAt", "address.checkedAt"}})
+		assert.Nil(t, err)
+		assert.Equal(t, start, doc.GetField("updatedAt"))
+		assert.Equal(t, DocumentFieldTypeDateTime, doc.Fields["updatedAt"].Type)
+		assert.Equal(t, start, doc.GetField("address").(map[string]interface{})["checkedAt"])
+
+		advance(time.Hour)
+		doc, err = collection.Update("1", Update{CurrentDate: []string{"updatedAt"}})
+		assert.Nil(t, err)
+		assert.Equal(t, start.Add(time.Hour), doc.GetField("updatedAt"))
+	})
+
+	t.Run("Should keep the types of values", func(t *testing.T) {
+		collection := newCollection(t)
+
+		tests := map[string]Update{
+			"name":         {Min: map[string]interface{}{"name": 1}},
+			"visits":       {Max: map[string]interface{}{"visits": "10"}},
+			"address.city": {Push: map[string][]interface{}{"address.city": {"Lviv"}}},
+			"address":      {AddToSet: map[string][]interface{}{"address": {"Lviv"}}},
+			"tags":         {CurrentDate: []string{"tags"}},
+		}
+		for field, update := range tests {
+			_, err := collection.Update("1", update)
+			assert.ErrorIs(t, err, ErrInvalidUpdate)
+			assert.ErrorIs(t, err, &Error{Code: CodeInvalidArgument, ID: "1", Field: field})
+		}
+		_, err := collection.Update("1", Update{Push: map[string][]interface{}{"id": {"2"}}})
+		assert.ErrorIs(t, err, ErrInvalidUpdate)
+	})
+
+	t.Run("Should validate updated values", func(t *testing.T) {
+		collection := NewCollection(&CollectionConfig{PrimaryKey: "id", Schema: &Schema{Fields: map[string]FieldSchema{
+			"tags": {Types: []DocumentFieldType{DocumentFieldTypeArray}, Items: &FieldSchema{Types: []DocumentFieldType{DocumentFieldTypeString}}},
+		}}})
+		collection.Put(userDocument("1", "John"))
+
+		_, err := collection.Update("1", Update{Set: map[string]interface{}{"address.location": GeoPoint{Lat: 91}}})
+		assert.ErrorIs(t, err, ErrValidationFailed)
+		assert.ErrorIs(t, err, &Error{ID: "1", Field: "address.location"})
+		_, err = collection.Update("1", Update{Push: map[string][]interface{}{"scores": {Decimal("1/2")}}})
+		assert.ErrorIs(t, err, &Error{Code: CodeInvalidArgument, Field: "scores.0"})
+		_, err = collection.Update("1", Update{AddToSet: map[string][]interface{}{"tags": {"a", 1}}})
+		assert.ErrorIs(t, err, ErrValidationFailed)
+		assert.ErrorIs(t, err, &Error{Field: "tags.1"})
+
+		doc, err := collection.Update("1", Update{AddToSet: map[string][]interface{}{"tags": {"a", "b"}}})
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"a", "b"}, doc.GetField("tags"))
+	})
+
+	t.Run("Should check references of updated documents", func(t *testing.T) {
+		store := newLibraryStore(t, "")
+		books, _ := store.GetCollection("books")
+
+		_, err := books.Update("b1", Update{Set: map[string]interface{}{"author": "a3"}})
+		assert.ErrorIs(t, err, ErrReferenceViolation)
+		doc, err := books.Update("b1", Update{Set: map[string]interface{}{"author": "a2"}})
+		assert.Nil(t, err)
+		assert.Equal(t, "a2", doc.GetField("author"))
+	})
+}
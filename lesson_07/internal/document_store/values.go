@@ -0,0 +1,377 @@
+package documentstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"math/big"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field values are plain Go values. Nested objects are maps keyed by field
+// name and arrays are slices; after a JSON round trip they are
+// map[string]interface{} and []interface{} and every number is float64.
+// Top-level fields of typed kinds keep their Go types, see
+// DocumentField.UnmarshalJSON.
+// Paths address nested values with dots, array elements by index:
+// "address.city", "tags.0".
+
+// lookupPath returns the value at a dotted path of a document.
+func lookupPath(doc Document, path string) (interface{}, bool) {
+	name, rest, nested := strings.Cut(path, ".")
+	field, ok := doc.Fields[name]
+	if !ok {
+		return nil, false
+	}
+	if !nested {
+		return field.Value, true
+	}
+
+	return lookupValue(field.Value, rest)
+}
+
+func lookupValue(value interface{}, path string) (interface{}, bool) {
+	for _, name := range strings.Split(path, ".") {
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			item := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !item.IsValid() {
+				return nil, false
+			}
+			value = item.Interface()
+		case reflect.Slice, reflect.Array:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= v.Len() {
+				return nil, false
+			}
+			value = v.Index(i).Interface()
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// setPath returns a copy of doc with the value at path replaced. Containers
+// on the way are copied, so doc itself is not modified. Missing objects are
+// created; false is returned when the path crosses a non-object value.
+func setPath(doc Document, path string, value interface{}) (Document, bool) {
+	name, rest, nested := strings.Cut(path, ".")
+	fields := maps.Clone(doc.Fields)
+	if fields == nil {
+		fields = map[string]DocumentField{}
+	}
+	doc.Fields = fields
+
+	if nested {
+		updated, ok := setValue(fields[name].Value, strings.Split(rest, "."), value)
+		if !ok {
+			return doc, false
+		}
+		value = updated
+	}
+
+	fields[name] = newField(value)
+	return doc, true
+}
+
+func setValue(container interface{}, path []string, value interface{}) (interface{}, bool) {
+	if len(path) == 0 {
+		return value, true
+	}
+
+	switch c := normalizeContainer(container).(type) {
+	case nil:
+		updated, ok := setValue(nil, path[1:], value)
+		return map[string]interface{}{path[0]: updated}, ok
+	case map[string]interface{}:
+		updated, ok := setValue(c[path[0]], path[1:], value)
+		if !ok {
+			return nil, false
+		}
+		c = maps.Clone(c)
+		c[path[0]] = updated
+		return c, true
+	case []interface{}:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(c) {
+			return nil, false
+		}
+		updated, ok := setValue(c[i], path[1:], value)
+		if !ok {
+			return nil, false
+		}
+		c = slices.Clone(c)
+		c[i] = updated
+		return c, true
+	default:
+		return nil, false
+	}
+}
+
+// unsetPath returns a copy of doc without the value at path. Array elements
+// are set to null rather than removed, so that later elements keep their
+// indices. false is returned when there is nothing at path.
+func unsetPath(doc Document, path string) (Document, bool) {
+	name, rest, nested := strings.Cut(path, ".")
+	field, ok := doc.Fields[name]
+	if !ok {
+		return doc, false
+	}
+
+	fields := maps.Clone(doc.Fields)
+	doc.Fields = fields
+	if !nested {
+		delete(fields, name)
+		return doc, true
+	}
+
+	updated, ok := unsetValue(field.Value, strings.Split(rest, "."))
+	if !ok {
+		return doc, false
+	}
+	fields[name] = newField(updated)
+
+	return doc, true
+}
+
+func unsetValue(container interface{}, path []string) (interface{}, bool) {
+	switch c := normalizeContainer(container).(type) {
+	case map[string]interface{}:
+		item, ok := c[path[0]]
+		if !ok {
+			return nil, false
+		}
+		c = maps.Clone(c)
+		if len(path) == 1 {
+			delete(c, path[0])
+			return c, true
+		}
+		if c[path[0]], ok = unsetValue(item, path[1:]); !ok {
+			return nil, false
+		}
+		return c, true
+	case []interface{}:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(c) {
+			return nil, false
+		}
+		c = slices.Clone(c)
+		if len(path) == 1 {
+			c[i] = nil
+			return c, true
+		}
+		var ok bool
+		if c[i], ok = unsetValue(c[i], path[1:]); !ok {
+			return nil, false
+		}
+		return c, true
+	default:
+		return nil, false
+	}
+}
+
+// normalizeContainer converts maps and slices of any type to the generic
+// forms used after a JSON round trip. Other values are returned as is.
+func normalizeContainer(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return value
+		}
+		m := make(map[string]interface{}, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			m[it.Key().String()] = it.Value().Interface()
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if _, ok := value.([]byte); ok {
+			return value
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = v.Index(i).Interface()
+		}
+		return s
+	default:
+		return value
+	}
+}
+
+// newField wraps a value with the type validateDocument expects for it.
+func newField(value interface{}) DocumentField {
+	return DocumentField{Type: GetValueType(value), Value: value}
+}
+
+// documentValues returns the fields of a document as a plain object.
+func documentValues(doc Document) map[string]interface{} {
+	values := make(map[string]interface{}, len(doc.Fields))
+	for name, field := range doc.Fields {
+		values[name] = field.Value
+	}
+
+	return values
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	if d, ok := value.(Decimal); ok {
+		r, valid := d.rat()
+		if !valid {
+			return 0, false
+		}
+		f, _ := r.Float64()
+		return f, true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// Ranks order values of different types: missing and null values first,
+// then numbers, strings, bools, datetimes, binary and everything else.
+const (
+	rankNull = iota
+	rankNumber
+	rankString
+	rankBool
+	rankDateTime
+	rankBinary
+	rankOther
+)
+
+func valueRank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return rankNull
+	case time.Time:
+		return rankDateTime
+	case []byte:
+		return rankBinary
+	}
+	if _, ok := toNumber(value); ok {
+		return rankNumber
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String:
+		return rankString
+	case reflect.Bool:
+		return rankBool
+	default:
+		return rankOther
+	}
+}
+
+// compareValues orders two values. Numbers of any Go type, decimals
+// included, compare by value; values of different types compare by rank.
+// Arrays and objects are equal to each other for ordering purposes.
+func compareValues(a, b interface{}) int {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+
+	switch ra {
+	case rankNumber:
+		return compareNumbers(a, b)
+	case rankString:
+		return strings.Compare(reflect.ValueOf(a).String(), reflect.ValueOf(b).String())
+	case rankDateTime:
+		return a.(time.Time).Compare(b.(time.Time))
+	case rankBinary:
+		return bytes.Compare(a.([]byte), b.([]byte))
+	case rankBool:
+		x, y := reflect.ValueOf(a).Bool(), reflect.ValueOf(b).Bool()
+		switch {
+		case x == y:
+			return 0
+		case y:
+			return -1
+		}
+		return 1
+	default:
+		return 0
+	}
+}
+
+// valueKey returns a string that is equal for equal values, treating
+// numbers of different Go types alike.
+func valueKey(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if valueRank(a) != valueRank(b) {
+		return false
+	}
+	if valueRank(a) < rankOther {
+		return compareValues(a, b) == 0
+	}
+
+	return valueKey(a) == valueKey(b)
+}
+
+// compareNumbers compares exactly when a decimal is involved.
+func compareNumbers(a, b interface{}) int {
+	_, decimalA := a.(Decimal)
+	_, decimalB := b.(Decimal)
+	if decimalA || decimalB {
+		x, okA := toRat(a)
+		y, okB := toRat(b)
+		if okA && okB {
+			return x.Cmp(y)
+		}
+	}
+
+	x, _ := toNumber(a)
+	y, _ := toNumber(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func toRat(value interface{}) (*big.Rat, bool) {
+	if d, ok := value.(Decimal); ok {
+		return d.rat()
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Rat).SetUint64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		r := new(big.Rat).SetFloat64(v.Float())
+		return r, r != nil
+	default:
+		return nil, false
+	}
+}
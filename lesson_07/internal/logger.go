@@ -1,23 +1,128 @@
 package internal
 
 import (
+	"context"
+	"io"
+	documentstore "lesson_07/internal/document_store"
 	"log/slog"
 	"os"
 )
 
-func init() {
-	setupLogger()
-}
+// LogFormat is the encoding of log records.
+type LogFormat string
 
-func setupLogger() *slog.Logger {
-	// Console handler (e.g., os.Stdout)
-	consoleHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	})
+const (
+	LogFormatText LogFormat = "text"
+	LogFormatJSON LogFormat = "json"
+)
 
-	// Wrap them with LevelBasedHandler
-	logger := slog.New(consoleHandler)
+// LoggerConfig configures the logger built by SetupLogger.
+type LoggerConfig struct {
+	// Level is the minimum level of records, unless their component has a
+	// level of its own.
+	Level slog.Level
+	// Format defaults to LogFormatText.
+	Format LogFormat
+	// Output defaults to os.Stdout.
+	Output io.Writer
+	// Components sets levels per component of the document store, e.g.
+	// documentstore.ComponentPersistence.
+	Components map[string]slog.Level
+	// Redact lists dotted paths of document fields whose values are never
+	// logged, see documentstore.RedactFields.
+	Redact []string
+	// Redactor is called for the values of document fields not hidden by
+	// Redact.
+	Redactor documentstore.LogRedactor
+}
 
+// SetupLogger makes the logger described by cfg the default one and sets the
+// redaction of logged documents.
+func SetupLogger(cfg LoggerConfig) *slog.Logger {
+	logger := NewLogger(cfg)
 	slog.SetDefault(logger)
+	documentstore.SetLogRedactor(newRedactor(cfg))
+
 	return logger
 }
+
+// NewLogger builds the logger described by cfg without installing it.
+// Redaction of documents is global and set by SetupLogger only.
+func NewLogger(cfg LoggerConfig) *slog.Logger {
+	output := cfg.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	// The handler lets through the lowest of the levels, the rest is
+	// filtered by componentLevelHandler.
+	minLevel := cfg.Level
+	for _, level := range cfg.Components {
+		minLevel = min(minLevel, level)
+	}
+	opts := &slog.HandlerOptions{Level: minLevel}
+
+	var handler slog.Handler
+	if cfg.Format == LogFormatJSON {
+		handler = slog.NewJSONHandler(output, opts)
+	} else {
+		handler = slog.NewTextHandler(output, opts)
+	}
+
+	// Filter records by the levels of their components and add the attributes
+	// set by documentstore.WithLogAttrs.
+	handler = &componentLevelHandler{handler: handler, level: cfg.Level, components: cfg.Components}
+
+	return slog.New(documentstore.NewContextHandler(handler))
+}
+
+func newRedactor(cfg LoggerConfig) documentstore.LogRedactor {
+	switch {
+	case len(cfg.Redact) == 0:
+		return cfg.Redactor
+	case cfg.Redactor == nil:
+		return documentstore.RedactFields(cfg.Redact...)
+	}
+
+	fields := documentstore.RedactFields(cfg.Redact...)
+	return func(path string, value interface{}) interface{} {
+		if value = fields(path, value); value == documentstore.RedactedValue {
+			return value
+		}
+		return cfg.Redactor(path, value)
+	}
+}
+
+// componentLevelHandler applies the level of the component a logger was
+// created for with documentstore.ComponentKey.
+type componentLevelHandler struct {
+	handler    slog.Handler
+	level      slog.Level
+	components map[string]slog.Level
+}
+
+func (h *componentLevelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *componentLevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *componentLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	level := h.level
+	for _, attr := range attrs {
+		if attr.Key != documentstore.ComponentKey {
+			continue
+		}
+		if componentLevel, ok := h.components[attr.Value.String()]; ok {
+			level = componentLevel
+		}
+	}
+
+	return &componentLevelHandler{handler: h.handler.WithAttrs(attrs), level: level, components: h.components}
+}
+
+func (h *componentLevelHandler) WithGroup(name string) slog.Handler {
+	return &componentLevelHandler{handler: h.handler.WithGroup(name), level: h.level, components: h.components}
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	documentstore "lesson_07/internal/document_store"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Run("Should apply levels per component", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := NewLogger(LoggerConfig{
+			Level:  slog.LevelInfo,
+			Output: &buf,
+			Components: map[string]slog.Level{
+				documentstore.ComponentPersistence: slog.LevelDebug,
+				documentstore.ComponentCollection:  slog.LevelError,
+			},
+		})
+
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.With(documentstore.ComponentKey, documentstore.ComponentPersistence).Debug("persistence debug")
+		logger.With(documentstore.ComponentKey, documentstore.ComponentCollection).Warn("collection warn")
+		logger.With(documentstore.ComponentKey, documentstore.ComponentStore).Debug("store debug")
+
+		output := buf.String()
+		assert.Equal(t, 2, strings.Count(output, "\n"))
+		assert.Contains(t, output, "msg=info")
+		assert.Contains(t, output, `msg="persistence debug" component=persistence`)
+	})
+
+	t.Run("Should write JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := NewLogger(LoggerConfig{Format: LogFormatJSON, Output: &buf})
+
+		logger.Info("Put document", "key", "1")
+
+		var record map[string]interface{}
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "Put document", record["msg"])
+		assert.Equal(t, "1", record["key"])
+	})
+}
+
+func TestSetupLogger(t *testing.T) {
+	t.Run("Should redact documents logged by the store", func(t *testing.T) {
+		defaultLogger := slog.Default()
+		t.Cleanup(func() {
+			slog.SetDefault(defaultLogger)
+			documentstore.SetLogRedactor(nil)
+		})
+
+		var buf bytes.Buffer
+		SetupLogger(LoggerConfig{
+			Level:  slog.LevelDebug,
+			Output: &buf,
+			Redact: []string{"password"},
+			Redactor: func(path string, value interface{}) interface{} {
+				if path == "email" {
+					return "***"
+				}
+				return value
+			},
+		})
+
+		collection := documentstore.NewCollection(&documentstore.CollectionConfig{PrimaryKey: "id"})
+		_, err := collection.Put(documentstore.Document{Fields: map[string]documentstore.DocumentField{
+			"id":       {Type: documentstore.DocumentFieldTypeString, Value: "1"},
+			"email":    {Type: documentstore.DocumentFieldTypeString, Value: "john@example.com"},
+			"password": {Type: documentstore.DocumentFieldTypeString, Value: "secret"},
+		}})
+		assert.Nil(t, err)
+
+		output := buf.String()
+		assert.Contains(t, output, "doc.email=*** doc.id=1 doc.password=[REDACTED]")
+		assert.NotContains(t, output, "secret")
+		assert.NotContains(t, output, "john@")
+	})
+}
@@ -0,0 +1,67 @@
+package lsm
+
+import (
+	"hash/fnv"
+)
+
+// bloom is a bloom filter over segment keys. It uses double hashing to derive
+// k probe positions from a single 64-bit hash.
+type bloom struct {
+	k    uint8
+	bits []byte
+}
+
+func newBloom(keys int, bitsPerKey int) *bloom {
+	if bitsPerKey <= 0 {
+		bitsPerKey = 10
+	}
+
+	// k = ln(2) * bits per key gives the lowest false positive rate.
+	k := uint8(float64(bitsPerKey) * 0.69)
+	k = max(1, min(k, 30))
+
+	size := max(keys*bitsPerKey, 64)
+	return &bloom{k: k, bits: make([]byte, (size+7)/8)}
+}
+
+func bloomHash(key string) (uint32, uint32) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+
+	return uint32(sum), uint32(sum >> 32)
+}
+
+func (b *bloom) add(key string) {
+	h1, h2 := bloomHash(key)
+	size := uint32(len(b.bits) * 8)
+	for i := uint32(0); i < uint32(b.k); i++ {
+		bit := (h1 + i*h2) % size
+		b.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+func (b *bloom) mayContain(key string) bool {
+	h1, h2 := bloomHash(key)
+	size := uint32(len(b.bits) * 8)
+	for i := uint32(0); i < uint32(b.k); i++ {
+		bit := (h1 + i*h2) % size
+		if b.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (b *bloom) encode() []byte {
+	return append([]byte{b.k}, b.bits...)
+}
+
+func decodeBloom(data []byte) *bloom {
+	if len(data) < 2 {
+		return nil
+	}
+
+	return &bloom{k: data[0], bits: data[1:]}
+}
@@ -0,0 +1,292 @@
+package lsm
+
+import (
+	"sort"
+)
+
+// compaction merges input segments of level `level` (and overlapping
+// segments of the next level) into `level+1`.
+type compaction struct {
+	level  int
+	inputs [2][]*segment
+}
+
+func (c *compaction) segments() []*segment {
+	return append(append([]*segment(nil), c.inputs[0]...), c.inputs[1]...)
+}
+
+func (db *DB) scheduleCompaction() {
+	if db.opts.DisableAutoCompaction {
+		return
+	}
+
+	select {
+	case db.compactCh <- struct{}{}:
+	default:
+	}
+}
+
+func (db *DB) compactionLoop() {
+	defer db.wg.Done()
+
+	for {
+		select {
+		case <-db.closing:
+			return
+		case <-db.compactCh:
+			if err := db.compact(db.closing); err != nil {
+				db.mu.Lock()
+				db.bgErr = err
+				db.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// Compact runs compactions until no level exceeds its limits.
+func (db *DB) Compact() error {
+	db.mu.RLock()
+	err := db.checkOpen()
+	db.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return db.compact(nil)
+}
+
+func (db *DB) compact(stop <-chan struct{}) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		done, err := db.compactOnce()
+		if err != nil || !done {
+			return err
+		}
+	}
+}
+
+func (db *DB) maxLevelBytes(level int) int64 {
+	size := db.opts.BaseLevelSize
+	for i := 1; i < level; i++ {
+		size *= int64(db.opts.LevelSizeMultiplier)
+	}
+
+	return size
+}
+
+// pickCompaction must be called with db.mu held.
+func (db *DB) pickCompaction() *compaction {
+	v := db.version
+	if len(v.levels[0]) >= db.opts.L0CompactionTrigger {
+		c := &compaction{level: 0}
+		c.inputs[0] = v.levels[0]
+		minKey, maxKey := keyRange(c.inputs[0])
+		c.inputs[1] = overlapping(v.levels[1], minKey, maxKey)
+		return c
+	}
+
+	for level := 1; level < maxLevels-1; level++ {
+		var size int64
+		for _, s := range v.levels[level] {
+			size += s.size
+		}
+		if size <= db.maxLevelBytes(level) {
+			continue
+		}
+
+		// Rotate through the level so that every key range gets compacted.
+		segments := v.levels[level]
+		i := sort.Search(len(segments), func(i int) bool { return segments[i].minKey > db.compactPointer[level] })
+		if i == len(segments) {
+			i = 0
+		}
+		db.compactPointer[level] = segments[i].maxKey
+
+		c := &compaction{level: level}
+		c.inputs[0] = []*segment{segments[i]}
+		c.inputs[1] = overlapping(v.levels[level+1], segments[i].minKey, segments[i].maxKey)
+		return c
+	}
+
+	return nil
+}
+
+func keyRange(segments []*segment) (string, string) {
+	minKey, maxKey := segments[0].minKey, segments[0].maxKey
+	for _, s := range segments[1:] {
+		minKey = min(minKey, s.minKey)
+		maxKey = max(maxKey, s.maxKey)
+	}
+
+	return minKey, maxKey
+}
+
+func overlapping(level []*segment, minKey, maxKey string) []*segment {
+	var result []*segment
+	for _, s := range level {
+		if s.overlaps(minKey, maxKey) {
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
+
+func (db *DB) compactOnce() (bool, error) {
+	db.compactMu.Lock()
+	defer db.compactMu.Unlock()
+
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return false, nil
+	}
+	c := db.pickCompaction()
+	if c == nil {
+		db.mu.Unlock()
+		return false, nil
+	}
+
+	// Tombstones can be dropped when no deeper level may hold older values.
+	bottom := true
+	for level := c.level + 2; level < maxLevels; level++ {
+		if len(db.version.levels[level]) > 0 {
+			bottom = false
+		}
+	}
+	for _, s := range c.segments() {
+		s.ref()
+	}
+	db.mu.Unlock()
+
+	outputs, err := db.merge(c, bottom)
+	for _, s := range c.segments() {
+		s.unref()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	removed := map[*segment]bool{}
+	for _, s := range c.segments() {
+		removed[s] = true
+	}
+
+	v := &version{}
+	for level, segments := range db.version.levels {
+		for _, s := range segments {
+			if !removed[s] {
+				v.levels[level] = append(v.levels[level], s)
+			}
+		}
+	}
+	target := c.level + 1
+	v.levels[target] = append(v.levels[target], outputs...)
+	sort.Slice(v.levels[target], func(i, j int) bool {
+		return v.levels[target][i].minKey < v.levels[target][j].minKey
+	})
+
+	previous := db.version
+	db.version = v
+	if err := db.saveManifest(); err != nil {
+		db.version = previous
+		for _, s := range outputs {
+			s.obsolete.Store(true)
+			s.unref()
+		}
+		return false, err
+	}
+
+	for s := range removed {
+		s.obsolete.Store(true)
+		s.unref()
+	}
+
+	return true, nil
+}
+
+// merge writes the merged content of the compaction inputs into new
+// segments split at the target file size.
+func (db *DB) merge(c *compaction, bottom bool) ([]*segment, error) {
+	var sources []source
+	if c.level == 0 {
+		for _, s := range c.inputs[0] {
+			sources = append(sources, s.iterator(""))
+		}
+	} else {
+		sources = append(sources, newLevelSource(c.inputs[0], ""))
+	}
+	if len(c.inputs[1]) > 0 {
+		sources = append(sources, newLevelSource(c.inputs[1], ""))
+	}
+
+	var outputs []*segment
+	var w *segmentWriter
+	abort := func(err error) ([]*segment, error) {
+		if w != nil {
+			_ = w.abort(err)
+		}
+		for _, s := range outputs {
+			s.obsolete.Store(true)
+			s.unref()
+		}
+		return nil, err
+	}
+	finish := func() error {
+		s, err := w.finish(true)
+		if err != nil {
+			return err
+		}
+		outputs = append(outputs, s)
+		w = nil
+		return nil
+	}
+
+	it := newMergeIterator(sources)
+	for it.next() {
+		e := it.entry()
+		if e.deleted && bottom {
+			continue
+		}
+
+		if w == nil {
+			db.mu.Lock()
+			id := db.nextFile()
+			db.mu.Unlock()
+
+			var err error
+			if w, err = newSegmentWriter(id, segmentPath(db.dir, id), db.opts.BloomBitsPerKey); err != nil {
+				return abort(err)
+			}
+		}
+		if err := w.add(e); err != nil {
+			return abort(err)
+		}
+		if w.size() >= db.opts.TargetFileSize {
+			if err := finish(); err != nil {
+				return abort(err)
+			}
+		}
+	}
+	if err := it.error(); err != nil {
+		return abort(err)
+	}
+	if w != nil && !w.empty() {
+		if err := finish(); err != nil {
+			return abort(err)
+		}
+	} else if w != nil {
+		_ = w.abort(nil)
+	}
+
+	return outputs, nil
+}
@@ -0,0 +1,578 @@
+// Package lsm implements a log-structured merge tree key/value engine.
+//
+// Writes go to a write-ahead log and an in-memory memtable. A full memtable
+// is flushed into an immutable sorted segment file on level 0. Segments carry
+// a bloom filter so point reads skip files that cannot contain a key. A
+// background goroutine merges level 0 into level 1 and each overfull level
+// into the next one, dropping shadowed values and, on the bottom level,
+// tombstones.
+package lsm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const maxLevels = 7
+
+var ErrClosed = errors.New("lsm: database is closed")
+
+type Options struct {
+	// MemtableSize is the approximate amount of bytes buffered in memory
+	// before a flush to level 0.
+	MemtableSize int
+	// L0CompactionTrigger is the number of level 0 segments that starts a
+	// compaction into level 1.
+	L0CompactionTrigger int
+	// BaseLevelSize is the size limit of level 1 in bytes. Every following
+	// level may be LevelSizeMultiplier times bigger.
+	BaseLevelSize       int64
+	LevelSizeMultiplier int
+	// TargetFileSize is the size at which compaction starts a new segment.
+	TargetFileSize  int64
+	BloomBitsPerKey int
+	// SyncWrites fsyncs the write-ahead log after every write.
+	SyncWrites bool
+	// DisableAutoCompaction turns off background compaction; Compact can
+	// still be called explicitly.
+	DisableAutoCompaction bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.MemtableSize <= 0 {
+		o.MemtableSize = 4 << 20
+	}
+	if o.L0CompactionTrigger <= 0 {
+		o.L0CompactionTrigger = 4
+	}
+	if o.BaseLevelSize <= 0 {
+		o.BaseLevelSize = 10 << 20
+	}
+	if o.LevelSizeMultiplier <= 1 {
+		o.LevelSizeMultiplier = 10
+	}
+	if o.TargetFileSize <= 0 {
+		o.TargetFileSize = 2 << 20
+	}
+	if o.BloomBitsPerKey <= 0 {
+		o.BloomBitsPerKey = 10
+	}
+
+	return o
+}
+
+// version is an immutable set of live segments. Level 0 is ordered from
+// newest to oldest, other levels by key range.
+type version struct {
+	levels [maxLevels][]*segment
+}
+
+func (v *version) clone() *version {
+	c := &version{}
+	for i, level := range v.levels {
+		c.levels[i] = append([]*segment(nil), level...)
+	}
+
+	return c
+}
+
+func (v *version) ref() {
+	for _, level := range v.levels {
+		for _, s := range level {
+			s.ref()
+		}
+	}
+}
+
+func (v *version) unref() {
+	for _, level := range v.levels {
+		for _, s := range level {
+			s.unref()
+		}
+	}
+}
+
+type DB struct {
+	dir  string
+	opts Options
+
+	writeMu sync.Mutex // serializes writes and flushes
+	wal     *wal
+	batches atomic.Int32 // running batches, see Batch
+
+	mu       sync.RWMutex // guards fields below
+	mem      map[string]entry
+	memSize  int
+	imm      map[string]entry
+	version  *version
+	manifest manifest
+	closed   bool
+	bgErr    error
+
+	compactMu      sync.Mutex
+	compactPointer [maxLevels]string
+	compactCh      chan struct{}
+	closing        chan struct{}
+	wg             sync.WaitGroup
+}
+
+type LevelStats struct {
+	Segments int
+	Bytes    int64
+	Entries  int
+}
+
+type Stats struct {
+	MemtableEntries int
+	MemtableBytes   int
+	Levels          []LevelStats
+}
+
+func Open(dir string, opts *Options) (*DB, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		dir:       dir,
+		opts:      opts.withDefaults(),
+		mem:       map[string]entry{},
+		version:   &version{},
+		compactCh: make(chan struct{}, 1),
+		closing:   make(chan struct{}),
+	}
+	if err := db.load(); err != nil {
+		db.version.unref()
+		return nil, fmt.Errorf("failed to open %s: %w", dir, err)
+	}
+
+	db.wg.Add(1)
+	go db.compactionLoop()
+	db.scheduleCompaction()
+
+	return db, nil
+}
+
+// load opens segments listed in the manifest, replays write-ahead logs of
+// an interrupted session into a level 0 segment and removes leftovers.
+func (db *DB) load() error {
+	m, err := readManifest(db.dir)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		m = &manifest{NextFile: 1}
+	}
+	db.manifest = *m
+
+	live := map[uint64]bool{}
+	for level, ids := range m.Levels {
+		if level >= maxLevels {
+			return fmt.Errorf("manifest has too many levels: %d", len(m.Levels))
+		}
+		for _, id := range ids {
+			s, err := openSegment(id, segmentPath(db.dir, id))
+			if err != nil {
+				return err
+			}
+			db.version.levels[level] = append(db.version.levels[level], s)
+			live[id] = true
+		}
+	}
+
+	files, err := os.ReadDir(db.dir)
+	if err != nil {
+		return err
+	}
+	var logs []uint64
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		id, err := strconv.ParseUint(strings.TrimSuffix(file.Name(), ext), 10, 64)
+		if err != nil {
+			continue
+		}
+		db.manifest.NextFile = max(db.manifest.NextFile, id+1)
+
+		switch {
+		case ext == ".sst" && !live[id]:
+			_ = os.Remove(filepath.Join(db.dir, file.Name()))
+		case ext == ".log" && id < m.WAL:
+			_ = os.Remove(filepath.Join(db.dir, file.Name()))
+		case ext == ".log":
+			logs = append(logs, id)
+		}
+	}
+	slices.Sort(logs)
+
+	for _, id := range logs {
+		err := replayWAL(walPath(db.dir, id), func(e entry) {
+			db.mem[e.key] = e
+			db.memSize += e.size()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	walNumber := db.nextFile()
+	db.wal, err = createWAL(walPath(db.dir, walNumber))
+	if err != nil {
+		return err
+	}
+	if len(db.mem) > 0 {
+		s, err := db.writeSegment(db.mem)
+		if err != nil {
+			return err
+		}
+		db.version.levels[0] = append([]*segment{s}, db.version.levels[0]...)
+		db.mem = map[string]entry{}
+		db.memSize = 0
+	}
+	db.manifest.WAL = walNumber
+	if err := db.saveManifest(); err != nil {
+		return err
+	}
+
+	for _, id := range logs {
+		_ = os.Remove(walPath(db.dir, id))
+	}
+
+	return nil
+}
+
+// nextFile reserves a file number. Must be called with db.mu held or before
+// the database is shared.
+func (db *DB) nextFile() uint64 {
+	id := db.manifest.NextFile
+	db.manifest.NextFile++
+
+	return id
+}
+
+// saveManifest persists the current version. Must be called with db.mu held.
+func (db *DB) saveManifest() error {
+	db.manifest.Levels = make([][]uint64, maxLevels)
+	for level, segments := range db.version.levels {
+		db.manifest.Levels[level] = []uint64{}
+		for _, s := range segments {
+			db.manifest.Levels[level] = append(db.manifest.Levels[level], s.id)
+		}
+	}
+
+	return writeManifest(db.dir, &db.manifest, true)
+}
+
+func (db *DB) Put(key string, value []byte) error {
+	return db.write(entry{key: key, value: value})
+}
+
+func (db *DB) Delete(key string) error {
+	return db.write(entry{key: key, deleted: true})
+}
+
+func (db *DB) write(e entry) error {
+	db.writeMu.Lock()
+	defer db.writeMu.Unlock()
+
+	db.mu.Lock()
+	if err := db.checkOpen(); err != nil {
+		db.mu.Unlock()
+		return err
+	}
+	db.mu.Unlock()
+
+	if err := db.wal.append(e, db.opts.SyncWrites && db.batches.Load() == 0); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	db.mem[e.key] = e
+	db.memSize += e.size()
+	full := db.memSize >= db.opts.MemtableSize
+	db.mu.Unlock()
+
+	if full {
+		return db.flush()
+	}
+
+	return nil
+}
+
+// checkOpen must be called with db.mu held.
+func (db *DB) checkOpen() error {
+	if db.closed {
+		return ErrClosed
+	}
+	if db.bgErr != nil {
+		return fmt.Errorf("lsm: background compaction failed: %w", db.bgErr)
+	}
+
+	return nil
+}
+
+// Get returns the value of key. Deleted and missing keys are reported as not
+// found.
+func (db *DB) Get(key string) ([]byte, bool, error) {
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return nil, false, ErrClosed
+	}
+	for _, mem := range []map[string]entry{db.mem, db.imm} {
+		if e, ok := mem[key]; ok {
+			db.mu.RUnlock()
+			return e.value, !e.deleted, nil
+		}
+	}
+	v := db.version
+	v.ref()
+	db.mu.RUnlock()
+	defer v.unref()
+
+	for _, s := range v.levels[0] {
+		if e, ok, err := s.get(key); err != nil || ok {
+			return e.value, ok && !e.deleted, err
+		}
+	}
+	for _, level := range v.levels[1:] {
+		i := sort.Search(len(level), func(i int) bool { return level[i].maxKey >= key })
+		if i == len(level) {
+			continue
+		}
+		if e, ok, err := level[i].get(key); err != nil || ok {
+			return e.value, ok && !e.deleted, err
+		}
+	}
+
+	return nil, false, nil
+}
+
+// Scan calls fn for every live key >= from in ascending order until fn
+// returns false. The scan observes the state at the moment it was started
+// and fn may write to the database.
+func (db *DB) Scan(from string, fn func(key string, value []byte) bool) error {
+	snapshot, err := db.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	return snapshot.Scan(from, fn)
+}
+
+// Batch runs fn deferring the log syncs of SyncWrites to a single sync after
+// fn returns. Writes made by fn, and by other goroutines meanwhile, are
+// applied immediately but may be lost on a crash until Batch returns.
+func (db *DB) Batch(fn func()) error {
+	db.batches.Add(1)
+	fn()
+	db.batches.Add(-1)
+	if !db.opts.SyncWrites {
+		return nil
+	}
+
+	db.writeMu.Lock()
+	defer db.writeMu.Unlock()
+
+	db.mu.RLock()
+	err := db.checkOpen()
+	db.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return db.wal.sync()
+}
+
+// Flush writes the memtable into a level 0 segment.
+func (db *DB) Flush() error {
+	db.writeMu.Lock()
+	defer db.writeMu.Unlock()
+
+	db.mu.RLock()
+	err := db.checkOpen()
+	db.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return db.flush()
+}
+
+// flush must be called with db.writeMu held.
+func (db *DB) flush() error {
+	db.mu.Lock()
+	if len(db.mem) == 0 {
+		db.mu.Unlock()
+		return nil
+	}
+	imm := db.mem
+	db.imm = imm
+	db.mem = map[string]entry{}
+	db.memSize = 0
+	walNumber := db.nextFile()
+	db.mu.Unlock()
+
+	oldWAL := db.wal
+	newWAL, err := createWAL(walPath(db.dir, walNumber))
+	if err != nil {
+		return db.restoreMemtable(imm, err)
+	}
+
+	s, err := db.writeSegment(imm)
+	if err != nil {
+		_ = newWAL.close()
+		_ = os.Remove(walPath(db.dir, walNumber))
+		return db.restoreMemtable(imm, err)
+	}
+
+	db.mu.Lock()
+	v := db.version.clone()
+	v.levels[0] = append([]*segment{s}, v.levels[0]...)
+	db.version = v
+	db.imm = nil
+	db.manifest.WAL = walNumber
+	err = db.saveManifest()
+	db.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	db.wal = newWAL
+	_ = oldWAL.close()
+	_ = os.Remove(oldWAL.file.Name())
+	db.scheduleCompaction()
+
+	return nil
+}
+
+// restoreMemtable puts a memtable back after a failed flush. Entries written
+// in the meantime are newer and win.
+func (db *DB) restoreMemtable(imm map[string]entry, err error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for key, e := range imm {
+		if _, ok := db.mem[key]; !ok {
+			db.mem[key] = e
+			db.memSize += e.size()
+		}
+	}
+	db.imm = nil
+
+	return err
+}
+
+func (db *DB) writeSegment(mem map[string]entry) (*segment, error) {
+	db.mu.Lock()
+	id := db.nextFile()
+	db.mu.Unlock()
+
+	w, err := newSegmentWriter(id, segmentPath(db.dir, id), db.opts.BloomBitsPerKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range sortedEntries(mem) {
+		if err := w.add(e); err != nil {
+			return nil, w.abort(err)
+		}
+	}
+
+	return w.finish(true)
+}
+
+func sortedEntries(mem map[string]entry) []entry {
+	entries := make([]entry, 0, len(mem))
+	for _, e := range mem {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	return entries
+}
+
+func (db *DB) Stats() Stats {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	stats := Stats{MemtableEntries: len(db.mem) + len(db.imm), MemtableBytes: db.memSize}
+	for _, level := range db.version.levels {
+		ls := LevelStats{Segments: len(level)}
+		for _, s := range level {
+			ls.Bytes += s.size
+			ls.Entries += s.count
+		}
+		stats.Levels = append(stats.Levels, ls)
+	}
+
+	return stats
+}
+
+// Close stops background compaction and releases files. Unflushed writes
+// stay in the write-ahead log and are recovered by the next Open.
+func (db *DB) Close() error {
+	db.writeMu.Lock()
+	defer db.writeMu.Unlock()
+
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return nil
+	}
+	db.closed = true
+	db.mu.Unlock()
+
+	close(db.closing)
+	db.wg.Wait()
+
+	err := db.wal.close()
+	db.mu.Lock()
+	db.version.unref()
+	db.mu.Unlock()
+
+	return err
+}
+
+// Remove deletes the files of a closed database in dir: the manifest,
+// write-ahead logs and segments. Other files are kept; dir is removed only
+// when nothing else is left in it.
+func Remove(dir string) error {
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, file := range files {
+		name := file.Name()
+		ext := filepath.Ext(name)
+		_, err := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 64)
+		owned := name == manifestName || name == manifestName+".tmp" || (err == nil && (ext == ".sst" || ext == ".log"))
+		if !owned {
+			kept++
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	if kept > 0 {
+		return nil
+	}
+
+	return os.Remove(dir)
+}
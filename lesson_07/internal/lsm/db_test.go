@@ -0,0 +1,283 @@
+package lsm
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func smallOptions() *Options {
+	return &Options{
+		MemtableSize:        1 << 10,
+		L0CompactionTrigger: 2,
+		BaseLevelSize:       4 << 10,
+		LevelSizeMultiplier: 2,
+		TargetFileSize:      2 << 10,
+	}
+}
+
+func scanAll(t *testing.T, db *DB) map[string]string {
+	t.Helper()
+	result := map[string]string{}
+	var previous string
+	err := db.Scan("", func(key string, value []byte) bool {
+		assert.Greater(t, key, previous, "keys should be ordered")
+		previous = key
+		result[key] = string(value)
+		return true
+	})
+	assert.Nil(t, err)
+
+	return result
+}
+
+func TestDB_PutGetDelete(t *testing.T) {
+	db, err := Open(t.TempDir(), nil)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	t.Run("Should read written value", func(t *testing.T) {
+		assert.Nil(t, db.Put("a", []byte("1")))
+		value, ok, err := db.Get("a")
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), value)
+	})
+
+	t.Run("Should hide deleted value", func(t *testing.T) {
+		assert.Nil(t, db.Delete("a"))
+		_, ok, err := db.Get("a")
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Should hide deleted value after flush", func(t *testing.T) {
+		assert.Nil(t, db.Put("b", []byte("1")))
+		assert.Nil(t, db.Flush())
+		assert.Nil(t, db.Delete("b"))
+		assert.Nil(t, db.Flush())
+
+		_, ok, err := db.Get("b")
+		assert.Nil(t, err)
+		assert.False(t, ok)
+		assert.Empty(t, scanAll(t, db))
+	})
+}
+
+func TestDB_Recovery(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put("flushed", []byte("1")))
+	assert.Nil(t, db.Flush())
+	assert.Nil(t, db.Put("logged", []byte("2")))
+	assert.Nil(t, db.Close())
+
+	db, err = Open(dir, nil)
+	assert.Nil(t, err)
+	defer db.Close()
+	assert.Equal(t, map[string]string{"flushed": "1", "logged": "2"}, scanAll(t, db))
+}
+
+func TestDB_Batch(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, &Options{SyncWrites: true})
+	assert.Nil(t, err)
+
+	err = db.Batch(func() {
+		assert.Nil(t, db.Put("a", []byte("1")))
+		assert.Nil(t, db.Put("b", []byte("2")))
+		assert.Nil(t, db.Delete("a"))
+		assert.Equal(t, map[string]string{"b": "2"}, scanAll(t, db))
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	db, err = Open(dir, nil)
+	assert.Nil(t, err)
+	defer db.Close()
+	assert.Equal(t, map[string]string{"b": "2"}, scanAll(t, db))
+}
+
+func TestDB_TornWALRecord(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put("a", []byte("1")))
+	walFile := db.wal.file.Name()
+	assert.Nil(t, db.Close())
+
+	file, err := os.OpenFile(walFile, os.O_WRONLY|os.O_APPEND, 0644)
+	assert.Nil(t, err)
+	_, err = file.Write([]byte{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	db, err = Open(dir, nil)
+	assert.Nil(t, err)
+	defer db.Close()
+	assert.Equal(t, map[string]string{"a": "1"}, scanAll(t, db))
+}
+
+func TestDB_Compaction(t *testing.T) {
+	db, err := Open(t.TempDir(), &Options{
+		MemtableSize:          1 << 10,
+		L0CompactionTrigger:   2,
+		BaseLevelSize:         4 << 10,
+		LevelSizeMultiplier:   2,
+		TargetFileSize:        2 << 10,
+		DisableAutoCompaction: true,
+	})
+	assert.Nil(t, err)
+	defer db.Close()
+
+	for i := 0; i < 2000; i++ {
+		assert.Nil(t, db.Put(fmt.Sprintf("key-%04d", i%500), []byte(fmt.Sprintf("value-%d", i))))
+	}
+	for i := 0; i < 250; i++ {
+		assert.Nil(t, db.Delete(fmt.Sprintf("key-%04d", i)))
+	}
+	assert.Nil(t, db.Flush())
+	assert.Nil(t, db.Compact())
+
+	stats := db.Stats()
+	assert.Less(t, stats.Levels[0].Segments, 2, "level 0 should be compacted")
+	entries := 0
+	for _, level := range stats.Levels {
+		entries += level.Entries
+	}
+	assert.LessOrEqual(t, entries, 500, "shadowed values should be dropped")
+
+	content := scanAll(t, db)
+	assert.Len(t, content, 250)
+	assert.Equal(t, "value-1999", content["key-0499"])
+}
+
+// TestDB_Model compares the engine with a map under random writes, deletes,
+// flushes, background compactions and reopens.
+func TestDB_Model(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, smallOptions())
+	assert.Nil(t, err)
+
+	rnd := rand.New(rand.NewSource(42))
+	model := map[string]string{}
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("key-%04d", rnd.Intn(800))
+		switch op := rnd.Intn(10); {
+		case op < 6:
+			value := fmt.Sprintf("value-%d", i)
+			model[key] = value
+			assert.Nil(t, db.Put(key, []byte(value)))
+		case op < 9:
+			delete(model, key)
+			assert.Nil(t, db.Delete(key))
+		default:
+			value, ok, err := db.Get(key)
+			assert.Nil(t, err)
+			expected, exists := model[key]
+			assert.Equal(t, exists, ok, key)
+			assert.Equal(t, expected, string(value), key)
+		}
+
+		if i%1500 == 1499 {
+			assert.Nil(t, db.Close())
+			db, err = Open(dir, smallOptions())
+			assert.Nil(t, err)
+		}
+	}
+	defer db.Close()
+	assert.Nil(t, db.Compact())
+
+	assert.Equal(t, model, scanAll(t, db))
+	for key, expected := range model {
+		value, ok, err := db.Get(key)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, expected, string(value))
+	}
+
+	t.Run("Should scan from key", func(t *testing.T) {
+		var expected, actual []string
+		for key := range model {
+			if key >= "key-0400" {
+				expected = append(expected, key)
+			}
+		}
+		sort.Strings(expected)
+		err := db.Scan("key-0400", func(key string, _ []byte) bool {
+			actual = append(actual, key)
+			return true
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("Should remove compacted segment files", func(t *testing.T) {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.sst"))
+		segments := 0
+		for _, level := range db.Stats().Levels {
+			segments += level.Segments
+		}
+		assert.Equal(t, segments, len(files))
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	db, err := Open(t.TempDir(), smallOptions())
+	assert.Nil(t, err)
+	defer db.Close()
+
+	assert.Nil(t, db.Put("a", []byte("1")))
+	snapshot, err := db.Snapshot()
+	assert.Nil(t, err)
+	defer snapshot.Close()
+
+	assert.Nil(t, db.Put("a", []byte("2")))
+	for i := 0; i < 500; i++ {
+		assert.Nil(t, db.Put(fmt.Sprintf("key-%04d", i), []byte("value")))
+	}
+
+	value, ok, err := snapshot.Get("a")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	count := 0
+	assert.Nil(t, snapshot.Scan("", func(string, []byte) bool {
+		count++
+		return true
+	}))
+	assert.Equal(t, 1, count)
+}
+
+func TestRemove(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	db, err := Open(dir, smallOptions())
+	assert.Nil(t, err)
+	for i := range 100 {
+		assert.Nil(t, db.Put(fmt.Sprintf("key%03d", i), []byte("value")))
+	}
+	assert.Nil(t, db.Close())
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep"), 0644))
+
+	assert.Nil(t, Remove(dir))
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "notes.txt", files[0].Name())
+
+	assert.Nil(t, os.Remove(filepath.Join(dir, "notes.txt")))
+	db, err = Open(dir, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+	assert.Nil(t, Remove(dir))
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+	assert.Nil(t, Remove(dir))
+}
@@ -0,0 +1,129 @@
+package lsm
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// source is a sorted stream of entries.
+type source interface {
+	next() bool
+	entry() entry
+	error() error
+}
+
+func (it *segmentIterator) entry() entry {
+	return it.current
+}
+
+func (it *segmentIterator) error() error {
+	return it.err
+}
+
+// sliceSource iterates over sorted in-memory entries.
+type sliceSource struct {
+	entries []entry
+	pos     int
+}
+
+func newSliceSource(entries []entry, from string) *sliceSource {
+	pos := sort.Search(len(entries), func(i int) bool { return entries[i].key >= from })
+	return &sliceSource{entries: entries, pos: pos - 1}
+}
+
+func (s *sliceSource) next() bool {
+	s.pos++
+	return s.pos < len(s.entries)
+}
+
+func (s *sliceSource) entry() entry {
+	return s.entries[s.pos]
+}
+
+func (s *sliceSource) error() error {
+	return nil
+}
+
+// mergeIterator merges sources ordered from newest to oldest. For equal keys
+// only the entry of the newest source is returned.
+type mergeIterator struct {
+	heap    sourceHeap
+	current entry
+	err     error
+}
+
+type heapItem struct {
+	source   source
+	priority int
+}
+
+type sourceHeap []heapItem
+
+func (h sourceHeap) Len() int { return len(h) }
+func (h sourceHeap) Less(i, j int) bool {
+	ki, kj := h[i].source.entry().key, h[j].source.entry().key
+	if ki != kj {
+		return ki < kj
+	}
+	return h[i].priority < h[j].priority
+}
+func (h sourceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *sourceHeap) Push(x any)   { *h = append(*h, x.(heapItem)) }
+func (h *sourceHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+func newMergeIterator(sources []source) *mergeIterator {
+	it := &mergeIterator{}
+	for priority, s := range sources {
+		it.advance(heapItem{source: s, priority: priority}, false)
+	}
+	heap.Init(&it.heap)
+
+	return it
+}
+
+// advance moves a source forward and returns it to the heap if it has more
+// entries.
+func (it *mergeIterator) advance(item heapItem, fix bool) {
+	if item.source.next() {
+		if fix {
+			heap.Push(&it.heap, item)
+		} else {
+			it.heap = append(it.heap, item)
+		}
+		return
+	}
+
+	if err := item.source.error(); err != nil && it.err == nil {
+		it.err = err
+	}
+}
+
+func (it *mergeIterator) next() bool {
+	if it.err != nil || len(it.heap) == 0 {
+		return false
+	}
+
+	top := heap.Pop(&it.heap).(heapItem)
+	it.current = top.source.entry()
+	it.advance(top, true)
+
+	for len(it.heap) > 0 && it.heap[0].source.entry().key == it.current.key {
+		shadowed := heap.Pop(&it.heap).(heapItem)
+		it.advance(shadowed, true)
+	}
+
+	return it.err == nil
+}
+
+func (it *mergeIterator) entry() entry {
+	return it.current
+}
+
+func (it *mergeIterator) error() error {
+	return it.err
+}
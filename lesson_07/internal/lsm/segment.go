@@ -0,0 +1,431 @@
+package lsm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync/atomic"
+)
+
+const (
+	segmentMagic  uint64 = 0x4c534d5345474d31
+	footerSize           = 40
+	indexInterval        = 16
+)
+
+const (
+	kindPut    byte = 0
+	kindDelete byte = 1
+)
+
+var errCorruptSegment = errors.New("corrupt segment file")
+
+// entry is a single record. Deletes are stored as tombstones until they
+// reach the bottom level.
+type entry struct {
+	key     string
+	value   []byte
+	deleted bool
+}
+
+func (e entry) size() int {
+	return len(e.key) + len(e.value) + 8
+}
+
+func appendEntry(buf []byte, e entry) []byte {
+	kind := kindPut
+	if e.deleted {
+		kind = kindDelete
+	}
+	buf = append(buf, kind)
+	buf = binary.AppendUvarint(buf, uint64(len(e.key)))
+	buf = append(buf, e.key...)
+	buf = binary.AppendUvarint(buf, uint64(len(e.value)))
+
+	return append(buf, e.value...)
+}
+
+func readEntry(r io.ByteReader) (entry, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return entry{}, err
+	}
+
+	readBytes := func() ([]byte, error) {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, length)
+		for i := range data {
+			if data[i], err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	}
+
+	key, err := readBytes()
+	if err != nil {
+		return entry{}, unexpectedEOF(err)
+	}
+	value, err := readBytes()
+	if err != nil {
+		return entry{}, unexpectedEOF(err)
+	}
+
+	return entry{key: string(key), value: value, deleted: kind == kindDelete}, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+type indexEntry struct {
+	key    string
+	offset int64
+}
+
+// segment is an immutable sorted file. Its layout is
+//
+//	entries | sparse index | bloom filter | footer
+//
+// The sparse index and bloom filter are kept in memory while the segment is open.
+type segment struct {
+	id      uint64
+	path    string
+	file    *os.File
+	size    int64
+	count   int
+	dataEnd int64
+	index   []indexEntry
+	minKey  string
+	maxKey  string
+	bloom   *bloom
+
+	refs     atomic.Int32
+	obsolete atomic.Bool
+}
+
+func (s *segment) ref() {
+	s.refs.Add(1)
+}
+
+// unref closes and removes an obsolete segment once nothing refers to it.
+func (s *segment) unref() {
+	if s.refs.Add(-1) > 0 {
+		return
+	}
+
+	_ = s.file.Close()
+	if s.obsolete.Load() {
+		_ = os.Remove(s.path)
+	}
+}
+
+func (s *segment) overlaps(minKey, maxKey string) bool {
+	return s.maxKey >= minKey && s.minKey <= maxKey
+}
+
+// get returns the entry for key, including tombstones.
+func (s *segment) get(key string) (entry, bool, error) {
+	if key < s.minKey || key > s.maxKey || !s.bloom.mayContain(key) {
+		return entry{}, false, nil
+	}
+
+	i := sort.Search(len(s.index), func(i int) bool { return s.index[i].key > key }) - 1
+	if i < 0 {
+		return entry{}, false, nil
+	}
+	end := s.dataEnd
+	if i+1 < len(s.index) {
+		end = s.index[i+1].offset
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(s.file, s.index[i].offset, end-s.index[i].offset))
+	for {
+		e, err := readEntry(r)
+		if errors.Is(err, io.EOF) {
+			return entry{}, false, nil
+		}
+		if err != nil {
+			return entry{}, false, fmt.Errorf("segment %d: %w", s.id, err)
+		}
+		if e.key == key {
+			return e, true, nil
+		}
+		if e.key > key {
+			return entry{}, false, nil
+		}
+	}
+}
+
+// iterator returns an iterator over keys >= from.
+func (s *segment) iterator(from string) *segmentIterator {
+	offset := int64(0)
+	if i := sort.Search(len(s.index), func(i int) bool { return s.index[i].key > from }) - 1; i >= 0 {
+		offset = s.index[i].offset
+	}
+
+	return &segmentIterator{
+		segment: s,
+		from:    from,
+		reader:  bufio.NewReader(io.NewSectionReader(s.file, offset, s.dataEnd-offset)),
+	}
+}
+
+type segmentIterator struct {
+	segment *segment
+	from    string
+	reader  *bufio.Reader
+	current entry
+	done    bool
+	err     error
+}
+
+func (it *segmentIterator) next() bool {
+	if it.done {
+		return false
+	}
+
+	for {
+		e, err := readEntry(it.reader)
+		if err != nil {
+			it.done = true
+			if !errors.Is(err, io.EOF) {
+				it.err = fmt.Errorf("segment %d: %w", it.segment.id, err)
+			}
+			return false
+		}
+		if e.key >= it.from {
+			it.current = e
+			return true
+		}
+	}
+}
+
+// segmentWriter writes sorted entries to a new segment file.
+type segmentWriter struct {
+	id         uint64
+	path       string
+	file       *os.File
+	writer     *bufio.Writer
+	offset     int64
+	index      []indexEntry
+	keys       []string
+	lastKey    string
+	bitsPerKey int
+	buf        []byte
+}
+
+func newSegmentWriter(id uint64, path string, bitsPerKey int) (*segmentWriter, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &segmentWriter{
+		id:         id,
+		path:       path,
+		file:       file,
+		writer:     bufio.NewWriter(file),
+		bitsPerKey: bitsPerKey,
+	}, nil
+}
+
+func (w *segmentWriter) add(e entry) error {
+	if len(w.keys) > 0 && e.key <= w.lastKey {
+		return fmt.Errorf("segment keys out of order: %q after %q", e.key, w.lastKey)
+	}
+	if len(w.keys)%indexInterval == 0 {
+		w.index = append(w.index, indexEntry{key: e.key, offset: w.offset})
+	}
+
+	w.buf = appendEntry(w.buf[:0], e)
+	if _, err := w.writer.Write(w.buf); err != nil {
+		return err
+	}
+	w.offset += int64(len(w.buf))
+	w.keys = append(w.keys, e.key)
+	w.lastKey = e.key
+
+	return nil
+}
+
+func (w *segmentWriter) size() int64 {
+	return w.offset
+}
+
+func (w *segmentWriter) empty() bool {
+	return len(w.keys) == 0
+}
+
+// finish writes index, bloom filter and footer and opens the segment for reading.
+func (w *segmentWriter) finish(sync bool) (*segment, error) {
+	dataEnd := w.offset
+
+	var index []byte
+	index = binary.AppendUvarint(index, uint64(len(w.index)))
+	for _, ie := range w.index {
+		index = binary.AppendUvarint(index, uint64(len(ie.key)))
+		index = append(index, ie.key...)
+		index = binary.LittleEndian.AppendUint64(index, uint64(ie.offset))
+	}
+	index = binary.AppendUvarint(index, uint64(len(w.lastKey)))
+	index = append(index, w.lastKey...)
+
+	filter := newBloom(len(w.keys), w.bitsPerKey)
+	for _, key := range w.keys {
+		filter.add(key)
+	}
+	bloomData := filter.encode()
+
+	footer := make([]byte, 0, footerSize)
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(dataEnd))
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(dataEnd)+uint64(len(index)))
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(dataEnd)+uint64(len(index)+len(bloomData)))
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(len(w.keys)))
+	footer = binary.LittleEndian.AppendUint64(footer, segmentMagic)
+
+	for _, data := range [][]byte{index, bloomData, footer} {
+		if _, err := w.writer.Write(data); err != nil {
+			return nil, w.abort(err)
+		}
+	}
+	if err := w.writer.Flush(); err != nil {
+		return nil, w.abort(err)
+	}
+	if sync {
+		if err := w.file.Sync(); err != nil {
+			return nil, w.abort(err)
+		}
+	}
+	if err := w.file.Close(); err != nil {
+		return nil, err
+	}
+
+	return openSegment(w.id, w.path)
+}
+
+func (w *segmentWriter) abort(err error) error {
+	_ = w.file.Close()
+	_ = os.Remove(w.path)
+
+	return err
+}
+
+func openSegment(id uint64, path string) (*segment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := loadSegment(id, path, file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("segment %s: %w", path, err)
+	}
+	s.refs.Store(1)
+
+	return s, nil
+}
+
+func loadSegment(id uint64, path string, file *os.File) (*segment, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < footerSize {
+		return nil, errCorruptSegment
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := file.ReadAt(footer, info.Size()-footerSize); err != nil {
+		return nil, err
+	}
+	dataEnd := int64(binary.LittleEndian.Uint64(footer[0:]))
+	indexEnd := int64(binary.LittleEndian.Uint64(footer[8:]))
+	bloomEnd := int64(binary.LittleEndian.Uint64(footer[16:]))
+	count := int(binary.LittleEndian.Uint64(footer[24:]))
+	if binary.LittleEndian.Uint64(footer[32:]) != segmentMagic || dataEnd > indexEnd || indexEnd > bloomEnd || bloomEnd+footerSize != info.Size() {
+		return nil, errCorruptSegment
+	}
+
+	meta := make([]byte, bloomEnd-dataEnd)
+	if _, err := file.ReadAt(meta, dataEnd); err != nil {
+		return nil, err
+	}
+	index, maxKey, err := decodeIndex(meta[:indexEnd-dataEnd])
+	if err != nil {
+		return nil, err
+	}
+
+	s := &segment{
+		id:      id,
+		path:    path,
+		file:    file,
+		size:    info.Size(),
+		count:   count,
+		dataEnd: dataEnd,
+		index:   index,
+		maxKey:  maxKey,
+		bloom:   decodeBloom(meta[indexEnd-dataEnd:]),
+	}
+	if len(index) > 0 {
+		s.minKey = index[0].key
+	}
+	if s.bloom == nil {
+		return nil, errCorruptSegment
+	}
+
+	return s, nil
+}
+
+func decodeIndex(data []byte) ([]indexEntry, string, error) {
+	pos := 0
+	readUvarint := func() (uint64, bool) {
+		v, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return 0, false
+		}
+		pos += n
+		return v, true
+	}
+	readString := func() (string, bool) {
+		length, ok := readUvarint()
+		if !ok || uint64(len(data)-pos) < length {
+			return "", false
+		}
+		s := string(data[pos : pos+int(length)])
+		pos += int(length)
+		return s, true
+	}
+
+	count, ok := readUvarint()
+	if !ok || count > uint64(len(data)) {
+		return nil, "", errCorruptSegment
+	}
+	index := make([]indexEntry, count)
+	for i := range index {
+		key, ok := readString()
+		if !ok || len(data)-pos < 8 {
+			return nil, "", errCorruptSegment
+		}
+		index[i] = indexEntry{key: key, offset: int64(binary.LittleEndian.Uint64(data[pos:]))}
+		pos += 8
+	}
+	maxKey, ok := readString()
+	if !ok {
+		return nil, "", errCorruptSegment
+	}
+
+	return index, maxKey, nil
+}
@@ -0,0 +1,140 @@
+package lsm
+
+import (
+	"sort"
+)
+
+// Snapshot is a consistent read-only view of the database. It keeps the
+// segments it reads from alive until it is closed.
+type Snapshot struct {
+	mem     []entry
+	version *version
+	closed  bool
+}
+
+// Snapshot captures the current state. The memtable is copied, segments are
+// shared with the database.
+func (db *DB) Snapshot() (*Snapshot, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	mem := make(map[string]entry, len(db.mem)+len(db.imm))
+	for key, e := range db.imm {
+		mem[key] = e
+	}
+	for key, e := range db.mem {
+		mem[key] = e
+	}
+	db.version.ref()
+
+	return &Snapshot{mem: sortedEntries(mem), version: db.version}, nil
+}
+
+func (s *Snapshot) Get(key string) ([]byte, bool, error) {
+	if s.closed {
+		return nil, false, ErrClosed
+	}
+
+	if i := sort.Search(len(s.mem), func(i int) bool { return s.mem[i].key >= key }); i < len(s.mem) && s.mem[i].key == key {
+		return s.mem[i].value, !s.mem[i].deleted, nil
+	}
+
+	for _, seg := range s.version.levels[0] {
+		if e, ok, err := seg.get(key); err != nil || ok {
+			return e.value, ok && !e.deleted, err
+		}
+	}
+	for _, level := range s.version.levels[1:] {
+		i := sort.Search(len(level), func(i int) bool { return level[i].maxKey >= key })
+		if i == len(level) {
+			continue
+		}
+		if e, ok, err := level[i].get(key); err != nil || ok {
+			return e.value, ok && !e.deleted, err
+		}
+	}
+
+	return nil, false, nil
+}
+
+// Scan calls fn for every live key >= from in ascending order until fn
+// returns false.
+func (s *Snapshot) Scan(from string, fn func(key string, value []byte) bool) error {
+	if s.closed {
+		return ErrClosed
+	}
+
+	sources := []source{newSliceSource(s.mem, from)}
+	for _, seg := range s.version.levels[0] {
+		sources = append(sources, seg.iterator(from))
+	}
+	for _, level := range s.version.levels[1:] {
+		if len(level) > 0 {
+			sources = append(sources, newLevelSource(level, from))
+		}
+	}
+
+	it := newMergeIterator(sources)
+	for it.next() {
+		e := it.entry()
+		if e.deleted {
+			continue
+		}
+		if !fn(e.key, e.value) {
+			return nil
+		}
+	}
+
+	return it.error()
+}
+
+func (s *Snapshot) Close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.version.unref()
+}
+
+// levelSource iterates over the non-overlapping segments of a level.
+type levelSource struct {
+	segments []*segment
+	from     string
+	current  *segmentIterator
+	err      error
+}
+
+func newLevelSource(segments []*segment, from string) *levelSource {
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].maxKey >= from })
+	return &levelSource{segments: segments[i:], from: from}
+}
+
+func (l *levelSource) next() bool {
+	for {
+		if l.current != nil && l.current.next() {
+			return true
+		}
+		if l.current != nil && l.current.err != nil {
+			l.err = l.current.err
+			return false
+		}
+		if len(l.segments) == 0 {
+			return false
+		}
+
+		l.current = l.segments[0].iterator(l.from)
+		l.segments = l.segments[1:]
+	}
+}
+
+func (l *levelSource) entry() entry {
+	return l.current.entry()
+}
+
+func (l *levelSource) error() error {
+	return l.err
+}